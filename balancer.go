@@ -0,0 +1,245 @@
+package srvclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultBalancerMaxIdle is the number of idle connections a Balancer keeps to
+// each target when MaxIdlePerTarget isn't set
+const DefaultBalancerMaxIdle = 2
+
+var errBalancerStopped = errors.New("balancer has been stopped")
+
+// BalancerConn is a connection returned by a Balancer, to the target with the
+// given Addr ("host:port")
+type BalancerConn struct {
+	net.Conn
+	Addr string
+}
+
+// Balancer spreads connections to the targets of a hostname by their weights,
+// and keeps a pool of idle connections to each of them for reuse. The hostname
+// is looked up in the background whenever the TTL of its last answer runs out,
+// in the same way as Refresher, and once a target is removed from the answer
+// its idle connections are closed, as are its busy ones when they're returned.
+//
+// If a refresh fails then the last successful answer keeps being used.
+type Balancer struct {
+	// MinInterval and MaxInterval bound the time between lookups of the
+	// hostname, regardless of the TTLs. They can only be changed before calling
+	// Start.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// MaxIdlePerTarget is the number of idle connections kept to each target.
+	// Defaults to DefaultBalancerMaxIdle.
+	MaxIdlePerTarget int
+
+	sc       *SRVClient
+	name     string
+	port     string
+	dial     func(ctx context.Context, addr string) (net.Conn, error)
+	resolved chan struct{}
+
+	l       sync.Mutex
+	ans     []*dns.SRV
+	err     error
+	targets map[string]bool
+	idle    map[string][]net.Conn
+	stopped bool
+
+	stopCh chan struct{}
+	stopO  sync.Once
+	wg     sync.WaitGroup
+}
+
+// NewBalancer returns a Balancer which connects to the targets of the given
+// hostname using dial, which is given their address ("ip:port"). Lookups are
+// made using the given client, or DefaultSRVClient if nil. Like SRV, if the
+// hostname contains a port then that port is used for every target. Start must
+// be called for it to begin resolving.
+func NewBalancer(sc *SRVClient, hostname string, dial func(ctx context.Context, addr string) (net.Conn, error)) *Balancer {
+	if sc == nil {
+		sc = DefaultSRVClient
+	}
+	name, port := splitHostPort(hostname)
+	return &Balancer{
+		sc:       sc,
+		name:     name,
+		port:     port,
+		dial:     dial,
+		resolved: make(chan struct{}),
+		err:      errNotResolved,
+		idle:     map[string][]net.Conn{},
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins refreshing the hostname in the background. The first lookup
+// happens immediately.
+func (b *Balancer) Start() {
+	b.wg.Add(1)
+	go b.loop()
+}
+
+// Stop stops refreshing, waits for it to finish, and closes every idle
+// connection. Connections which are returned afterwards are closed. It's safe
+// to call more than once.
+func (b *Balancer) Stop() {
+	b.stopO.Do(func() { close(b.stopCh) })
+	b.wg.Wait()
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.stopped = true
+	for addr, conns := range b.idle {
+		closeConns(conns)
+		delete(b.idle, addr)
+	}
+}
+
+func closeConns(conns []net.Conn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+func (b *Balancer) maxIdle() int {
+	if b.MaxIdlePerTarget > 0 {
+		return b.MaxIdlePerTarget
+	}
+	return DefaultBalancerMaxIdle
+}
+
+func (b *Balancer) refresh(ctx context.Context) []*dns.SRV {
+	ans, err := b.sc.lookupSRV(ctx, b.name, true, false)
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	if len(ans) > 0 {
+		b.ans, b.err = ans, nil
+		b.targets = make(map[string]bool, len(ans))
+		for _, srv := range ans {
+			b.targets[srvToStr(srv, b.port)] = true
+		}
+		// drain the targets which have been removed
+		for addr, conns := range b.idle {
+			if !b.targets[addr] {
+				closeConns(conns)
+				delete(b.idle, addr)
+			}
+		}
+	} else if len(b.ans) == 0 {
+		b.err = err
+	}
+	return ans
+}
+
+func (b *Balancer) loop() {
+	defer b.wg.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-b.stopCh
+		cancel()
+	}()
+
+	for first := true; ; first = false {
+		ans := b.refresh(ctx)
+		if first {
+			close(b.resolved)
+		}
+		t := time.NewTimer(b.sc.jitter(refreshInterval(ans, b.MinInterval, b.MaxInterval)))
+		select {
+		case <-t.C:
+		case <-b.stopCh:
+			t.Stop()
+			return
+		}
+	}
+}
+
+// Get returns a connection to one of the targets, picked in the same way as
+// SRV. An idle connection to the target is reused if there is one, otherwise a
+// new one is dialed, and if that fails then the other targets are tried in
+// turn. If the hostname hasn't been resolved yet then Get waits for the first
+// lookup to finish, or for ctx to be canceled.
+//
+// Idle connections are returned as they are, so if one turns out to be broken
+// then it should be passed to Put with an error.
+func (b *Balancer) Get(ctx context.Context) (*BalancerConn, error) {
+	select {
+	case <-b.resolved:
+	case <-b.stopCh:
+		return nil, errBalancerStopped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	b.l.Lock()
+	if b.stopped {
+		b.l.Unlock()
+		return nil, errBalancerStopped
+	} else if len(b.ans) == 0 {
+		err := b.err
+		b.l.Unlock()
+		return nil, err
+	}
+	ans := b.ans
+
+	var errs []error
+	failed := map[string]bool{}
+	candidates := make([]*dns.SRV, 0, len(ans))
+	for {
+		candidates = candidates[:0]
+		for _, srv := range ans {
+			if !failed[srvToStr(srv, b.port)] {
+				candidates = append(candidates, srv)
+			}
+		}
+		if len(candidates) == 0 {
+			b.l.Unlock()
+			return nil, errors.Join(errs...)
+		}
+
+		addr := srvToStr(b.sc.pick(candidates), b.port)
+		if conns := b.idle[addr]; len(conns) > 0 {
+			conn := conns[len(conns)-1]
+			b.idle[addr] = conns[:len(conns)-1]
+			b.l.Unlock()
+			return &BalancerConn{Conn: conn, Addr: addr}, nil
+		}
+
+		b.l.Unlock()
+		conn, err := b.dial(ctx, addr)
+		if err == nil {
+			return &BalancerConn{Conn: conn, Addr: addr}, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			return nil, errors.Join(errs...)
+		}
+		failed[addr] = true
+		b.l.Lock()
+	}
+}
+
+// Put returns a connection retrieved with Get to the pool. err should be the
+// error, if any, encountered while using the connection, in which case it's
+// closed rather than reused. It's also closed if its target has been removed,
+// or there are already MaxIdlePerTarget idle connections to it.
+func (b *Balancer) Put(c *BalancerConn, err error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if err != nil || b.stopped || !b.targets[c.Addr] || len(b.idle[c.Addr]) >= b.maxIdle() {
+		c.Conn.Close()
+		return
+	}
+	b.idle[c.Addr] = append(b.idle[c.Addr], c.Conn)
+}
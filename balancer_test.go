@@ -0,0 +1,126 @@
+package srvclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalancer(t *testing.T) {
+	listen := func() string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { l.Close() })
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+		return l.Addr().String()
+	}
+	addrA, addrB, dead := listen(), listen(), closedAddr(t)
+	port := func(addr string) string {
+		_, port, _ := net.SplitHostPort(addr)
+		return port
+	}
+
+	var recordsL sync.Mutex
+	records := []string{
+		"pool.test. 0 IN SRV 0 0 " + port(dead) + " p.test.",
+		"pool.test. 0 IN SRV 1 0 " + port(addrA) + " p.test.",
+	}
+	setRecords := func(rrs ...string) {
+		recordsL.Lock()
+		defer recordsL.Unlock()
+		records = rrs
+	}
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if q := r.Question[0]; q.Name == "pool.test." && q.Qtype == dns.TypeSRV {
+			recordsL.Lock()
+			for _, rr := range records {
+				m.Answer = append(m.Answer, newRR(rr))
+			}
+			recordsL.Unlock()
+			m.Extra = []dns.RR{newRR("p.test. 0 IN A 127.0.0.1")}
+		}
+		w.WriteMsg(m)
+	})
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+
+	var dialsL sync.Mutex
+	dials := map[string]int{}
+	b := NewBalancer(&client, "pool.test", func(ctx context.Context, addr string) (net.Conn, error) {
+		dialsL.Lock()
+		dials[addr]++
+		dialsL.Unlock()
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	})
+	b.MinInterval = 50 * time.Millisecond
+	b.Start()
+	defer b.Stop()
+	ctx := context.Background()
+
+	// the dead target is tried first, and then the live one
+	c, err := b.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, addrA, c.Addr)
+
+	// returned connections are reused, unless they're broken
+	b.Put(c, nil)
+	c2, err := b.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, c.Conn, c2.Conn)
+	b.Put(c2, assert.AnError)
+	c3, err := b.Get(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, c.Conn, c3.Conn)
+	dialsL.Lock()
+	assert.Equal(t, map[string]int{dead: 3, addrA: 2}, dials)
+	dials = map[string]int{}
+	dialsL.Unlock()
+
+	// once A is removed its idle connections are closed, and so is the busy
+	// one when it's returned
+	idle, err := b.Get(ctx)
+	require.NoError(t, err)
+	b.Put(idle, nil)
+	setRecords("pool.test. 0 IN SRV 0 0 " + port(addrB) + " p.test.")
+	assert.Eventually(t, func() bool {
+		b.l.Lock()
+		defer b.l.Unlock()
+		return len(b.idle[addrA]) == 0
+	}, time.Second, 10*time.Millisecond)
+	b.Put(c3, nil)
+	b.l.Lock()
+	assert.Empty(t, b.idle[addrA])
+	b.l.Unlock()
+
+	c, err = b.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, addrB, c.Addr)
+	b.Put(c, nil)
+}
+
+func TestBalancerStopTwice(t *testing.T) {
+	b := NewBalancer(nil, testHostname, func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	})
+	b.Start()
+	b.Stop()
+	assert.NotPanics(t, b.Stop)
+}
@@ -0,0 +1,139 @@
+package srvclient
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// newBenchClient returns a SRVClient which answers from memory using
+// handleRequest, so that benchmarks measure the client rather than the network
+func newBenchClient() *SRVClient {
+	sc := &SRVClient{Exchanger: memExchanger(handleRequest)}
+	sc.ResolverAddrs = []string{"127.0.0.1:53"}
+	return sc
+}
+
+func BenchmarkLookupSRV(b *testing.B) {
+	ctx := context.Background()
+	b.Run("skipCache", func(b *testing.B) {
+		sc := newBenchClient()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := sc.lookupSRV(ctx, testHostname, false, true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cacheLast", func(b *testing.B) {
+		sc := newBenchClient()
+		sc.EnableCacheLast()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := sc.lookupSRV(ctx, testHostname, false, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("udp", func(b *testing.B) {
+		sc := &SRVClient{}
+		sc.ResolverAddrs = []string{startTestServer(b, handleRequest)}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := sc.lookupSRV(ctx, testHostname, false, true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkTranslate(b *testing.B) {
+	ctx := context.Background()
+	for _, translate := range []bool{false, true} {
+		b.Run(fmt.Sprintf("translate=%v", translate), func(b *testing.B) {
+			sc := newBenchClient()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := sc.allSRV(ctx, testHostname, translate, true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	b.Run("SRV", func(b *testing.B) {
+		sc := newBenchClient()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := sc.SRVNoCacheContext(ctx, testHostname); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkPickSRV(b *testing.B) {
+	for _, n := range []int{2, 10, 100} {
+		srvs := make([]*dns.SRV, n)
+		for i := range srvs {
+			srvs[i] = newRR(fmt.Sprintf("srv.test. 60 IN SRV %d %d 1000 %d.srv.test.", i%2, i+1, i)).(*dns.SRV)
+		}
+		b.Run(fmt.Sprintf("records=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				pickSRV(srvs)
+			}
+		})
+	}
+}
+
+func BenchmarkSingleInFlight(b *testing.B) {
+	ctx := context.Background()
+	// the exchange takes a little while so that lookups overlap
+	slow := memExchanger(func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(100 * time.Microsecond)
+		handleRequest(w, r)
+	})
+	for _, single := range []bool{false, true} {
+		b.Run(fmt.Sprintf("single=%v", single), func(b *testing.B) {
+			sc := &SRVClient{Exchanger: slow, SingleInFlight: single}
+			sc.ResolverAddrs = []string{"127.0.0.1:53"}
+			b.ReportAllocs()
+			b.SetParallelism(16)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := sc.lookupSRV(ctx, testHostname, false, true); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkClientConfig(b *testing.B) {
+	// many clients looking up concurrently shouldn't contend on the shared
+	// resolv.conf config
+	clients := make([]*SRVClient, 64)
+	for i := range clients {
+		clients[i] = newBenchClient()
+	}
+	var next int64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		sc := clients[int(atomic.AddInt64(&next, 1))%len(clients)]
+		for pb.Next() {
+			if _, _, _, err := sc.clientConfig(); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package srvclient
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Cache stores responses by key, e.g. the last successful response for each
+// hostname as used by EnableCacheLast. It can be set on multiple SRVClients so
+// that they share the same cached responses, and can be backed by an external
+// store like Redis or memcached, in which case the Pack and Unpack methods of
+// dns.Msg can be used to serialize responses. Implementations must be safe for
+// concurrent use, and the responses passed to and returned from them must not
+// be modified.
+type Cache interface {
+	// Get returns the response stored under key, or nil if there isn't one or
+	// it has expired
+	Get(key string) *dns.Msg
+
+	// Set stores the response under key, replacing any previous one. If ttl is
+	// greater than 0 then the response expires after that long.
+	Set(key string, msg *dns.Msg, ttl time.Duration)
+
+	// Delete removes the response stored under key, if there is one
+	Delete(key string)
+}
+
+// The estimated memory overheads of a cached response, beyond its size on the
+// wire, used by MemoryCache to account for its memory usage
+const (
+	cachedMsgOverhead = 256
+	cachedRROverhead  = 64
+)
+
+// cachedSize returns the estimated memory used by caching msg under key
+func cachedSize(key string, msg *dns.Msg) int {
+	n := len(msg.Answer) + len(msg.Ns) + len(msg.Extra)
+	return len(key) + msg.Len() + cachedMsgOverhead + n*cachedRROverhead
+}
+
+type memoryCacheEntry struct {
+	key     string
+	msg     *dns.Msg
+	expires time.Time
+	size    int
+}
+
+func (e *memoryCacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// MemoryCache is a Cache which stores responses in memory, optionally within a
+// budget of bytes. Use NewMemoryCache or NewBoundedMemoryCache to initialize
+// one.
+type MemoryCache struct {
+	maxBytes int
+
+	l     sync.RWMutex
+	m     map[string]*list.Element
+	lru   *list.List
+	bytes int
+}
+
+// NewMemoryCache returns an empty MemoryCache without any limit on its size
+func NewMemoryCache() *MemoryCache {
+	return NewBoundedMemoryCache(0)
+}
+
+// NewBoundedMemoryCache returns an empty MemoryCache which stores at most
+// maxBytes worth of responses, evicting the least recently used ones to stay
+// within it. The memory used by each response is estimated from its size on
+// the wire plus an overhead per record, so it's approximate. Responses which
+// are larger than maxBytes on their own aren't stored. A maxBytes of 0 means
+// no limit.
+func NewBoundedMemoryCache(maxBytes int) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		m:        map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// Get implements the Cache interface
+func (c *MemoryCache) Get(key string) *dns.Msg {
+	now := time.Now()
+	// the order of use is only needed for eviction, so an unbounded cache
+	// doesn't need the write lock
+	if c.maxBytes == 0 {
+		c.l.RLock()
+		el, ok := c.m[key]
+		c.l.RUnlock()
+		// entries are never modified once they're stored
+		if !ok {
+			return nil
+		} else if e := el.Value.(*memoryCacheEntry); !e.expired(now) {
+			return e.msg
+		}
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	el, ok := c.m[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*memoryCacheEntry)
+	if e.expired(now) {
+		c.remove(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return e.msg
+}
+
+// remove removes the element from the cache. The lock must be held.
+func (c *MemoryCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*memoryCacheEntry)
+	delete(c.m, e.key)
+	c.bytes -= e.size
+}
+
+// Set implements the Cache interface
+func (c *MemoryCache) Set(key string, msg *dns.Msg, ttl time.Duration) {
+	e := &memoryCacheEntry{key: key, msg: msg, size: cachedSize(key, msg)}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	if el, ok := c.m[key]; ok {
+		c.remove(el)
+	}
+	if c.maxBytes > 0 && e.size > c.maxBytes {
+		return
+	}
+	c.m[key] = c.lru.PushFront(e)
+	c.bytes += e.size
+	for c.maxBytes > 0 && c.bytes > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Delete implements the Cache interface
+func (c *MemoryCache) Delete(key string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if el, ok := c.m[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Flush removes every response from the cache
+func (c *MemoryCache) Flush() {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.m = map[string]*list.Element{}
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// Len returns the number of responses in the cache, including any which have
+// expired but haven't been retrieved since
+func (c *MemoryCache) Len() int {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	return len(c.m)
+}
+
+// Bytes returns the estimated memory used by the responses in the cache
+func (c *MemoryCache) Bytes() int {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	return c.bytes
+}
@@ -0,0 +1,101 @@
+package srvclient
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedCache(t *testing.T) {
+	var fail int64
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if atomic.LoadInt64(&fail) == 0 {
+			handleRequest(w, r)
+			return
+		}
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+	})
+
+	cache := NewMemoryCache()
+	a := &SRVClient{Cache: cache}
+	a.ResolverAddrs = []string{addr}
+	b := &SRVClient{Cache: cache}
+	b.ResolverAddrs = []string{addr}
+
+	r, err := a.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, r, 2)
+	assert.Equal(t, 1, cache.Len())
+
+	// b gets the response which a cached
+	atomic.StoreInt64(&fail, 1)
+	r, err = b.AllSRV(testHostname)
+	assert.NoError(t, err)
+	assert.Len(t, r, 2)
+	assert.Equal(t, int64(1), b.Stats().CacheLastHits)
+	assert.Zero(t, a.Stats().CacheLastHits)
+	assert.True(t, b.Describe().CacheLast)
+}
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache()
+	assert.Nil(t, c.Get("a"))
+
+	m := new(dns.Msg)
+	c.Set("a", m, 0)
+	assert.Same(t, m, c.Get("a"))
+	c.Delete("a")
+	assert.Nil(t, c.Get("a"))
+
+	c.Set("b", m, 20*time.Millisecond)
+	assert.Same(t, m, c.Get("b"))
+	assert.Equal(t, 1, c.Len())
+	time.Sleep(30 * time.Millisecond)
+	assert.Nil(t, c.Get("b"))
+	assert.Zero(t, c.Len())
+}
+
+func TestBoundedMemoryCache(t *testing.T) {
+	msg := func(n int) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("srv.test.", dns.TypeSRV)
+		for i := 0; i < n; i++ {
+			m.Answer = append(m.Answer, newRR("srv.test. 60 IN SRV 0 0 1000 1.srv.test."))
+		}
+		return m
+	}
+	a, b, c := msg(1), msg(1), msg(1)
+	size := cachedSize("a", a)
+
+	cache := NewBoundedMemoryCache(2 * size)
+	cache.Set("a", a, 0)
+	cache.Set("b", b, 0)
+	assert.Equal(t, 2*size, cache.Bytes())
+
+	// a was used more recently, so b is evicted
+	assert.Same(t, a, cache.Get("a"))
+	cache.Set("c", c, 0)
+	assert.Equal(t, 2, cache.Len())
+	assert.Nil(t, cache.Get("b"))
+	assert.Same(t, a, cache.Get("a"))
+	assert.Same(t, c, cache.Get("c"))
+
+	// replacing an entry accounts for the new size
+	cache.Set("a", b, 0)
+	assert.Equal(t, 2*size, cache.Bytes())
+
+	// a response which doesn't fit at all isn't stored, and replaces the old
+	cache.Set("a", msg(100), 0)
+	assert.Nil(t, cache.Get("a"))
+	assert.Equal(t, size, cache.Bytes())
+
+	cache.Delete("c")
+	assert.Zero(t, cache.Bytes())
+	assert.Zero(t, cache.Len())
+}
@@ -0,0 +1,95 @@
+package srvclient
+
+// Clone returns a new SRVClient with the same configuration as sc, which can
+// then be modified without affecting sc, e.g. to derive per-tenant clients from
+// a template. ResolverAddrs, StandbyResolverAddrs, SearchDomains, StubZones,
+// Overrides and TCPHostnames are copied, but other fields, like TLSConfig,
+// Exchanger, Cache, Events, Dnstap, QueryLog and Picker, are shared. If
+// EnableCacheLast was called on sc then the clone has it enabled too. Nothing
+// else, e.g. the caches, stats and in-flight lookups, is shared.
+func (sc *SRVClient) Clone() *SRVClient {
+	c := &SRVClient{
+		OnExchangeError:        sc.OnExchangeError,
+		OnConfigReload:         sc.OnConfigReload,
+		OnExchange:             sc.OnExchange,
+		UDPSize:                sc.UDPSize,
+		EDNS:                   sc.EDNS,
+		MaxAnswers:             sc.MaxAnswers,
+		MaxResponseSize:        sc.MaxResponseSize,
+		IgnoreTruncated:        sc.IgnoreTruncated,
+		Truncation:             sc.Truncation,
+		MaxUDPSize:             sc.MaxUDPSize,
+		Net:                    sc.Net,
+		ReuseUDPSockets:        sc.ReuseUDPSockets,
+		HardenUDP:              sc.HardenUDP,
+		TLSConfig:              sc.TLSConfig,
+		Exchanger:              sc.Exchanger,
+		Cache:                  sc.Cache,
+		TTLCache:               sc.TTLCache,
+		StaleWhileRefresh:      sc.StaleWhileRefresh,
+		Events:                 sc.Events,
+		Dnstap:                 sc.Dnstap,
+		QueryLog:               sc.QueryLog,
+		Search:                 sc.Search,
+		Ndots:                  sc.Ndots,
+		ServicePrefix:          sc.ServicePrefix,
+		MDNS:                   sc.MDNS,
+		OverridesFile:          sc.OverridesFile,
+		Preprocess:             sc.Preprocess,
+		ValidateRecord:         sc.ValidateRecord,
+		PostProcess:            sc.PostProcess,
+		ResolveTargets:         sc.ResolveTargets,
+		IgnoreExtra:            sc.IgnoreExtra,
+		IPPreference:           sc.IPPreference,
+		UnicodeTargets:         sc.UnicodeTargets,
+		MinAnswers:             sc.MinAnswers,
+		DedupeAnswers:          sc.DedupeAnswers,
+		PriorityClasses:        sc.PriorityClasses,
+		WeightedShuffle:        sc.WeightedShuffle,
+		SingleInFlight:         sc.SingleInFlight,
+		Picker:                 sc.Picker,
+		MaxConcurrentExchanges: sc.MaxConcurrentExchanges,
+		ResolverRateLimit:      sc.ResolverRateLimit,
+		ResolverRateBurst:      sc.ResolverRateBurst,
+		Jitter:                 sc.Jitter,
+		ResolverStrategy:       sc.ResolverStrategy,
+		HedgeDelay:             sc.HedgeDelay,
+		DefaultTimeout:         sc.DefaultTimeout,
+		Rand:                   sc.Rand,
+	}
+	if sc.ResolverAddrs != nil {
+		c.ResolverAddrs = append([]string(nil), sc.ResolverAddrs...)
+	}
+	if sc.StandbyResolverAddrs != nil {
+		c.StandbyResolverAddrs = append([]string(nil), sc.StandbyResolverAddrs...)
+	}
+	if sc.SearchDomains != nil {
+		c.SearchDomains = append([]string(nil), sc.SearchDomains...)
+	}
+	if sc.StubZones != nil {
+		c.StubZones = make(map[string][]string, len(sc.StubZones))
+		for zone, servers := range sc.StubZones {
+			c.StubZones[zone] = append([]string(nil), servers...)
+		}
+	}
+	if sc.Overrides != nil {
+		c.Overrides = make(map[string][]string, len(sc.Overrides))
+		for hostname, addrs := range sc.Overrides {
+			c.Overrides[hostname] = append([]string(nil), addrs...)
+		}
+	}
+	if sc.TCPHostnames != nil {
+		c.TCPHostnames = make(map[string]TCPPolicy, len(sc.TCPHostnames))
+		for hostname, p := range sc.TCPHostnames {
+			c.TCPHostnames[hostname] = p
+		}
+	}
+
+	sc.cacheLastL.RLock()
+	cacheLast := sc.cacheLast != nil
+	sc.cacheLastL.RUnlock()
+	if cacheLast {
+		c.EnableCacheLast()
+	}
+	return c
+}
@@ -0,0 +1,113 @@
+package srvclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClone(t *testing.T) {
+	sc := &SRVClient{
+		OnExchangeError:        func(context.Context, string, string, error) {},
+		OnConfigReload:         func(dns.ClientConfig) {},
+		OnExchange:             func(context.Context, string, string, time.Duration, *dns.Msg, error) {},
+		UDPSize:                1232,
+		EDNS:                   EDNSNoFallback,
+		MaxAnswers:             10,
+		MaxResponseSize:        1000,
+		IgnoreTruncated:        true,
+		Truncation:             TruncationRetryUDP,
+		MaxUDPSize:             4096,
+		TCPHostnames:           map[string]TCPPolicy{"trunc.test": TCPOnly},
+		Net:                    NetTCP,
+		ReuseUDPSockets:        true,
+		HardenUDP:              true,
+		TLSConfig:              new(tls.Config),
+		Exchanger:              new(Replayer),
+		Cache:                  NewMemoryCache(),
+		TTLCache:               true,
+		StaleWhileRefresh:      time.Minute,
+		Events:                 make(chan Event),
+		Dnstap:                 new(DnstapLogger),
+		QueryLog:               new(bytes.Buffer),
+		ResolverAddrs:          []string{"127.0.0.1:53"},
+		StandbyResolverAddrs:   []string{"127.0.0.3:53"},
+		StubZones:              map[string][]string{"consul": {"127.0.0.1:8600"}},
+		Search:                 true,
+		SearchDomains:          []string{"test"},
+		Ndots:                  2,
+		ServicePrefix:          "_grpc._tcp",
+		MDNS:                   true,
+		Overrides:              map[string][]string{"srv.test": {"10.0.0.1:80"}},
+		OverridesFile:          "/etc/srv-overrides",
+		Preprocess:             func(*dns.Msg) {},
+		ValidateRecord:         ValidSRV,
+		PostProcess:            func(srvs []*dns.SRV) []*dns.SRV { return srvs },
+		ResolveTargets:         true,
+		IgnoreExtra:            true,
+		IPPreference:           PreferIPv6,
+		UnicodeTargets:         true,
+		MinAnswers:             2,
+		DedupeAnswers:          true,
+		PriorityClasses:        1,
+		WeightedShuffle:        true,
+		SingleInFlight:         true,
+		Picker:                 new(LocalityPicker),
+		MaxConcurrentExchanges: 5,
+		ResolverRateLimit:      100,
+		ResolverRateBurst:      10,
+		Jitter:                 0.1,
+		ResolverStrategy:       StrategyHedged,
+		HedgeDelay:             time.Second,
+		DefaultTimeout:         time.Second,
+		Rand:                   rand.New(rand.NewSource(1)),
+	}
+	sc.EnableCacheLast()
+	sc.cacheLast["srv.test."] = new(dns.Msg)
+	sc.numUDPQueries = 5
+
+	c := sc.Clone()
+	v, cv := reflect.ValueOf(sc).Elem(), reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		// every field must be set above, so that new fields get tested
+		require.False(t, v.Field(i).IsZero(), "%s isn't set", f.Name)
+		if f.Type.Kind() == reflect.Func {
+			assert.Equal(t, v.Field(i).Pointer(), cv.Field(i).Pointer(), f.Name)
+		} else {
+			assert.Equal(t, v.Field(i).Interface(), cv.Field(i).Interface(), f.Name)
+		}
+	}
+
+	// the slices and maps were copied
+	c.ResolverAddrs[0] = "127.0.0.2:53"
+	c.SearchDomains[0] = "other"
+	c.StandbyResolverAddrs[0] = "127.0.0.4:53"
+	c.StubZones["consul"][0] = "127.0.0.2:8600"
+	c.TCPHostnames["trunc.test"] = TCPRace
+	c.Overrides["srv.test"][0] = "10.0.0.2:80"
+	assert.Equal(t, "127.0.0.1:53", sc.ResolverAddrs[0])
+	assert.Equal(t, "test", sc.SearchDomains[0])
+	assert.Equal(t, "127.0.0.3:53", sc.StandbyResolverAddrs[0])
+	assert.Equal(t, "127.0.0.1:8600", sc.StubZones["consul"][0])
+	assert.Equal(t, TCPOnly, sc.TCPHostnames["trunc.test"])
+	assert.Equal(t, "10.0.0.1:80", sc.Overrides["srv.test"][0])
+
+	// the cache is enabled but empty, and the stats are reset
+	assert.NotNil(t, c.cacheLast)
+	assert.Empty(t, c.cacheLast)
+	assert.Zero(t, c.Stats().UDPQueries)
+
+	assert.Nil(t, new(SRVClient).Clone().cacheLast)
+}
@@ -0,0 +1,121 @@
+package srvclient
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxCNAMEChain is the longest chain of CNAMEs which will be followed, so that
+// loops can't cause problems
+const maxCNAMEChain = 8
+
+// sameName returns whether the two domain names are the same, ignoring case
+// and whether they're fully qualified
+func sameName(a, b string) bool {
+	return a == b || dns.CanonicalName(a) == dns.CanonicalName(b)
+}
+
+// followCNAMEs returns the name which the given name is an alias for according
+// to the CNAME records in rrs, following chains of them. If there is no CNAME
+// for name then it's returned as-is. Names are compared case-insensitively,
+// since resolvers can change their case.
+func followCNAMEs(name string, rrs []dns.RR) string {
+	for i := 0; i < maxCNAMEChain; i++ {
+		next := ""
+		for _, rr := range rrs {
+			if cname, ok := rr.(*dns.CNAME); ok && sameName(cname.Hdr.Name, name) {
+				next = cname.Target
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return name
+}
+
+// addrsFor returns the IPs from the A and AAAA records for name in rrs, after
+// following any CNAMEs for it
+func addrsFor(name string, rrs []dns.RR) []net.IP {
+	name = followCNAMEs(name, rrs)
+	var ips []net.IP
+	for _, rr := range rrs {
+		if a, ok := rr.(*dns.A); ok && sameName(a.Hdr.Name, name) {
+			ips = append(ips, a.A)
+		} else if aaaa, ok := rr.(*dns.AAAA); ok && sameName(aaaa.Hdr.Name, name) {
+			ips = append(ips, aaaa.AAAA)
+		}
+	}
+	return ips
+}
+
+// lookupTargetIPs performs follow-up A and AAAA queries for the target of a SRV
+// record, following any CNAMEs in their responses. Concurrent follow-ups for the
+// same target are always combined, regardless of SingleInFlight, since many
+// lookups of a hostname will generally share the same targets.
+func (sc *SRVClient) lookupTargetIPs(ctx context.Context, target string, skipCache bool) []net.IP {
+	var ips []net.IP
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg, _, _ := sc.lookupMsgInFlight(ctx, target, qtype, skipCache, true)
+		if msg == nil || len(msg.Question) == 0 {
+			continue
+		}
+		ips = append(ips, addrsFor(msg.Question[0].Name, msg.Answer)...)
+	}
+	return ips
+}
+
+// shouldResolveTargets returns whether follow-up queries should be made for SRV
+// targets which a response didn't include any IPs for
+func (sc *SRVClient) shouldResolveTargets() bool {
+	return sc.ResolveTargets || sc.IgnoreExtra
+}
+
+// extra returns the records in the additional section of m which IPs of SRV
+// targets can be taken from, which is none if IgnoreExtra is set
+func (sc *SRVClient) extra(m *dns.Msg) []dns.RR {
+	if sc.IgnoreExtra {
+		return nil
+	}
+	return m.Extra
+}
+
+// targetIPsLookup returns a function which calls lookupTargetIPs, but only once
+// for each target, since an answer can contain the same target multiple times
+// with different ports
+func (sc *SRVClient) targetIPsLookup(ctx context.Context, skipCache bool) func(target string) []net.IP {
+	looked := map[string][]net.IP{}
+	return func(target string) []net.IP {
+		key := strings.ToLower(target)
+		ips, ok := looked[key]
+		if !ok {
+			ips = sc.lookupTargetIPs(ctx, target, skipCache)
+			looked[key] = ips
+		}
+		return ips
+	}
+}
+
+// resolveTargets replaces the targets of any records which aren't IPs with the
+// results of lookupTargetIPs, if ResolveTargets or IgnoreExtra is set
+func (sc *SRVClient) resolveTargets(ctx context.Context, ans []*dns.SRV, skipCache bool) {
+	if !sc.shouldResolveTargets() {
+		return
+	}
+	lookup := sc.targetIPsLookup(ctx, skipCache)
+	for i, srv := range ans {
+		if net.ParseIP(srv.Target) != nil {
+			continue
+		}
+		if ips := orderIPs(lookup(srv.Target), sc.IPPreference); len(ips) > 0 {
+			srv = dns.Copy(srv).(*dns.SRV)
+			srv.Target = ips[0].String()
+			ans[i] = srv
+		}
+	}
+}
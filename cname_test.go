@@ -0,0 +1,169 @@
+package srvclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowCNAMEs(t *testing.T) {
+	rrs := []dns.RR{
+		newRR("a.test. 60 IN CNAME b.test."),
+		newRR("b.test. 60 IN CNAME c.test."),
+		newRR("c.test. 60 IN A 10.0.0.1"),
+		newRR("loop.test. 60 IN CNAME loop.test."),
+	}
+	assert.Equal(t, "c.test.", followCNAMEs("a.test.", rrs))
+	assert.Equal(t, "other.test.", followCNAMEs("other.test.", rrs))
+	assert.Equal(t, "loop.test.", followCNAMEs("loop.test.", rrs))
+	assert.Equal(t, "10.0.0.1", addrsFor("a.test.", rrs)[0].String())
+
+	// names are compared case-insensitively, with or without the trailing dot
+	assert.Equal(t, "c.test.", followCNAMEs("A.Test.", rrs))
+	require.Len(t, addrsFor("A.TEST", rrs), 1)
+	rrs = []dns.RR{newRR("Target.Test. 60 IN A 10.0.0.2")}
+	srv := newRR("srv.test. 60 IN SRV 0 0 1000 target.test.").(*dns.SRV)
+	assert.Equal(t, "10.0.0.2", replaceSRVTarget(srv, rrs, PreferFirst).Target)
+}
+
+func TestCNAMETargets(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Name == "cname.test." && q.Qtype == dns.TypeSRV:
+			m.Answer = []dns.RR{
+				newRR("cname.test. 60 IN SRV 0 0 1000 alias.test."),
+				newRR("cname.test. 60 IN SRV 1 0 1001 other.test."),
+			}
+			m.Extra = []dns.RR{
+				newRR("alias.test. 60 IN CNAME real.test."),
+				newRR("real.test. 60 IN A 10.0.0.1"),
+			}
+		case q.Name == "other.test." && q.Qtype == dns.TypeA:
+			m.Answer = []dns.RR{
+				newRR("other.test. 60 IN CNAME other-real.test."),
+				newRR("other-real.test. 60 IN A 10.0.0.2"),
+			}
+		}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	srvs, err := client.AllSRVTranslate("cname.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:1000", "other.test.:1001"}, srvs)
+
+	client.ResolveTargets = true
+	srvs, err = client.AllSRVTranslate("cname.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:1000", "10.0.0.2:1001"}, srvs)
+
+	// the cached response must not have been modified by the translation
+	srvs, err = client.AllSRV("cname.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alias.test.:1000", "other.test.:1001"}, srvs)
+
+	res, err := client.LookupSRV("cname.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", res.Records[1].IPs[0].String())
+}
+
+func TestIgnoreExtra(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Name == "glue.test." && q.Qtype == dns.TypeSRV:
+			m.Answer = []dns.RR{newRR("glue.test. 60 IN SRV 0 0 1000 target.test.")}
+			m.Extra = []dns.RR{newRR("target.test. 60 IN A 10.0.0.1")}
+		case q.Name == "target.test." && q.Qtype == dns.TypeA:
+			m.Answer = []dns.RR{newRR("target.test. 60 IN A 10.0.0.2")}
+		}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	srvs, err := client.AllSRVTranslate("glue.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:1000"}, srvs)
+
+	client.IgnoreExtra = true
+	srvs, err = client.AllSRVTranslate("glue.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:1000"}, srvs)
+
+	res, err := client.LookupSRV("glue.test")
+	require.NoError(t, err)
+	require.Len(t, res.Records, 1)
+	assert.Equal(t, "10.0.0.2", res.Records[0].IPs[0].String())
+}
+
+func TestCoalesceFollowups(t *testing.T) {
+	var l sync.Mutex
+	aQueries := map[string]int{}
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		switch q.Qtype {
+		case dns.TypeSRV:
+			for i := 0; i < 50; i++ {
+				m.Answer = append(m.Answer, newRR(fmt.Sprintf("many.test. 60 IN SRV 0 0 %d t%d.test.", 1000+i, i%5)))
+			}
+		case dns.TypeA:
+			l.Lock()
+			aQueries[q.Name]++
+			l.Unlock()
+			// give concurrent lookups a chance to coalesce
+			time.Sleep(20 * time.Millisecond)
+			m.Answer = []dns.RR{newRR(q.Name + " 60 IN A 10.0.0.1")}
+		}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{ResolveTargets: true}
+	client.ResolverAddrs = []string{addr}
+
+	// duplicate targets within a single answer are only resolved once
+	srvs, err := client.AllSRVTranslateContext(WithNoCache(context.Background()), "many.test")
+	require.NoError(t, err)
+	assert.Len(t, srvs, 50)
+	l.Lock()
+	assert.Len(t, aQueries, 5)
+	for name, n := range aQueries {
+		assert.Equal(t, 1, n, name)
+	}
+	aQueries = map[string]int{}
+	l.Unlock()
+
+	// and concurrent translations share their follow-ups, even without
+	// SingleInFlight
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srvs, err := client.AllSRVTranslateContext(WithNoCache(context.Background()), "many.test")
+			assert.NoError(t, err)
+			assert.Len(t, srvs, 50)
+		}()
+	}
+	wg.Wait()
+	l.Lock()
+	defer l.Unlock()
+	assert.Len(t, aQueries, 5)
+	for name, n := range aQueries {
+		assert.Less(t, n, 10, name)
+	}
+}
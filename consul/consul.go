@@ -0,0 +1,79 @@
+// Package consul provides helpers for looking up services using Consul's DNS
+// interface, as described at https://developer.hashicorp.com/consul/docs/services/discovery/dns-static-lookups
+package consul
+
+import (
+	"context"
+	"strings"
+
+	"github.com/levenlabs/go-srvclient"
+)
+
+// DefaultAgentAddr is the address of the DNS interface of a local Consul agent
+// with the default configuration
+const DefaultAgentAddr = "127.0.0.1:8600"
+
+// DefaultDomain is the domain Consul serves by default
+const DefaultDomain = "consul"
+
+// Client looks up Consul services using a SRVClient
+type Client struct {
+	// SRVClient is used for all lookups
+	SRVClient *srvclient.SRVClient
+
+	// Domain is the domain Consul is serving. Defaults to DefaultDomain.
+	Domain string
+
+	// Datacenter, if set, is the datacenter services are looked up in, rather
+	// than the agent's
+	Datacenter string
+}
+
+// New returns a Client which queries the Consul agent at the given address,
+// or DefaultAgentAddr if it's empty
+func New(agentAddr string) *Client {
+	if agentAddr == "" {
+		agentAddr = DefaultAgentAddr
+	}
+	return &Client{
+		SRVClient: &srvclient.SRVClient{ResolverAddrs: []string{agentAddr}},
+	}
+}
+
+// Name returns the name to look up for the given service, in the RFC 2782 style
+// of "_service._tag.service[.datacenter].domain". If tag is empty then the
+// instances aren't filtered by tag.
+func (c *Client) Name(service, tag string) string {
+	if tag == "" {
+		// consul treats the tcp protocol as meaning no tag
+		tag = "tcp"
+	}
+	parts := []string{"_" + service, "_" + tag, "service"}
+	if c.Datacenter != "" {
+		parts = append(parts, c.Datacenter)
+	}
+	domain := c.Domain
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	parts = append(parts, strings.Trim(domain, "."))
+	return strings.Join(parts, ".")
+}
+
+// SRV returns the address ("ip:port") of a single instance of the service which
+// has the given tag, or any instance if tag is empty
+func (c *Client) SRV(ctx context.Context, service, tag string) (string, error) {
+	return c.SRVClient.SRVContext(ctx, c.Name(service, tag))
+}
+
+// AllSRV returns the addresses ("ip:port") of all instances of the service
+// which have the given tag, or of all instances if tag is empty
+func (c *Client) AllSRV(ctx context.Context, service, tag string) ([]string, error) {
+	return c.SRVClient.AllSRVTranslateContext(ctx, c.Name(service, tag))
+}
+
+// Lookup returns the full result of looking up the instances of the service
+// which have the given tag, or all instances if tag is empty
+func (c *Client) Lookup(ctx context.Context, service, tag string) (*srvclient.Result, error) {
+	return c.SRVClient.LookupSRVContext(ctx, c.Name(service, tag))
+}
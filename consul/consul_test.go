@@ -0,0 +1,47 @@
+package consul
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/levenlabs/go-srvclient/srvclienttest"
+)
+
+func TestName(t *testing.T) {
+	c := New("")
+	assert.Equal(t, []string{DefaultAgentAddr}, c.SRVClient.ResolverAddrs)
+	assert.Equal(t, "_web._tcp.service.consul", c.Name("web", ""))
+	assert.Equal(t, "_web._v2.service.consul", c.Name("web", "v2"))
+
+	c.Datacenter = "dc2"
+	c.Domain = "example.test."
+	assert.Equal(t, "_web._v2.service.dc2.example.test", c.Name("web", "v2"))
+}
+
+func TestClient(t *testing.T) {
+	s, sc := srvclienttest.New(t)
+	s.AddSRV("_web._tcp.service.consul", "node1.node.dc1.consul", 8000, 1, 1)
+	s.AddSRV("_web._tcp.service.consul", "node2.node.dc1.consul", 8001, 1, 1)
+	s.AddSRV("_web._v2.service.consul", "node2.node.dc1.consul", 8001, 1, 1)
+	s.AddA("node1.node.dc1.consul", "10.0.0.1")
+	s.AddA("node2.node.dc1.consul", "10.0.0.2")
+
+	c := New(s.Addr)
+	c.SRVClient = sc
+	ctx := context.Background()
+
+	addrs, err := c.AllSRV(ctx, "web", "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"10.0.0.1:8000", "10.0.0.2:8001"}, addrs)
+
+	addr, err := c.SRV(ctx, "web", "v2")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:8001", addr)
+
+	res, err := c.Lookup(ctx, "web", "v2")
+	require.NoError(t, err)
+	assert.Len(t, res.Records, 1)
+}
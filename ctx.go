@@ -2,6 +2,7 @@ package srvclient
 
 import (
 	"context"
+	"sync"
 	"time"
 )
 
@@ -34,3 +35,79 @@ func (withoutCancel) Done() <-chan struct{} {
 func (withoutCancel) Deadline() (time.Time, bool) {
 	return emptyTime, false
 }
+
+type resolversKey struct{}
+
+// WithResolvers returns a context which causes lookups using it to query the
+// given resolvers ("ip:port"), rather than the SRVClient's normal ones or any
+// StubZones, e.g. to resolve a request against a tenant-specific server
+func WithResolvers(ctx context.Context, resolvers []string) context.Context {
+	return context.WithValue(ctx, resolversKey{}, append([]string(nil), resolvers...))
+}
+
+func resolversFromContext(ctx context.Context) []string {
+	resolvers, _ := ctx.Value(resolversKey{}).([]string)
+	return resolvers
+}
+
+type noCacheKey struct{}
+
+// WithNoCache returns a context which causes lookups using it to skip any
+// caching, like the NoCache methods, e.g. to force a refresh
+func WithNoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func noCacheFromContext(ctx context.Context) bool {
+	noCache, _ := ctx.Value(noCacheKey{}).(bool)
+	return noCache
+}
+
+// AnswerSource records where the answers used by lookups came from, see
+// WithAnswerSource
+type AnswerSource struct {
+	l        sync.Mutex
+	resolver string
+	cached   bool
+}
+
+// Resolver returns the address of the resolver which answered the most recent
+// lookup made using the context, or an empty string if there wasn't one or if
+// the last successful response was used instead, in which case Cached returns
+// true. Follow-up queries for the IPs of the targets aren't included.
+func (s *AnswerSource) Resolver() string {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.resolver
+}
+
+// Cached returns true if the most recent lookup made using the context used the
+// last successful response, because the query failed, rather than one from a
+// resolver
+func (s *AnswerSource) Cached() bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.cached
+}
+
+func (s *AnswerSource) set(resolver string) {
+	s.l.Lock()
+	s.resolver, s.cached = resolver, resolver == ""
+	s.l.Unlock()
+}
+
+type answerSourceKey struct{}
+
+// WithAnswerSource returns a context which causes the resolver which answered
+// lookups using it to be recorded in the returned AnswerSource. This is meant
+// for debugging which resolver produced a stale or wrong answer from methods
+// like SRV which only return addresses, the Result of LookupSRV already has it.
+func WithAnswerSource(ctx context.Context) (context.Context, *AnswerSource) {
+	s := new(AnswerSource)
+	return context.WithValue(ctx, answerSourceKey{}, s), s
+}
+
+func answerSourceFromContext(ctx context.Context) *AnswerSource {
+	s, _ := ctx.Value(answerSourceKey{}).(*AnswerSource)
+	return s
+}
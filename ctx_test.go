@@ -2,9 +2,12 @@ package srvclient
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -57,3 +60,88 @@ func TestWithoutCancel(t *testing.T) {
 
 	middleFn()
 }
+
+func TestWithResolvers(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = []string{closedAddr(t)}
+	_, err := client.SRV(testHostname)
+	assert.Error(t, err)
+
+	ctx := WithResolvers(context.Background(), DefaultSRVClient.ResolverAddrs[:1])
+	srvs, err := client.AllSRVContext(ctx, testHostname)
+	require.NoError(t, err)
+	assert.Len(t, srvs, 2)
+}
+
+func TestWithNoCache(t *testing.T) {
+	client := SRVClient{}
+	client.EnableCacheLast()
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	_, err := client.SRV(testHostname)
+	require.NoError(t, err)
+
+	client.ResolverAddrs = []string{closedAddr(t)}
+	client.lastConfig.updated = time.Time{}
+	// the cached response is returned along with the error
+	addr, err := client.SRV(testHostname)
+	assert.Error(t, err)
+	assert.NotEmpty(t, addr)
+	assert.EqualValues(t, 1, client.Stats().CacheLastHits)
+
+	addr, err = client.SRVContext(WithNoCache(context.Background()), testHostname)
+	assert.Error(t, err)
+	assert.Empty(t, addr)
+	assert.EqualValues(t, 1, client.Stats().CacheLastHits)
+}
+
+func TestDeadline(t *testing.T) {
+	// a server which never responds
+	addr := startTestServer(t, func(dns.ResponseWriter, *dns.Msg) {})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.SRVContext(ctx, testHostname)
+	assert.True(t, errors.Is(err, &ErrTimeout{}))
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, _, cfg, err := client.clientConfig()
+	require.NoError(t, err)
+	cfg.Timeout = 1
+	actx, cancel := client.attemptContext(context.Background(), cfg)
+	defer cancel()
+	deadline, ok := actx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+}
+
+func TestWithAnswerSource(t *testing.T) {
+	var fail int32
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if atomic.LoadInt32(&fail) == 1 {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeServerFailure)
+			w.WriteMsg(m)
+			return
+		}
+		handleRequest(w, r)
+	})
+	client := SRVClient{}
+	client.ResolverAddrs = []string{closedAddr(t), addr}
+	client.EnableCacheLast()
+
+	ctx, src := WithAnswerSource(context.Background())
+	assert.Empty(t, src.Resolver())
+	_, err := client.SRVContext(ctx, testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, addr, src.Resolver())
+	assert.False(t, src.Cached())
+
+	atomic.StoreInt32(&fail, 1)
+	_, err = client.SRVContext(ctx, testHostname)
+	require.NoError(t, err)
+	assert.Empty(t, src.Resolver())
+	assert.True(t, src.Cached())
+}
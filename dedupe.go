@@ -0,0 +1,49 @@
+package srvclient
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// dedupeSRVs merges the records with the same target and port, as described by
+// DedupeAnswers, keeping the order in which each first appeared. The merged
+// records are copies, since the originals may be cached, and srvs is reused
+// for the result.
+func dedupeSRVs(srvs []*dns.SRV) []*dns.SRV {
+	if len(srvs) < 2 {
+		return srvs
+	}
+	idx := make(map[string]int, len(srvs))
+	copied := map[int]bool{}
+	out := srvs[:0]
+	for _, srv := range srvs {
+		key := strings.ToLower(srv.Target) + ":" + strconv.Itoa(int(srv.Port))
+		i, ok := idx[key]
+		if !ok {
+			idx[key] = len(out)
+			out = append(out, srv)
+			continue
+		}
+
+		merged := out[i]
+		if !copied[i] {
+			merged = dns.Copy(merged).(*dns.SRV)
+			copied[i] = true
+		}
+		if srv.Priority < merged.Priority {
+			merged.Priority = srv.Priority
+		}
+		if w := int(merged.Weight) + int(srv.Weight); w > 0xffff {
+			merged.Weight = 0xffff
+		} else {
+			merged.Weight = uint16(w)
+		}
+		out[i] = merged
+	}
+	for i := len(out); i < len(srvs); i++ {
+		srvs[i] = nil
+	}
+	return out
+}
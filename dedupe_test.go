@@ -0,0 +1,65 @@
+package srvclient
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupeSRVs(t *testing.T) {
+	a := newRR("srv.test. 60 IN SRV 1 10 1000 a.srv.test.").(*dns.SRV)
+	srvs := []*dns.SRV{
+		a,
+		newRR("srv.test. 60 IN SRV 0 10 1000 b.srv.test.").(*dns.SRV),
+		newRR("srv.test. 60 IN SRV 0 20 1000 A.srv.test.").(*dns.SRV),
+		newRR("srv.test. 60 IN SRV 0 10 1001 a.srv.test.").(*dns.SRV),
+		newRR("srv.test. 60 IN SRV 1 65530 1000 a.srv.test.").(*dns.SRV),
+	}
+	out := dedupeSRVs(srvs)
+	require.Len(t, out, 3)
+	assert.Equal(t, "a.srv.test.", out[0].Target)
+	assert.Equal(t, uint16(0), out[0].Priority)
+	assert.Equal(t, uint16(0xffff), out[0].Weight)
+	assert.Equal(t, "b.srv.test.", out[1].Target)
+	assert.Equal(t, uint16(1001), out[2].Port)
+
+	// the original record wasn't modified
+	assert.Equal(t, uint16(1), a.Priority)
+	assert.Equal(t, uint16(10), a.Weight)
+}
+
+func TestDedupeAnswers(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{
+			newRR("srv.test. 60 IN SRV 0 1 1000 1.srv.test."),
+			newRR("srv.test. 60 IN SRV 0 1 1000 1.srv.test."),
+			newRR("srv.test. 60 IN SRV 0 1 1001 2.srv.test."),
+		}
+		m.Extra = []dns.RR{newRR("1.srv.test. 60 IN A 10.0.0.1")}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	r, err := client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, r, 3)
+
+	client.DedupeAnswers = true
+	r, err = client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.srv.test.:1000", "2.srv.test.:1001"}, r)
+
+	r, err = client.AllSRVTranslate(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:1000", "2.srv.test.:1001"}, r)
+
+	res, err := client.LookupSRV(testHostname)
+	require.NoError(t, err)
+	require.Len(t, res.Records, 2)
+	assert.Equal(t, uint16(2), res.Records[0].Weight)
+}
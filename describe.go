@@ -0,0 +1,174 @@
+package srvclient
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Description describes the effective configuration of a SRVClient, e.g. for
+// including in debug output
+type Description struct {
+	// Resolvers are the resolvers currently being used, and ConfigSource is
+	// where they came from: "ResolverAddrs", ResolversEnv or /etc/resolv.conf
+	Resolvers    []string `json:"resolvers"`
+	ConfigSource string   `json:"configSource"`
+
+	// StandbyResolvers are the StandbyResolverAddrs
+	StandbyResolvers []string `json:"standbyResolvers,omitempty"`
+
+	// LastReload is when the configuration was last loaded from
+	// /etc/resolv.conf
+	LastReload time.Time `json:"lastReload,omitempty"`
+
+	// Error is set if the configuration couldn't be loaded
+	Error string `json:"error,omitempty"`
+
+	// OverridesFileError is set if the last attempt to load OverridesFile
+	// failed
+	OverridesFile      string `json:"overridesFile,omitempty"`
+	OverridesFileError string `json:"overridesFileError,omitempty"`
+
+	// SearchDomains and Ndots are the search options used, if Search is set
+	SearchDomains []string `json:"searchDomains,omitempty"`
+	Ndots         int      `json:"ndots,omitempty"`
+
+	ServicePrefix string `json:"servicePrefix,omitempty"`
+
+	StubZones      map[string][]string `json:"stubZones,omitempty"`
+	Overrides      map[string][]string `json:"overrides,omitempty"`
+	TCPHostnames   map[string]string   `json:"tcpHostnames,omitempty"`
+	Net            string              `json:"net"`
+	Timeout        time.Duration       `json:"timeout"`
+	DefaultTimeout time.Duration       `json:"defaultTimeout,omitempty"`
+	UDPSize        uint16              `json:"udpSize"`
+	MaxUDPSize     uint16              `json:"maxUDPSize,omitempty"`
+	EDNS           string              `json:"edns"`
+	CacheLast      bool                `json:"cacheLast"`
+	SingleInFlight bool                `json:"singleInFlight"`
+
+	// StaleWhileRefresh is set to the effective StaleWhileRefresh if TTLCache
+	// is set
+	TTLCache          bool          `json:"ttlCache,omitempty"`
+	StaleWhileRefresh time.Duration `json:"staleWhileRefresh,omitempty"`
+
+	Truncation             string        `json:"truncation"`
+	DedupeAnswers          bool          `json:"dedupeAnswers,omitempty"`
+	WeightedShuffle        bool          `json:"weightedShuffle,omitempty"`
+	IgnoreExtra            bool          `json:"ignoreExtra,omitempty"`
+	MDNS                   bool          `json:"mdns,omitempty"`
+	ReuseUDPSockets        bool          `json:"reuseUDPSockets,omitempty"`
+	HardenUDP              bool          `json:"hardenUDP,omitempty"`
+	MaxConcurrentExchanges int           `json:"maxConcurrentExchanges,omitempty"`
+	ResolverRateLimit      float64       `json:"resolverRateLimit,omitempty"`
+	ResolverRateBurst      int           `json:"resolverRateBurst,omitempty"`
+	Jitter                 float64       `json:"jitter,omitempty"`
+	ResolverStrategy       string        `json:"resolverStrategy"`
+	HedgeDelay             time.Duration `json:"hedgeDelay,omitempty"`
+	MaxAnswers             int           `json:"maxAnswers,omitempty"`
+	MinAnswers             int           `json:"minAnswers,omitempty"`
+	PriorityClasses        int           `json:"priorityClasses,omitempty"`
+	MaxResponseSize        int           `json:"maxResponseSize,omitempty"`
+
+	Stats SRVStats `json:"stats"`
+}
+
+// Describe returns a Description of the SRVClient's effective configuration,
+// loading it if the SRVClient hasn't been used yet
+func (sc *SRVClient) Describe() Description {
+	d := Description{
+		StubZones:              sc.StubZones,
+		Overrides:              sc.Overrides,
+		OverridesFile:          sc.OverridesFile,
+		ServicePrefix:          sc.ServicePrefix,
+		Net:                    sc.Net,
+		DefaultTimeout:         sc.DefaultTimeout,
+		UDPSize:                sc.UDPSize,
+		MaxUDPSize:             sc.MaxUDPSize,
+		EDNS:                   sc.EDNS.String(),
+		SingleInFlight:         sc.SingleInFlight,
+		TTLCache:               sc.TTLCache,
+		Truncation:             sc.truncation().String(),
+		DedupeAnswers:          sc.DedupeAnswers,
+		WeightedShuffle:        sc.WeightedShuffle,
+		IgnoreExtra:            sc.IgnoreExtra,
+		MDNS:                   sc.MDNS,
+		ReuseUDPSockets:        sc.ReuseUDPSockets,
+		HardenUDP:              sc.HardenUDP,
+		MaxConcurrentExchanges: sc.MaxConcurrentExchanges,
+		ResolverRateLimit:      sc.ResolverRateLimit,
+		ResolverRateBurst:      sc.ResolverRateBurst,
+		Jitter:                 sc.Jitter,
+		ResolverStrategy:       sc.ResolverStrategy.String(),
+		MaxAnswers:             sc.MaxAnswers,
+		MinAnswers:             sc.MinAnswers,
+		PriorityClasses:        sc.PriorityClasses,
+		MaxResponseSize:        sc.MaxResponseSize,
+		Stats:                  sc.Stats(),
+	}
+	if d.Net == "" {
+		d.Net = NetUDP
+	}
+	if sc.TTLCache {
+		d.StaleWhileRefresh = sc.staleWhileRefresh()
+	}
+	if sc.ResolverStrategy == StrategyHedged {
+		d.HedgeDelay = sc.hedgeDelay()
+	}
+	if len(sc.TCPHostnames) > 0 {
+		d.TCPHostnames = make(map[string]string, len(sc.TCPHostnames))
+		for hostname, p := range sc.TCPHostnames {
+			d.TCPHostnames[hostname] = p.String()
+		}
+	}
+	if err := sc.overridesFileErr(); err != nil {
+		d.OverridesFileError = err.Error()
+	}
+	if d.UDPSize == 0 {
+		d.UDPSize = dns.DefaultMsgSize
+	}
+
+	sc.cacheLastL.RLock()
+	d.CacheLast = sc.cacheLast != nil || sc.Cache != nil
+	sc.cacheLastL.RUnlock()
+
+	switch {
+	case len(sc.ResolverAddrs) > 0:
+		d.ConfigSource = "ResolverAddrs"
+	case len(defaultResolvers()) > 0:
+		d.ConfigSource = ResolversEnv
+	default:
+		d.ConfigSource = resolvFile
+	}
+
+	_, _, cfg, err := sc.clientConfig()
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Resolvers = append([]string(nil), cfg.Servers...)
+	if standby := sc.standbyResolvers(); len(standby) > 0 {
+		d.StandbyResolvers = append([]string(nil), standby...)
+	}
+	d.Timeout = time.Duration(cfg.Timeout) * time.Second
+	if sc.Search {
+		d.SearchDomains, d.Ndots = sc.SearchDomains, sc.Ndots
+		if len(d.SearchDomains) == 0 {
+			d.SearchDomains = cfg.Search
+		}
+		if d.Ndots == 0 {
+			d.Ndots = cfg.Ndots
+		}
+	}
+	sc.clientConfigL.RLock()
+	d.LastReload = sc.lastConfig.updated
+	sc.clientConfigL.RUnlock()
+	return d
+}
+
+// MarshalJSON implements the json.Marshaler interface by encoding the
+// SRVClient's Description
+func (sc *SRVClient) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sc.Describe())
+}
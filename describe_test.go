@@ -0,0 +1,36 @@
+package srvclient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribe(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	client.SingleInFlight = true
+	client.EnableCacheLast()
+	_, err := client.SRV(testHostname)
+	require.NoError(t, err)
+
+	d := client.Describe()
+	assert.Equal(t, client.ResolverAddrs, d.Resolvers)
+	assert.Equal(t, "ResolverAddrs", d.ConfigSource)
+	assert.Empty(t, d.Error)
+	assert.False(t, d.LastReload.IsZero())
+	assert.Equal(t, NetUDP, d.Net)
+	assert.Equal(t, "fallback", d.EDNS)
+	assert.True(t, d.CacheLast)
+	assert.True(t, d.SingleInFlight)
+	assert.EqualValues(t, 1, d.Stats.UDPQueries)
+
+	b, err := json.Marshal(&client)
+	require.NoError(t, err)
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &m))
+	assert.Equal(t, []interface{}{client.ResolverAddrs[0]}, m["resolvers"])
+	assert.Equal(t, true, m["cacheLast"])
+}
@@ -0,0 +1,206 @@
+package srvclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ConfigureResolver configures r, e.g. net.DefaultResolver, so that the lookups
+// made with it use the SRVClient's resolvers and transports, via DialDNS. This
+// lets a whole program use the same upstreams and DNS over TLS/HTTPS settings.
+func (sc *SRVClient) ConfigureResolver(r *net.Resolver) {
+	r.PreferGo = true
+	r.Dial = sc.DialDNS
+}
+
+// NetResolver returns a new net.Resolver configured with ConfigureResolver
+func (sc *SRVClient) NetResolver() *net.Resolver {
+	r := new(net.Resolver)
+	sc.ConfigureResolver(r)
+	return r
+}
+
+// DialDNS can be used as the Dial function of a net.Resolver with PreferGo set.
+// Rather than connecting to address it returns a connection which answers the
+// queries written to it by making them with the SRVClient, the same as Query.
+// network determines whether the connection behaves like a "udp" or "tcp" one.
+func (sc *SRVClient) DialDNS(ctx context.Context, network, address string) (net.Conn, error) {
+	c := &dnsConn{sc: sc, ctx: ctx, network: network}
+	switch network {
+	case "udp", "udp4", "udp6":
+		return &dnsPacketConn{c}, nil
+	case "tcp", "tcp4", "tcp6":
+		return c, nil
+	}
+	return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+}
+
+var dnsConnAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+
+// dnsConn is the net.Conn returned from DialDNS. When used as a stream the
+// messages are prefixed with their length, like over tcp.
+type dnsConn struct {
+	sc      *SRVClient
+	ctx     context.Context
+	network string
+
+	l        sync.Mutex
+	in, out  bytes.Buffer
+	deadline time.Time
+	closed   bool
+}
+
+func (c *dnsConn) stream() bool {
+	return c.network[:3] == "tcp"
+}
+
+// Write implements the net.Conn interface. Each complete message written is
+// answered before Write returns.
+func (c *dnsConn) Write(b []byte) (int, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+
+	if !c.stream() {
+		return len(b), c.answer(b)
+	}
+	c.in.Write(b)
+	for c.in.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.in.Bytes()))
+		if c.in.Len() < 2+n {
+			break
+		}
+		c.in.Next(2)
+		if err := c.answer(c.in.Next(n)); err != nil {
+			return len(b), err
+		}
+	}
+	return len(b), nil
+}
+
+// answer performs the query in b and buffers the response. The lock must be
+// held.
+func (c *dnsConn) answer(b []byte) error {
+	m := new(dns.Msg)
+	if err := m.Unpack(b); err != nil {
+		return err
+	}
+	if len(m.Question) != 1 {
+		return errors.New("expected exactly 1 question")
+	}
+
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	q := m.Question[0]
+	res, err := c.sc.Query(ctx, q.Name, q.Qtype)
+	if res == nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return os.ErrDeadlineExceeded
+		}
+		res = new(dns.Msg)
+		res.SetRcode(m, dns.RcodeServerFailure)
+	} else {
+		res = res.Copy()
+	}
+	res.Id = m.Id
+	res.Question = m.Question
+
+	if !c.stream() {
+		size := dns.MinMsgSize
+		if opt := m.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		res.Truncate(size)
+	}
+	out, err := res.Pack()
+	if err != nil {
+		return err
+	}
+	if c.stream() {
+		c.out.Write(binary.BigEndian.AppendUint16(nil, uint16(len(out))))
+	}
+	c.out.Write(out)
+	return nil
+}
+
+// Read implements the net.Conn interface. It returns the responses to the
+// messages which have been written. When not used as a stream each Read
+// returns a single response.
+func (c *dnsConn) Read(b []byte) (int, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	} else if c.out.Len() == 0 {
+		// we answer synchronously in Write, so there's nothing to wait for
+		return 0, os.ErrDeadlineExceeded
+	}
+	if c.stream() {
+		return c.out.Read(b)
+	}
+	n := copy(b, c.out.Bytes())
+	c.out.Reset()
+	return n, nil
+}
+
+// Close implements the net.Conn interface
+func (c *dnsConn) Close() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.closed = true
+	return nil
+}
+
+// LocalAddr implements the net.Conn interface
+func (c *dnsConn) LocalAddr() net.Addr { return dnsConnAddr }
+
+// RemoteAddr implements the net.Conn interface
+func (c *dnsConn) RemoteAddr() net.Addr { return dnsConnAddr }
+
+// SetDeadline implements the net.Conn interface. The deadline applies to the
+// queries made by Write.
+func (c *dnsConn) SetDeadline(t time.Time) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.deadline = t
+	return nil
+}
+
+// SetReadDeadline implements the net.Conn interface. It does nothing since
+// Read never blocks.
+func (c *dnsConn) SetReadDeadline(t time.Time) error { return nil }
+
+// SetWriteDeadline implements the net.Conn interface
+func (c *dnsConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+// dnsPacketConn is a dnsConn which also implements net.PacketConn, which is
+// how the net package determines that it shouldn't be used as a stream
+type dnsPacketConn struct {
+	*dnsConn
+}
+
+// ReadFrom implements the net.PacketConn interface
+func (c *dnsPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, dnsConnAddr, err
+}
+
+// WriteTo implements the net.PacketConn interface
+func (c *dnsPacketConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	return c.Write(b)
+}
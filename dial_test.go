@@ -0,0 +1,33 @@
+package srvclient
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetResolver(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	r := client.NetResolver()
+
+	addrs, err := r.LookupHost(context.Background(), testHostnameNoSRV)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"11.0.0.1"}, addrs)
+
+	_, srvs, err := r.LookupSRV(context.Background(), "", "", testHostname)
+	require.NoError(t, err)
+	require.Len(t, srvs, 2)
+	targets := []string{srvs[0].Target, srvs[1].Target}
+	sort.Strings(targets)
+	assert.Equal(t, []string{"1.srv.test.", "2.srv.test."}, targets)
+
+	// the udp response is truncated so this is also retried over tcp
+	_, srvs, err = r.LookupSRV(context.Background(), "", "", testHostnameTruncated)
+	require.NoError(t, err)
+	assert.Len(t, srvs, 2)
+	assert.EqualValues(t, 1, client.Stats().TruncatedResponses)
+}
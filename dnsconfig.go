@@ -1,7 +1,10 @@
 package srvclient
 
 import (
+	"net"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
@@ -22,7 +25,17 @@ type dnsConfigGet struct {
 	err error
 }
 
-var dnsConfigCh = make(chan dnsConfigGet)
+// dnsConfig holds the latest result of loading resolvFile. It's never modified
+// once stored, so lookups can read it concurrently without any coordination.
+var dnsConfig atomic.Pointer[dnsConfigGet]
+
+// dnsConfigLoaded is closed once dnsConfig has first been stored
+var dnsConfigLoaded = make(chan struct{})
+
+// dnsConfigL is held while loading resolvFile, so that dnsReload and
+// dnsConfigLoop don't overwrite each other, and protects dnsLastReload
+var dnsConfigL sync.Mutex
+var dnsLastReload time.Time
 
 func dnsShouldReload(lastReload time.Time) bool {
 	fi, err := os.Stat(resolvFile)
@@ -32,43 +45,67 @@ func dnsShouldReload(lastReload time.Time) bool {
 	return lastReload.Before(fi.ModTime())
 }
 
-func dnsConfigLoop() {
-	getConfig := func() dnsConfigGet {
-		cfg, err := dns.ClientConfigFromFile(resolvFile)
-		if err != nil {
-			return dnsConfigGet{err: err}
-		}
-		for i := range cfg.Servers {
-			cfg.Servers[i] = cfg.Servers[i] + ":" + cfg.Port
-		}
-
-		return dnsConfigGet{
-			cfg: clientConfig{
-				ClientConfig: *cfg,
-				updated:      time.Now(),
-			},
-		}
+func dnsLoadConfig() *dnsConfigGet {
+	cfg, err := dns.ClientConfigFromFile(resolvFile)
+	if err != nil {
+		return &dnsConfigGet{err: err}
+	}
+	for i := range cfg.Servers {
+		cfg.Servers[i] = net.JoinHostPort(cfg.Servers[i], cfg.Port)
 	}
 
-	r := getConfig()
+	return &dnsConfigGet{
+		cfg: clientConfig{
+			ClientConfig: *cfg,
+			updated:      time.Now(),
+		},
+	}
+}
+
+// dnsStoreConfig loads resolvFile and stores the result in dnsConfig. If force
+// isn't set then it's only loaded if the last attempt failed or the file has
+// changed since. dnsConfigL must be held.
+func dnsStoreConfig(force bool) {
+	if r := dnsConfig.Load(); !force && r != nil && r.err == nil && !dnsShouldReload(dnsLastReload) {
+		return
+	}
+	r := dnsLoadConfig()
+	if r.err == nil {
+		dnsLastReload = time.Now()
+	}
+	dnsConfig.Store(r)
+}
+
+func dnsConfigLoop() {
+	dnsConfigL.Lock()
+	dnsStoreConfig(true)
+	dnsConfigL.Unlock()
+	close(dnsConfigLoaded)
+
 	tick := time.NewTicker(reloadInterval)
 	defer tick.Stop()
-	lastReload := time.Now()
-	for {
-		select {
-		case dnsConfigCh <- r:
-		case <-tick.C:
-			if r.err == nil && !dnsShouldReload(lastReload) {
-				continue
-			}
-			if r = getConfig(); r.err == nil {
-				lastReload = time.Now()
-			}
-		}
+	for range tick.C {
+		dnsConfigL.Lock()
+		dnsStoreConfig(false)
+		dnsConfigL.Unlock()
 	}
 }
 
 func dnsGetConfig() (clientConfig, error) {
-	r := <-dnsConfigCh
+	r := dnsConfig.Load()
+	if r == nil {
+		<-dnsConfigLoaded
+		r = dnsConfig.Load()
+	}
 	return r.cfg, r.err
 }
+
+// dnsReload reloads the config from resolvFile, which every SRVClient then
+// picks up on its next lookup. It returns once the new config is stored, so
+// lookups made afterwards get it.
+func dnsReload() {
+	<-dnsConfigLoaded
+	dnsConfigL.Lock()
+	defer dnsConfigL.Unlock()
+	dnsStoreConfig(true)
+}
@@ -0,0 +1,257 @@
+// Package dnsserver provides a small DNS server which answers queries using an
+// in-memory table of records that can be changed at runtime, for development
+// environments and integration tests which need a controllable source of SRV
+// records
+package dnsserver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+
+	"github.com/levenlabs/go-srvclient"
+)
+
+// DefaultTTL is the TTL given to records when the Server's TTL isn't set
+const DefaultTTL = 60
+
+// Server is a DNS server which answers queries, over both udp and tcp, using
+// the records which have been added to it. Names with no records at all are
+// answered with NXDOMAIN. The A and AAAA records for the targets of SRV
+// records are included in the extra section of SRV responses, like most
+// recursive resolvers do.
+//
+// All methods are safe to call concurrently, including while the server is
+// answering queries.
+type Server struct {
+	// Addr is the address ("ip:port") the server is listening on
+	Addr string
+
+	// TTL is given to records added after it is set. Defaults to DefaultTTL.
+	TTL uint32
+
+	l       sync.RWMutex
+	records map[string][]dns.RR
+
+	udp, tcp *dns.Server
+}
+
+// Listen starts a Server listening on the given address ("ip:port") over both
+// udp and tcp. If the port is 0 then a random one is used, which is the same for
+// both. Close should be called once it's no longer needed.
+func Listen(addr string) (*Server, error) {
+	s := &Server{records: map[string][]dns.RR{}}
+
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.Addr = pc.LocalAddr().String()
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+
+	s.udp = &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(s.handle)}
+	s.tcp = &dns.Server{Listener: l, Handler: dns.HandlerFunc(s.handle)}
+	for _, srv := range []*dns.Server{s.udp, s.tcp} {
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe()
+		<-started
+	}
+	return s, nil
+}
+
+// Client returns a new SRVClient which uses the Server as its only resolver
+func (s *Server) Client() *srvclient.SRVClient {
+	return &srvclient.SRVClient{ResolverAddrs: []string{s.Addr}}
+}
+
+// Close stops the Server from answering queries
+func (s *Server) Close() error {
+	uerr := s.udp.Shutdown()
+	if err := s.tcp.Shutdown(); err != nil {
+		return err
+	}
+	return uerr
+}
+
+func (s *Server) ttl() uint32 {
+	if s.TTL == 0 {
+		return DefaultTTL
+	}
+	return s.TTL
+}
+
+func (s *Server) hdr(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{
+		Name:   dns.Fqdn(name),
+		Rrtype: rrtype,
+		Class:  dns.ClassINET,
+		Ttl:    s.ttl(),
+	}
+}
+
+// AddRR adds an arbitrary record to the Server
+func (s *Server) AddRR(rr dns.RR) {
+	key := strings.ToLower(rr.Header().Name)
+	s.l.Lock()
+	s.records[key] = append(s.records[key], rr)
+	s.l.Unlock()
+}
+
+// AddSRV adds a SRV record for hostname pointing at target:port
+func (s *Server) AddSRV(hostname, target string, port, priority, weight uint16) {
+	s.AddRR(&dns.SRV{
+		Hdr:      s.hdr(hostname, dns.TypeSRV),
+		Priority: priority,
+		Weight:   weight,
+		Port:     port,
+		Target:   dns.Fqdn(target),
+	})
+}
+
+// AddA adds an A record for hostname. It panics if ip isn't an IPv4 address.
+func (s *Server) AddA(hostname, ip string) {
+	parsed := net.ParseIP(ip).To4()
+	if parsed == nil {
+		panic(fmt.Sprintf("dnsserver: invalid IPv4 address %q", ip))
+	}
+	s.AddRR(&dns.A{Hdr: s.hdr(hostname, dns.TypeA), A: parsed})
+}
+
+// AddAAAA adds an AAAA record for hostname. It panics if ip isn't an IPv6
+// address.
+func (s *Server) AddAAAA(hostname, ip string) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		panic(fmt.Sprintf("dnsserver: invalid IPv6 address %q", ip))
+	}
+	s.AddRR(&dns.AAAA{Hdr: s.hdr(hostname, dns.TypeAAAA), AAAA: parsed})
+}
+
+// AddTarget adds a SRV record for hostname pointing at target:port, along with
+// an A or AAAA record for target for each of the given ips. It panics if any of
+// the ips are invalid.
+func (s *Server) AddTarget(hostname, target string, port, priority, weight uint16, ips ...string) {
+	s.AddSRV(hostname, target, port, priority, weight)
+	for _, ip := range ips {
+		if strings.Contains(ip, ":") {
+			s.AddAAAA(target, ip)
+		} else {
+			s.AddA(target, ip)
+		}
+	}
+}
+
+// RemoveTarget removes the SRV records for hostname pointing at target:port.
+// If no other SRV records point at target then its A and AAAA records are
+// removed too. If hostname is left without any records then it's answered
+// with NXDOMAIN, as if Remove had been called.
+func (s *Server) RemoveTarget(hostname, target string, port uint16) {
+	key, target := strings.ToLower(dns.Fqdn(hostname)), strings.ToLower(dns.Fqdn(target))
+	s.l.Lock()
+	defer s.l.Unlock()
+	rrs := s.records[key][:0]
+	for _, rr := range s.records[key] {
+		if srv, ok := rr.(*dns.SRV); ok && srv.Port == port && strings.EqualFold(srv.Target, target) {
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	if len(rrs) == 0 {
+		delete(s.records, key)
+	} else {
+		s.records[key] = rrs
+	}
+
+	for _, rrs := range s.records {
+		for _, rr := range rrs {
+			if srv, ok := rr.(*dns.SRV); ok && strings.EqualFold(srv.Target, target) {
+				return
+			}
+		}
+	}
+	rrs = s.records[target][:0]
+	for _, rr := range s.records[target] {
+		if t := rr.Header().Rrtype; t != dns.TypeA && t != dns.TypeAAAA {
+			rrs = append(rrs, rr)
+		}
+	}
+	if len(rrs) == 0 {
+		delete(s.records, target)
+	} else {
+		s.records[target] = rrs
+	}
+}
+
+// Remove removes all records for hostname
+func (s *Server) Remove(hostname string) {
+	s.l.Lock()
+	delete(s.records, strings.ToLower(dns.Fqdn(hostname)))
+	s.l.Unlock()
+}
+
+// Reset removes all records from the Server
+func (s *Server) Reset() {
+	s.l.Lock()
+	s.records = map[string][]dns.RR{}
+	s.l.Unlock()
+}
+
+// lookup returns the records of the given type for name, and whether there were
+// any records for the name at all
+func (s *Server) lookup(name string, qtype uint16) ([]dns.RR, bool) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	all, ok := s.records[strings.ToLower(name)]
+	var rrs []dns.RR
+	for _, rr := range all {
+		if rr.Header().Rrtype == qtype || qtype == dns.TypeANY {
+			rrs = append(rrs, dns.Copy(rr))
+		}
+	}
+	return rrs, ok
+}
+
+func (s *Server) handle(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	if len(r.Question) != 1 {
+		m.SetRcode(r, dns.RcodeFormatError)
+		w.WriteMsg(m)
+		return
+	}
+
+	q := r.Question[0]
+	ans, exists := s.lookup(q.Name, q.Qtype)
+	if !exists {
+		m.SetRcode(r, dns.RcodeNameError)
+	}
+	m.Answer = ans
+	for _, rr := range ans {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		a, _ := s.lookup(srv.Target, dns.TypeA)
+		aaaa, _ := s.lookup(srv.Target, dns.TypeAAAA)
+		m.Extra = append(m.Extra, a...)
+		m.Extra = append(m.Extra, aaaa...)
+	}
+
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := r.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		m.Truncate(size)
+	}
+	w.WriteMsg(m)
+}
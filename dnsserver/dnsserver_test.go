@@ -0,0 +1,48 @@
+package dnsserver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/levenlabs/go-srvclient"
+)
+
+func TestTargets(t *testing.T) {
+	s, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer s.Close()
+	sc := s.Client()
+
+	s.AddTarget("_web._tcp.dev.test", "a.dev.test", 8000, 0, 1, "10.0.0.1", "::1")
+	s.AddTarget("_web._tcp.dev.test", "a.dev.test", 8001, 0, 1)
+	s.AddTarget("_web._tcp.dev.test", "b.dev.test", 8000, 0, 1, "10.0.0.2")
+	s.AddTarget("_api._tcp.dev.test", "b.dev.test", 9000, 0, 1)
+
+	addrs, err := sc.AllSRVTranslate("_web._tcp.dev.test")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"10.0.0.1:8000", "10.0.0.1:8001", "10.0.0.2:8000"}, addrs)
+
+	// a.dev.test is still the target of another record, so keeps its IPs
+	s.RemoveTarget("_web._tcp.dev.test", "a.dev.test", 8000)
+	addrs, err = sc.AllSRVTranslate("_web._tcp.dev.test")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"10.0.0.1:8001", "10.0.0.2:8000"}, addrs)
+
+	s.RemoveTarget("_web._tcp.dev.test", "A.dev.test.", 8001)
+	addrs, err = sc.AllSRVTranslate("_web._tcp.dev.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:8000"}, addrs)
+	_, err = sc.LookupIP("a.dev.test")
+	assert.True(t, errors.Is(err, srvclient.ErrNXDomain), "%v", err)
+
+	// b.dev.test is still used by _api
+	s.RemoveTarget("_web._tcp.dev.test", "b.dev.test", 8000)
+	_, err = sc.SRV("_web._tcp.dev.test")
+	assert.True(t, errors.Is(err, srvclient.ErrNXDomain), "%v", err)
+	addr, err := sc.SRV("_api._tcp.dev.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:9000", addr)
+}
@@ -0,0 +1,334 @@
+package srvclient
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The protobuf messages and frame streams protocol used here are described at
+// https://dnstap.info. They're simple enough that we encode them by hand rather
+// than pull in a protobuf dependency.
+
+const dnstapContentType = "protobuf:dnstap.Dnstap"
+
+// frame streams control frame types and fields
+const (
+	fstrmControlAccept  = 0x01
+	fstrmControlStart   = 0x02
+	fstrmControlStop    = 0x03
+	fstrmControlReady   = 0x04
+	fstrmControlFinish  = 0x05
+	fstrmFieldContentID = 0x01
+)
+
+// dnstap Message.Type values for a stub resolver
+const (
+	dnstapStubQuery    = 9
+	dnstapStubResponse = 10
+)
+
+// dnstapQueueSize is the number of messages which can be waiting to be written
+// before new ones are dropped
+const dnstapQueueSize = 1024
+
+// DnstapLogger writes a dnstap message for every query sent and response
+// received by a SRVClient whose Dnstap field is set to it. Messages are written
+// in the background so a slow destination doesn't slow down lookups, and are
+// dropped if too many are waiting to be written.
+type DnstapLogger struct {
+	identity []byte
+	w        *bufio.Writer
+	conn     io.ReadWriteCloser // only set when bidirectional
+	closer   io.Closer
+
+	ch        chan []byte
+	done      chan struct{}
+	closeO    sync.Once
+	closeErr  error
+	numDrops  int64
+	writeErrL sync.Mutex
+	writeErr  error
+}
+
+// NewDnstapWriter returns a DnstapLogger which writes to w using the
+// unidirectional frame streams protocol, e.g. to a file. identity is included
+// in every message, and can be empty. If w is an io.Closer it's closed when the
+// DnstapLogger is.
+func NewDnstapWriter(w io.Writer, identity string) (*DnstapLogger, error) {
+	d := newDnstapLogger(w, identity)
+	if c, ok := w.(io.Closer); ok {
+		d.closer = c
+	}
+	if err := d.writeControl(fstrmControlStart, true); err != nil {
+		return nil, err
+	}
+	if err := d.w.Flush(); err != nil {
+		return nil, err
+	}
+	go d.run()
+	return d, nil
+}
+
+// CreateDnstapFile creates, or truncates, the file at path and returns a
+// DnstapLogger which writes to it
+func CreateDnstapFile(path, identity string) (*DnstapLogger, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	d, err := NewDnstapWriter(f, identity)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// DialDnstap connects to the unix socket at path, e.g. one created by `dnstap
+// -u`, and returns a DnstapLogger which writes to it using the bidirectional
+// frame streams protocol
+func DialDnstap(path, identity string) (*DnstapLogger, error) {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	d := newDnstapLogger(conn, identity)
+	d.conn, d.closer = conn, conn
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	err = d.writeControl(fstrmControlReady, true)
+	if err == nil {
+		err = d.w.Flush()
+	}
+	if err == nil {
+		err = d.readControl(fstrmControlAccept)
+	}
+	if err == nil {
+		err = d.writeControl(fstrmControlStart, true)
+	}
+	if err == nil {
+		err = d.w.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("dnstap handshake with %s: %w", path, err)
+	}
+	conn.SetDeadline(time.Time{})
+	go d.run()
+	return d, nil
+}
+
+func newDnstapLogger(w io.Writer, identity string) *DnstapLogger {
+	return &DnstapLogger{
+		identity: []byte(identity),
+		w:        bufio.NewWriter(w),
+		ch:       make(chan []byte, dnstapQueueSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// Dropped returns the number of messages which were dropped, either because
+// too many were waiting to be written or because writing failed
+func (d *DnstapLogger) Dropped() int64 {
+	return atomic.LoadInt64(&d.numDrops)
+}
+
+// Close writes any waiting messages and stops the DnstapLogger. It returns the
+// first error encountered while writing, if any. No more messages should be
+// logged once Close has been called.
+func (d *DnstapLogger) Close() error {
+	d.closeO.Do(func() {
+		close(d.ch)
+		<-d.done
+
+		err := d.err()
+		if err == nil {
+			err = d.writeControl(fstrmControlStop, false)
+		}
+		if err == nil {
+			err = d.w.Flush()
+		}
+		if err == nil && d.conn != nil {
+			if c, ok := d.conn.(net.Conn); ok {
+				c.SetReadDeadline(time.Now().Add(5 * time.Second))
+			}
+			err = d.readControl(fstrmControlFinish)
+		}
+		if d.closer != nil {
+			if cerr := d.closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		d.closeErr = err
+	})
+	return d.closeErr
+}
+
+func (d *DnstapLogger) err() error {
+	d.writeErrL.Lock()
+	defer d.writeErrL.Unlock()
+	return d.writeErr
+}
+
+func (d *DnstapLogger) run() {
+	defer close(d.done)
+	for frame := range d.ch {
+		if d.err() != nil {
+			atomic.AddInt64(&d.numDrops, 1)
+			continue
+		}
+		var lenb [4]byte
+		binary.BigEndian.PutUint32(lenb[:], uint32(len(frame)))
+		d.w.Write(lenb[:])
+		d.w.Write(frame)
+		// only flush once we've caught up so bursts are written together
+		var err error
+		if len(d.ch) == 0 {
+			err = d.w.Flush()
+		}
+		if err != nil {
+			d.writeErrL.Lock()
+			d.writeErr = err
+			d.writeErrL.Unlock()
+		}
+	}
+}
+
+// writeControl writes a control frame, optionally with the dnstap content type
+func (d *DnstapLogger) writeControl(typ uint32, contentType bool) error {
+	var frame []byte
+	frame = binary.BigEndian.AppendUint32(frame, typ)
+	if contentType {
+		frame = binary.BigEndian.AppendUint32(frame, fstrmFieldContentID)
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(dnstapContentType)))
+		frame = append(frame, dnstapContentType...)
+	}
+	b := binary.BigEndian.AppendUint32(nil, 0) // escape
+	b = binary.BigEndian.AppendUint32(b, uint32(len(frame)))
+	_, err := d.w.Write(append(b, frame...))
+	return err
+}
+
+// readControl reads a control frame from the connection and checks it's of the
+// given type
+func (d *DnstapLogger) readControl(typ uint32) error {
+	var hdr [12]byte
+	if _, err := io.ReadFull(d.conn, hdr[:]); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint32(hdr[0:4]) != 0 {
+		return errors.New("expected a control frame")
+	}
+	n := binary.BigEndian.Uint32(hdr[4:8])
+	if n < 4 || n > 4096 {
+		return fmt.Errorf("invalid control frame length %d", n)
+	}
+	if got := binary.BigEndian.Uint32(hdr[8:12]); got != typ {
+		return fmt.Errorf("expected control frame type %d, got %d", typ, got)
+	}
+	// discard the frame's fields, we don't need them
+	_, err := io.CopyN(io.Discard, d.conn, int64(n-4))
+	return err
+}
+
+// log queues a message for a query or response. responseTime is only used for
+// responses.
+func (d *DnstapLogger) log(typ uint64, network, server string, m *dns.Msg, queryTime, responseTime time.Time) {
+	b, err := m.Pack()
+	if err != nil {
+		atomic.AddInt64(&d.numDrops, 1)
+		return
+	}
+	frame := encodeDnstap(d.identity, typ, network, server, b, queryTime, responseTime)
+	select {
+	case d.ch <- frame:
+	default:
+		atomic.AddInt64(&d.numDrops, 1)
+	}
+}
+
+// protobuf field encoding helpers
+
+func pbVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func pbTag(b []byte, field int, wireType int) []byte {
+	return pbVarint(b, uint64(field<<3|wireType))
+}
+
+func pbUint(b []byte, field int, v uint64) []byte {
+	return pbVarint(pbTag(b, field, 0), v)
+}
+
+func pbBytes(b []byte, field int, v []byte) []byte {
+	b = pbVarint(pbTag(b, field, 2), uint64(len(v)))
+	return append(b, v...)
+}
+
+func pbFixed32(b []byte, field int, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(pbTag(b, field, 5), v)
+}
+
+// encodeDnstap encodes a Dnstap protobuf containing a single Message
+func encodeDnstap(identity []byte, typ uint64, network, server string, msg []byte, queryTime, responseTime time.Time) []byte {
+	var m []byte
+	m = pbUint(m, 1, typ)
+
+	host, portStr, _ := net.SplitHostPort(server)
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			m = pbUint(m, 2, 1) // INET
+			ip = ip4
+		} else {
+			m = pbUint(m, 2, 2) // INET6
+		}
+		m = pbBytes(m, 5, ip)
+	}
+	switch network {
+	case NetUDP:
+		m = pbUint(m, 3, 1)
+	case NetTCP:
+		m = pbUint(m, 3, 2)
+	case NetTLS:
+		m = pbUint(m, 3, 3)
+	case NetHTTPS:
+		m = pbUint(m, 3, 4)
+	}
+	if port, err := strconv.ParseUint(portStr, 10, 16); err == nil {
+		m = pbUint(m, 7, port)
+	}
+
+	m = pbUint(m, 8, uint64(queryTime.Unix()))
+	m = pbFixed32(m, 9, uint32(queryTime.Nanosecond()))
+	if typ == dnstapStubQuery {
+		m = pbBytes(m, 10, msg)
+	} else {
+		m = pbUint(m, 12, uint64(responseTime.Unix()))
+		m = pbFixed32(m, 13, uint32(responseTime.Nanosecond()))
+		m = pbBytes(m, 14, msg)
+	}
+
+	var b []byte
+	if len(identity) > 0 {
+		b = pbBytes(b, 1, identity)
+	}
+	b = pbBytes(b, 14, m)
+	b = pbUint(b, 15, 1) // MESSAGE
+	return b
+}
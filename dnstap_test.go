@@ -0,0 +1,153 @@
+package srvclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readFrame reads a single frame streams frame, returning whether it was a
+// control frame
+func readFrame(t *testing.T, r io.Reader) ([]byte, bool) {
+	var n uint32
+	require.NoError(t, binary.Read(r, binary.BigEndian, &n))
+	control := n == 0
+	if control {
+		require.NoError(t, binary.Read(r, binary.BigEndian, &n))
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	require.NoError(t, err)
+	return b, control
+}
+
+// decodePB decodes the top-level fields of a protobuf message. Varints and
+// fixed32s are returned as uint64s, and length-delimited fields as []byte.
+func decodePB(t *testing.T, b []byte) map[int]interface{} {
+	fields := map[int]interface{}{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		require.Greater(t, n, 0)
+		b = b[n:]
+		switch tag & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			require.Greater(t, n, 0)
+			fields[int(tag>>3)], b = v, b[n:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			require.Greater(t, n, 0)
+			fields[int(tag>>3)], b = b[n:n+int(l)], b[n+int(l):]
+		case 5:
+			fields[int(tag>>3)], b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	return fields
+}
+
+func assertDnstapFrames(t *testing.T, r io.Reader, server string) {
+	for _, typ := range []uint64{dnstapStubQuery, dnstapStubResponse} {
+		frame, control := readFrame(t, r)
+		require.False(t, control)
+		d := decodePB(t, frame)
+		assert.Equal(t, []byte("test"), d[1])
+		assert.EqualValues(t, 1, d[15])
+
+		m := decodePB(t, d[14].([]byte))
+		assert.Equal(t, typ, m[1])
+		assert.EqualValues(t, 1, m[3]) // UDP
+		host, _, _ := net.SplitHostPort(server)
+		ip := net.ParseIP(host)
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		assert.Equal(t, []byte(ip), m[5])
+
+		field := 10
+		if typ == dnstapStubResponse {
+			field = 14
+		}
+		msg := new(dns.Msg)
+		require.NoError(t, msg.Unpack(m[field].([]byte)))
+		assert.Equal(t, dns.Fqdn(testHostname), msg.Question[0].Name)
+		assert.Equal(t, typ == dnstapStubResponse, msg.Response)
+	}
+}
+
+func TestDnstapWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	d, err := NewDnstapWriter(buf, "test")
+	require.NoError(t, err)
+
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	client.Dnstap = d
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+	assert.Zero(t, d.Dropped())
+
+	frame, control := readFrame(t, buf)
+	require.True(t, control)
+	assert.EqualValues(t, fstrmControlStart, binary.BigEndian.Uint32(frame))
+	assert.Contains(t, string(frame), dnstapContentType)
+
+	assertDnstapFrames(t, buf, client.ResolverAddrs[0])
+
+	frame, control = readFrame(t, buf)
+	require.True(t, control)
+	assert.EqualValues(t, fstrmControlStop, binary.BigEndian.Uint32(frame))
+	assert.Zero(t, buf.Len())
+}
+
+func TestDialDnstap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnstap.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	done := make(chan struct{})
+	server := DefaultSRVClient.ResolverAddrs[0]
+	go func() {
+		defer close(done)
+		conn, err := l.Accept()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		w := newDnstapLogger(conn, "")
+
+		frame, control := readFrame(t, conn)
+		assert.True(t, control)
+		assert.EqualValues(t, fstrmControlReady, binary.BigEndian.Uint32(frame))
+		assert.NoError(t, w.writeControl(fstrmControlAccept, true))
+		assert.NoError(t, w.w.Flush())
+
+		frame, _ = readFrame(t, conn)
+		assert.EqualValues(t, fstrmControlStart, binary.BigEndian.Uint32(frame))
+		assertDnstapFrames(t, conn, server)
+		frame, _ = readFrame(t, conn)
+		assert.EqualValues(t, fstrmControlStop, binary.BigEndian.Uint32(frame))
+		assert.NoError(t, w.writeControl(fstrmControlFinish, false))
+		assert.NoError(t, w.w.Flush())
+	}()
+
+	d, err := DialDnstap(path, "test")
+	require.NoError(t, err)
+	client := SRVClient{}
+	client.ResolverAddrs = []string{server}
+	client.Dnstap = d
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+	require.NoError(t, d.Close())
+	<-done
+}
@@ -0,0 +1,113 @@
+// Package dsn rewrites the hosts in database and message broker connection
+// strings using SRV lookups, so that drivers which know nothing about SRV
+// records can be pointed at SRV names directly.
+//
+// URL style DSNs, e.g. postgres://, redis:// and amqp://, are supported,
+// including ones with a comma separated list of hosts, as are the
+// "user:pass@tcp(host)/dbname" DSNs used by the go-sql-driver/mysql driver.
+// Every host which doesn't contain a port is rewritten to the result of a SRV
+// lookup of it, the same as MaybeSRV, and everything else in the DSN, like the
+// credentials and parameters, is left as it was.
+package dsn
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/levenlabs/go-srvclient"
+)
+
+// Rewrite calls RewriteE and only returns the DSN
+func Rewrite(ctx context.Context, r srvclient.Resolver, dsn string) string {
+	dsn, _ = RewriteE(ctx, r, dsn)
+	return dsn
+}
+
+// RewriteE rewrites the hosts of the given DSN using the MaybeSRVE method of
+// r, or of srvclient.DefaultSRVClient if r is nil. Hosts whose lookups fail are
+// left as they were, and the errors from those lookups are returned along with
+// the DSN. If the DSN isn't in a recognized format then it's returned as is.
+func RewriteE(ctx context.Context, r srvclient.Resolver, dsn string) (string, error) {
+	if r == nil {
+		r = srvclient.DefaultSRVClient
+	}
+	rw := rewriter{ctx: ctx, r: r}
+	var out string
+	if strings.Contains(dsn, "://") {
+		out = rw.url(dsn)
+	} else {
+		out = rw.mysql(dsn)
+	}
+	return out, errors.Join(rw.errs...)
+}
+
+type rewriter struct {
+	ctx  context.Context
+	r    srvclient.Resolver
+	errs []error
+}
+
+func (rw *rewriter) host(host string) string {
+	if host == "" {
+		return host
+	}
+	addr, _, err := rw.r.MaybeSRVE(rw.ctx, host)
+	if err != nil {
+		rw.errs = append(rw.errs, err)
+	}
+	return addr
+}
+
+// url rewrites a DSN of the form
+// scheme://[userinfo@]host[:port][,host[:port]...][/path][?query][#fragment]
+func (rw *rewriter) url(dsn string) string {
+	i := strings.Index(dsn, "://") + 3
+	scheme, rest := dsn[:i], dsn[i:]
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	authority, rest := rest[:end], rest[end:]
+
+	// passwords are meant to be escaped, but we use the last @ in case they're
+	// not, since hosts can't contain one
+	var userinfo string
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		userinfo, authority = authority[:at+1], authority[at+1:]
+	}
+
+	hosts := strings.Split(authority, ",")
+	for i := range hosts {
+		hosts[i] = rw.host(hosts[i])
+	}
+	return scheme + userinfo + strings.Join(hosts, ",") + rest
+}
+
+// mysql rewrites a DSN of the form
+// [user[:password]@][net[(addr)]]/dbname[?params], which like the driver is
+// split on the last / and the last @ before it. Only addresses for the tcp
+// networks are rewritten.
+func (rw *rewriter) mysql(dsn string) string {
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return dsn
+	}
+	prefix, rest := dsn[:slash], dsn[slash:]
+	var userinfo string
+	if at := strings.LastIndex(prefix, "@"); at >= 0 {
+		userinfo, prefix = prefix[:at+1], prefix[at+1:]
+	}
+
+	open := strings.Index(prefix, "(")
+	if open < 0 || !strings.HasSuffix(prefix, ")") {
+		return dsn
+	}
+	network, addr := prefix[:open], prefix[open+1:len(prefix)-1]
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return dsn
+	}
+	return userinfo + network + "(" + rw.host(addr) + ")" + rest
+}
@@ -0,0 +1,52 @@
+package srvclient
+
+import "time"
+
+// EDNSPolicy determines how EDNS0 is used for queries sent over UDP
+type EDNSPolicy int
+
+// The EDNSPolicy values which can be set on SRVClient.EDNS
+const (
+	// EDNSFallback advertises UDPSize using EDNS0, and if a resolver responds
+	// with FORMERR, indicating it doesn't support EDNS0, retries the query
+	// without it. Resolvers which don't support EDNS0 are remembered for
+	// ednsMemory so that subsequent queries to them skip it and aren't retried.
+	EDNSFallback EDNSPolicy = iota
+
+	// EDNSNoFallback advertises UDPSize using EDNS0 but doesn't retry queries
+	// which a resolver responds to with FORMERR
+	EDNSNoFallback
+
+	// EDNSDisabled never uses EDNS0, so UDP responses are limited to 512 bytes
+	// and larger ones will be truncated
+	EDNSDisabled
+)
+
+// String returns the name of the policy
+func (p EDNSPolicy) String() string {
+	switch p {
+	case EDNSFallback:
+		return "fallback"
+	case EDNSNoFallback:
+		return "no-fallback"
+	case EDNSDisabled:
+		return "disabled"
+	}
+	return "unknown"
+}
+
+// ednsMemory is how long a resolver which rejected EDNS0 is remembered for,
+// after which EDNS0 is tried with it again in case it was upgraded
+const ednsMemory = 10 * time.Minute
+
+// ednsUnsupported returns whether the resolver recently rejected EDNS0
+func (sc *SRVClient) ednsUnsupported(server string) bool {
+	ti, ok := sc.noEDNS.Load(server)
+	if !ok {
+		return false
+	} else if time.Since(ti.(time.Time)) > ednsMemory {
+		sc.noEDNS.Delete(server)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package srvclient
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noEDNSServer starts a server which responds with FORMERR to queries using
+// EDNS0, and returns its address along with a function returning whether each
+// query it received used EDNS0
+func noEDNSServer(t *testing.T) (string, func() []bool) {
+	var l sync.Mutex
+	var queries []bool
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		l.Lock()
+		queries = append(queries, r.IsEdns0() != nil)
+		l.Unlock()
+		if r.IsEdns0() != nil {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeFormatError)
+			w.WriteMsg(m)
+			return
+		}
+		handleRequest(w, r)
+	})
+	return addr, func() []bool {
+		l.Lock()
+		defer l.Unlock()
+		return append([]bool(nil), queries...)
+	}
+}
+
+func TestEDNSPolicy(t *testing.T) {
+	addr, queries := noEDNSServer(t)
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	_, err := client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, queries())
+
+	// the resolver not supporting EDNS0 is remembered
+	_, err = client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false}, queries())
+
+	client = SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	client.EDNS = EDNSDisabled
+	_, err = client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false, false}, queries())
+
+	client = SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	client.EDNS = EDNSNoFallback
+	_, err = client.AllSRV(testHostname)
+	assert.True(t, errors.Is(err, &ErrRcode{Rcode: dns.RcodeFormatError}))
+	assert.Equal(t, []bool{true, false, false, false, true}, queries())
+}
@@ -1,14 +1,275 @@
 package srvclient
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// ErrNoRecords can be used with errors.Is to check if a lookup failed because
+// there were no SRV records for the hostname, i.e. an ErrNotFound
+var ErrNoRecords = errors.New("no SRV records")
 
 // ErrNotFound is returned when there were no SRV records for the given
-// hostname
+// hostname, or no A or AAAA records for LookupIP. errors.Is(err, ErrNoRecords)
+// will be true for it, and if the resolver responded with NXDOMAIN then
+// errors.Is(err, ErrNXDomain) will also be true.
 type ErrNotFound struct {
 	hostname string
+	resolver string
+	rcode    int
+
+	// types describes the types of records which were looked up, if they
+	// weren't SRV records
+	types string
 }
 
 // Error implements the error interface
 func (err *ErrNotFound) Error() string {
+	if err.types != "" {
+		return fmt.Sprintf("No %s records for %q", err.types, err.hostname)
+	}
 	return fmt.Sprintf("No SRV records for %q", err.hostname)
 }
+
+// Hostname returns the hostname which had no records
+func (err *ErrNotFound) Hostname() string {
+	return err.hostname
+}
+
+// Is allows errors.Is to match ErrNoRecords and any other ErrNotFound
+func (err *ErrNotFound) Is(target error) bool {
+	if target == ErrNoRecords {
+		return true
+	}
+	_, ok := target.(*ErrNotFound)
+	return ok
+}
+
+// Unwrap returns an ErrRcode if the hostname didn't exist at all
+func (err *ErrNotFound) Unwrap() error {
+	if err.rcode != dns.RcodeNameError {
+		return nil
+	}
+	return &ErrRcode{Hostname: err.hostname, Resolver: err.resolver, Rcode: err.rcode}
+}
+
+// ErrNoNameservers is returned when there were no resolvers which could be
+// queried for the hostname
+type ErrNoNameservers struct {
+	Hostname string
+}
+
+// Error implements the error interface
+func (err *ErrNoNameservers) Error() string {
+	return fmt.Sprintf("no available nameservers to lookup %q", err.Hostname)
+}
+
+// Is allows errors.Is(err, &ErrNoNameservers{}) to match any ErrNoNameservers
+func (err *ErrNoNameservers) Is(target error) bool {
+	_, ok := target.(*ErrNoNameservers)
+	return ok
+}
+
+// ErrTimeout is returned when the query to a resolver timed out
+type ErrTimeout struct {
+	Hostname string
+	Resolver string
+	Err      error
+}
+
+// Error implements the error interface
+func (err *ErrTimeout) Error() string {
+	return fmt.Sprintf("timed out looking up %q on %s: %s", err.Hostname, err.Resolver, err.Err)
+}
+
+// Unwrap returns the underlying error
+func (err *ErrTimeout) Unwrap() error {
+	return err.Err
+}
+
+// Is allows errors.Is(err, &ErrTimeout{}) to match any ErrTimeout
+func (err *ErrTimeout) Is(target error) bool {
+	_, ok := target.(*ErrTimeout)
+	return ok
+}
+
+// Timeout implements the net.Error interface
+func (err *ErrTimeout) Timeout() bool {
+	return true
+}
+
+// Temporary implements the net.Error interface
+func (err *ErrTimeout) Temporary() bool {
+	return true
+}
+
+// ErrExchange is returned when the query to a resolver failed for a reason
+// other than a timeout, e.g. the connection was refused
+type ErrExchange struct {
+	Hostname string
+	Resolver string
+	Err      error
+}
+
+// Error implements the error interface
+func (err *ErrExchange) Error() string {
+	return fmt.Sprintf("error looking up %q on %s: %s", err.Hostname, err.Resolver, err.Err)
+}
+
+// Unwrap returns the underlying error
+func (err *ErrExchange) Unwrap() error {
+	return err.Err
+}
+
+// Is allows errors.Is(err, &ErrExchange{}) to match any ErrExchange
+func (err *ErrExchange) Is(target error) bool {
+	_, ok := target.(*ErrExchange)
+	return ok
+}
+
+var (
+	// ErrNXDomain can be used with errors.Is to check if a lookup failed because
+	// the hostname doesn't exist. This is generally a permanent failure.
+	ErrNXDomain = &ErrRcode{Rcode: dns.RcodeNameError}
+
+	// ErrServFail can be used with errors.Is to check if a lookup failed because
+	// a resolver responded with SERVFAIL. This is generally worth retrying.
+	ErrServFail = &ErrRcode{Rcode: dns.RcodeServerFailure}
+
+	// ErrRefused can be used with errors.Is to check if a lookup failed because
+	// a resolver refused to answer the query
+	ErrRefused = &ErrRcode{Rcode: dns.RcodeRefused}
+)
+
+// ErrRcode is returned when a resolver responded with an unsuccessful rcode and
+// no answers. NXDOMAIN responses are instead returned as an ErrNotFound which
+// wraps an ErrRcode.
+type ErrRcode struct {
+	Hostname string
+	Resolver string
+	Rcode    int
+}
+
+// Error implements the error interface
+func (err *ErrRcode) Error() string {
+	return fmt.Sprintf("%s looking up %q on %s", rcodeString(err.Rcode), err.Hostname, err.Resolver)
+}
+
+// Is allows errors.Is(err, &ErrRcode{}) to match any ErrRcode, and
+// errors.Is(err, &ErrRcode{Rcode: rcode}), like ErrServFail, to match any
+// ErrRcode with that rcode
+func (err *ErrRcode) Is(target error) bool {
+	t, ok := target.(*ErrRcode)
+	return ok && (t.Rcode == dns.RcodeSuccess || t.Rcode == err.Rcode)
+}
+
+// ErrTruncated is returned when a resolver's UDP response was truncated and the
+// TCP retry failed. It can be returned along with the truncated answers.
+type ErrTruncated struct {
+	Hostname string
+	Resolver string
+	Err      error
+}
+
+// Error implements the error interface
+func (err *ErrTruncated) Error() string {
+	return fmt.Sprintf("truncated response looking up %q on %s: %s", err.Hostname, err.Resolver, err.Err)
+}
+
+// Unwrap returns the error from the TCP retry
+func (err *ErrTruncated) Unwrap() error {
+	return err.Err
+}
+
+// Is allows errors.Is(err, &ErrTruncated{}) to match any ErrTruncated
+func (err *ErrTruncated) Is(target error) bool {
+	_, ok := target.(*ErrTruncated)
+	return ok
+}
+
+// ErrInvalidResponse is returned, wrapped in an ErrExchange, when a resolver's
+// response didn't match the query which was sent or was too large, e.g.
+// because it was spoofed or malformed
+type ErrInvalidResponse struct {
+	Hostname string
+	Resolver string
+	Reason   string
+}
+
+// Error implements the error interface
+func (err *ErrInvalidResponse) Error() string {
+	return fmt.Sprintf("invalid response looking up %q on %s: %s", err.Hostname, err.Resolver, err.Reason)
+}
+
+// Is allows errors.Is(err, &ErrInvalidResponse{}) to match any
+// ErrInvalidResponse
+func (err *ErrInvalidResponse) Is(target error) bool {
+	_, ok := target.(*ErrInvalidResponse)
+	return ok
+}
+
+// ErrLimitExceeded is returned, wrapped in an ErrExchange, when a resolver's
+// response exceeded one of the SRVClient's limits, i.e. MaxAnswers or
+// MaxResponseSize
+type ErrLimitExceeded struct {
+	Hostname string
+	Resolver string
+
+	// Limit describes the limit which was exceeded, e.g. "answers". Max is the
+	// limit and N is the response's actual value.
+	Limit string
+	Max   int
+	N     int
+}
+
+// Error implements the error interface
+func (err *ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("response looking up %q on %s exceeded %s limit (%d > %d)", err.Hostname, err.Resolver, err.Limit, err.N, err.Max)
+}
+
+// Is allows errors.Is(err, &ErrLimitExceeded{}) to match any ErrLimitExceeded
+func (err *ErrLimitExceeded) Is(target error) bool {
+	_, ok := target.(*ErrLimitExceeded)
+	return ok
+}
+
+// ErrTooFewAnswers is returned when a resolver's response contained fewer SRV
+// records than the SRVClient's MinAnswers
+type ErrTooFewAnswers struct {
+	Hostname string
+	Resolver string
+	Min      int
+	N        int
+}
+
+// Error implements the error interface
+func (err *ErrTooFewAnswers) Error() string {
+	return fmt.Sprintf("only %d of at least %d SRV records for %q on %s", err.N, err.Min, err.Hostname, err.Resolver)
+}
+
+// Is allows errors.Is(err, &ErrTooFewAnswers{}) to match any ErrTooFewAnswers
+func (err *ErrTooFewAnswers) Is(target error) bool {
+	_, ok := target.(*ErrTooFewAnswers)
+	return ok
+}
+
+func rcodeString(rcode int) string {
+	if s, ok := dns.RcodeToString[rcode]; ok {
+		return s
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// wrapExchangeErr wraps an error returned from exchanging with a resolver into
+// either an ErrTimeout or ErrExchange
+func wrapExchangeErr(hostname, server string, err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &ErrTimeout{Hostname: hostname, Resolver: server, Err: err}
+	}
+	return &ErrExchange{Hostname: hostname, Resolver: server, Err: err}
+}
@@ -0,0 +1,155 @@
+package srvclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closedAddr returns a udp address which nothing is listening on
+func closedAddr(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := conn.LocalAddr().String()
+	conn.Close()
+	return addr
+}
+
+func TestWrapExchangeErr(t *testing.T) {
+	err := wrapExchangeErr("foo", "1.2.3.4:53", context.DeadlineExceeded)
+	assert.IsType(t, &ErrTimeout{}, err)
+	assert.True(t, errors.Is(err, &ErrTimeout{}))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, errors.Is(err, &ErrExchange{}))
+	var terr *ErrTimeout
+	require.True(t, errors.As(err, &terr))
+	assert.Equal(t, "foo", terr.Hostname)
+	assert.Equal(t, "1.2.3.4:53", terr.Resolver)
+
+	err = wrapExchangeErr("foo", "1.2.3.4:53", context.Canceled)
+	assert.IsType(t, &ErrExchange{}, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestErrorTypes(t *testing.T) {
+	servfailAddr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+	})
+	// handles udp but not tcp
+	udpAddr := startTestServer(t, handleRequest)
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{servfailAddr}
+	_, err := client.SRV(testHostname)
+	var rerr *ErrRcode
+	require.True(t, errors.As(err, &rerr))
+	assert.Equal(t, dns.RcodeServerFailure, rerr.Rcode)
+	assert.Equal(t, testHostname, rerr.Hostname)
+	assert.Equal(t, servfailAddr, rerr.Resolver)
+
+	client = SRVClient{}
+	client.ResolverAddrs = []string{closedAddr(t)}
+	_, err = client.SRV(testHostname)
+	assert.True(t, errors.Is(err, &ErrExchange{}), "%v", err)
+
+	// the tcp retry will fail but we should still get the truncated answer
+	client = SRVClient{}
+	client.ResolverAddrs = []string{udpAddr}
+	r, err := client.SRV(testHostnameTruncated)
+	assert.True(t, errors.Is(err, &ErrTruncated{}), "%v", err)
+	assert.True(t, r == "10.0.0.1:1000" || r == "[2607:5300:60:92e7::1]:1001")
+
+	client = SRVClient{}
+	client.ResolverAddrs = []string{udpAddr}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = client.SRVContext(ctx, testHostname)
+	assert.True(t, errors.Is(err, &ErrTimeout{}), "%v", err)
+}
+
+func rcodeServer(t *testing.T, rcode int) string {
+	return startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		w.WriteMsg(m)
+	})
+}
+
+func TestRcodeErrors(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = []string{rcodeServer(t, dns.RcodeNameError)}
+	_, err := client.SRV(testHostname)
+	assert.IsType(t, &ErrNotFound{}, err)
+	assert.True(t, errors.Is(err, ErrNXDomain))
+	assert.False(t, errors.Is(err, ErrServFail))
+
+	client = SRVClient{}
+	client.ResolverAddrs = []string{rcodeServer(t, dns.RcodeServerFailure)}
+	_, err = client.SRV(testHostname)
+	assert.IsType(t, &ErrRcode{}, err)
+	assert.True(t, errors.Is(err, ErrServFail))
+	assert.True(t, errors.Is(err, &ErrRcode{}))
+	assert.False(t, errors.Is(err, ErrNXDomain))
+
+	client = SRVClient{}
+	client.ResolverAddrs = []string{rcodeServer(t, dns.RcodeRefused)}
+	_, err = client.SRV(testHostname)
+	assert.True(t, errors.Is(err, ErrRefused))
+
+	// NOERROR with no answers is only ErrNotFound
+	_, err = DefaultSRVClient.SRV("fail")
+	assert.IsType(t, &ErrNotFound{}, err)
+	assert.False(t, errors.Is(err, &ErrRcode{}))
+}
+
+func TestRejectedRcodes(t *testing.T) {
+	good := DefaultSRVClient.ResolverAddrs[:1]
+	for _, rcode := range []int{dns.RcodeRefused, dns.RcodeNotImplemented} {
+		for _, strategy := range []ResolverStrategy{StrategySequential, StrategyParallel} {
+			client := SRVClient{
+				ResolverAddrs:    append([]string{rcodeServer(t, rcode)}, good...),
+				ResolverStrategy: strategy,
+			}
+			r, err := client.SRV(testHostname)
+			require.NoError(t, err)
+			assert.True(t, r == "10.0.0.1:1000" || r == "[2607:5300:60:92e7::1]:1001", r)
+			if strategy == StrategySequential {
+				assert.Equal(t, int64(1), client.Stats().RejectedResponses)
+			}
+		}
+	}
+
+	// the rcode is still returned if every resolver rejects the query
+	client := SRVClient{
+		ResolverAddrs: []string{rcodeServer(t, dns.RcodeNotImplemented), rcodeServer(t, dns.RcodeRefused)},
+	}
+	_, err := client.SRV(testHostname)
+	assert.True(t, errors.Is(err, ErrRefused), "%v", err)
+	assert.Equal(t, int64(2), client.Stats().RejectedResponses)
+
+	// other rcodes are still a final answer
+	client = SRVClient{
+		ResolverAddrs: append([]string{rcodeServer(t, dns.RcodeServerFailure)}, good...),
+	}
+	_, err = client.SRV(testHostname)
+	assert.True(t, errors.Is(err, ErrServFail), "%v", err)
+}
+
+func TestErrNotFound(t *testing.T) {
+	_, err := DefaultSRVClient.SRV("fail")
+	assert.True(t, errors.Is(err, ErrNoRecords))
+	assert.True(t, errors.Is(err, &ErrNotFound{}))
+	var nerr *ErrNotFound
+	require.True(t, errors.As(err, &nerr))
+	assert.Equal(t, "fail", nerr.Hostname())
+
+	assert.False(t, errors.Is(&ErrRcode{Rcode: dns.RcodeServerFailure}, ErrNoRecords))
+}
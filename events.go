@@ -0,0 +1,92 @@
+package srvclient
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the type of an Event
+type EventType int
+
+// The EventTypes which are sent to SRVClient.Events
+const (
+	// EventQueryStarted is sent when a lookup of Question with the type
+	// Qtype starts, before any resolvers are queried
+	EventQueryStarted EventType = iota
+
+	// EventResolverTried is sent after every exchange with a Resolver, with
+	// its Net, RTT and Err
+	EventResolverTried
+
+	// EventTruncated is sent when a Resolver's UDP response was truncated
+	EventTruncated
+
+	// EventTCPFallback is sent when a truncated query is retried over TCP
+	EventTCPFallback
+
+	// EventCacheLastServed is sent when the last successful response for
+	// Question was used because the query failed
+	EventCacheLastServed
+
+	// EventAnswerChosen is sent when a record has been picked for Question by
+	// one of the SRV methods, with its address as Answer
+	EventAnswerChosen
+)
+
+// String returns the name of the event type
+func (t EventType) String() string {
+	switch t {
+	case EventQueryStarted:
+		return "query started"
+	case EventResolverTried:
+		return "resolver tried"
+	case EventTruncated:
+		return "truncated"
+	case EventTCPFallback:
+		return "tcp fallback"
+	case EventCacheLastServed:
+		return "cache last served"
+	case EventAnswerChosen:
+		return "answer chosen"
+	}
+	return "unknown"
+}
+
+// Event describes something which happened during a lookup, see
+// SRVClient.Events. Only the fields relevant to its Type are set.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Question is the name being looked up, and Qtype the type of records
+	Question string
+	Qtype    uint16
+
+	Resolver string
+	Net      string
+	RTT      time.Duration
+	Err      error
+
+	// Answer is the address ("host:port") which was picked
+	Answer string
+}
+
+// emit sends ev to Events, if set, without blocking
+func (sc *SRVClient) emit(ev Event) {
+	if sc.Events == nil {
+		return
+	}
+	ev.Time = time.Now()
+	select {
+	case sc.Events <- ev:
+	default:
+		atomic.AddInt64(&sc.numDroppedEvents, 1)
+	}
+}
+
+// cacheLastQuestion returns the name a cacheLastKey is for
+func cacheLastQuestion(key string) string {
+	question, _, _ := strings.Cut(key, ":")
+	return question
+}
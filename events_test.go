@@ -0,0 +1,92 @@
+package srvclient
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drainEvents(ch chan Event) []Event {
+	var evs []Event
+	for {
+		select {
+		case ev := <-ch:
+			evs = append(evs, ev)
+		default:
+			return evs
+		}
+	}
+}
+
+func eventTypes(evs []Event) []EventType {
+	types := make([]EventType, len(evs))
+	for i, ev := range evs {
+		types[i] = ev.Type
+	}
+	return types
+}
+
+func TestEvents(t *testing.T) {
+	ch := make(chan Event, 10)
+	client := SRVClient{Events: ch}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+
+	addr, err := client.SRV(testHostnameTruncated)
+	require.NoError(t, err)
+	evs := drainEvents(ch)
+	assert.Equal(t, []EventType{
+		EventQueryStarted,
+		EventResolverTried,
+		EventTruncated,
+		EventTCPFallback,
+		EventResolverTried,
+		EventAnswerChosen,
+	}, eventTypes(evs))
+	assert.Equal(t, "trunc.test.test.", evs[0].Question)
+	assert.Equal(t, client.ResolverAddrs[0], evs[1].Resolver)
+	assert.Equal(t, NetUDP, evs[1].Net)
+	assert.Equal(t, NetTCP, evs[4].Net)
+	assert.Equal(t, addr, evs[5].Answer)
+	assert.False(t, evs[0].Time.IsZero())
+
+	// the last response is served once the resolver starts failing
+	var failing int32
+	failAddr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if atomic.LoadInt32(&failing) == 1 {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeServerFailure)
+			w.WriteMsg(m)
+			return
+		}
+		handleRequest(w, r)
+	})
+	client = SRVClient{Events: ch}
+	client.ResolverAddrs = []string{failAddr}
+	client.EnableCacheLast()
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+	drainEvents(ch)
+	atomic.StoreInt32(&failing, 1)
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+	evs = drainEvents(ch)
+	assert.Equal(t, []EventType{
+		EventQueryStarted,
+		EventResolverTried,
+		EventCacheLastServed,
+		EventAnswerChosen,
+	}, eventTypes(evs))
+	assert.Equal(t, "srv.test.test.", evs[2].Question)
+
+	// events which don't fit are dropped
+	full := make(chan Event, 1)
+	client = SRVClient{Events: full}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, drainEvents(full), 1)
+	assert.Equal(t, int64(2), client.Stats().DroppedEvents)
+}
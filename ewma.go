@@ -0,0 +1,131 @@
+package srvclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultEWMADecay is the Decay used by EWMAPicker when none is set
+const DefaultEWMADecay = 0.2
+
+// EWMAPicker is a Picker which maintains an exponentially weighted moving
+// average of the latency to each target and picks the target with the lowest
+// latency amongst the records with the lowest priority. Latencies are fed in
+// using ReportLatency or Probe. If none of the lowest priority records have any
+// latency data then Fallback is used.
+//
+// Latencies are tracked per target host, not per port.
+type EWMAPicker struct {
+	// Decay is the weight, between 0 and 1, given to each new sample. If 0 then
+	// DefaultEWMADecay is used.
+	Decay float64
+
+	// Fallback is used when there is no latency data for any of the candidate
+	// records. If nil then WeightedPicker is used.
+	Fallback Picker
+
+	l         sync.RWMutex
+	latencies map[string]float64
+}
+
+func ewmaKey(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+// ReportLatency records a latency sample for the given address, which can
+// either be a "host:port" as returned from the SRV methods or just the host.
+func (p *EWMAPicker) ReportLatency(addr string, d time.Duration) {
+	decay := p.Decay
+	if decay <= 0 || decay > 1 {
+		decay = DefaultEWMADecay
+	}
+	key := ewmaKey(addr)
+
+	p.l.Lock()
+	defer p.l.Unlock()
+	if p.latencies == nil {
+		p.latencies = map[string]float64{}
+	}
+	if prev, ok := p.latencies[key]; ok {
+		p.latencies[key] = decay*float64(d) + (1-decay)*prev
+	} else {
+		p.latencies[key] = float64(d)
+	}
+}
+
+// Latency returns the current average latency for the given address, and false
+// if there is no data for it
+func (p *EWMAPicker) Latency(addr string) (time.Duration, bool) {
+	p.l.RLock()
+	defer p.l.RUnlock()
+	l, ok := p.latencies[ewmaKey(addr)]
+	return time.Duration(l), ok
+}
+
+// Forget removes any latency data for the given address
+func (p *EWMAPicker) Forget(addr string) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	delete(p.latencies, ewmaKey(addr))
+}
+
+// Probe dials each of the given addresses over network (e.g. "tcp") and reports
+// the time taken to connect as its latency. Addresses which fail to connect are
+// skipped and the first error is returned.
+func (p *EWMAPicker) Probe(ctx context.Context, network string, addrs ...string) error {
+	var d net.Dialer
+	var firstErr error
+	for _, addr := range addrs {
+		start := time.Now()
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		p.ReportLatency(addr, time.Since(start))
+		conn.Close()
+	}
+	return firstErr
+}
+
+// Pick implements the Picker interface
+func (p *EWMAPicker) Pick(srvs []*dns.SRV) *dns.SRV {
+	lowPrio := srvs[0].Priority
+	for _, srv := range srvs {
+		if srv.Priority < lowPrio {
+			lowPrio = srv.Priority
+		}
+	}
+
+	var best *dns.SRV
+	var bestL float64
+	p.l.RLock()
+	for _, srv := range srvs {
+		if srv.Priority != lowPrio {
+			continue
+		}
+		l, ok := p.latencies[ewmaKey(srv.Target)]
+		if ok && (best == nil || l < bestL) {
+			best = srv
+			bestL = l
+		}
+	}
+	p.l.RUnlock()
+
+	if best != nil {
+		return best
+	}
+	if p.Fallback == nil {
+		return pickSRV(srvs)
+	}
+	return p.Fallback.Pick(srvs)
+}
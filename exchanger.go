@@ -0,0 +1,114 @@
+package srvclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The transports which can be set on SRVClient.Net
+const (
+	NetUDP   = "udp"
+	NetTCP   = "tcp"
+	NetTLS   = "tcp-tls"
+	NetHTTPS = "https"
+)
+
+// Exchanger performs a single DNS exchange with a server. *dns.Client
+// implements it.
+type Exchanger interface {
+	ExchangeContext(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, time.Duration, error)
+}
+
+// udpSize returns the size which should be advertised using EDNS0 for queries
+// made with the given Exchanger, or 0 if none should be
+func udpSize(c Exchanger) uint16 {
+	switch c := c.(type) {
+	case *dns.Client:
+		if c.Net == "" || c.Net == NetUDP {
+			return c.UDPSize
+		}
+	case *udpMux:
+		return c.udpSize
+	case *hardenedUDP:
+		return c.udpSize
+	}
+	return 0
+}
+
+// dohClient is an Exchanger which performs DNS over HTTPS, as described in RFC
+// 8484
+type dohClient struct {
+	client *http.Client
+}
+
+func newDoHClient(tlsConfig *tls.Config, timeout time.Duration) *dohClient {
+	return &dohClient{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				TLSClientConfig:   tlsConfig,
+				ForceAttemptHTTP2: true,
+			},
+		},
+	}
+}
+
+// dohURL returns the URL to query for the given server, which can either be a
+// full URL or a "host:port", in which case the conventional /dns-query path is
+// used
+func dohURL(server string) string {
+	if strings.HasPrefix(server, "https://") {
+		return server
+	}
+	return "https://" + server + "/dns-query"
+}
+
+// ExchangeContext implements the Exchanger interface
+func (dc *dohClient) ExchangeContext(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	// RFC 8484 recommends an ID of 0 to make responses more cacheable so we
+	// restore the original once we're done
+	id := m.Id
+	m.Id = 0
+	b, err := m.Pack()
+	m.Id = id
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", dohURL(server), bytes.NewReader(b))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	start := time.Now()
+	resp, err := dc.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, err
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, rtt, fmt.Errorf("unexpected status from %s: %s", server, resp.Status)
+	}
+
+	res := new(dns.Msg)
+	if err := res.Unpack(body); err != nil {
+		return nil, rtt, err
+	}
+	res.Id = id
+	return res, rtt, nil
+}
@@ -0,0 +1,77 @@
+package srvclient
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// msgWriter is a dns.ResponseWriter which just holds onto the written msg
+type msgWriter struct {
+	msg *dns.Msg
+}
+
+func (w *msgWriter) LocalAddr() net.Addr       { return &net.TCPAddr{} }
+func (w *msgWriter) RemoteAddr() net.Addr      { return &net.TCPAddr{} }
+func (w *msgWriter) WriteMsg(m *dns.Msg) error { w.msg = m; return nil }
+func (w *msgWriter) Write([]byte) (int, error) { return 0, nil }
+func (w *msgWriter) Close() error              { return nil }
+func (w *msgWriter) TsigStatus() error         { return nil }
+func (w *msgWriter) TsigTimersOnly(bool)       {}
+func (w *msgWriter) Hijack()                   {}
+func (w *msgWriter) Network() string           { return "tcp" }
+
+func TestNetTCP(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	client.Net = NetTCP
+
+	// the tcp handler returns different ips for the truncated hostname
+	r, err := client.SRV(testHostnameTruncated)
+	require.NoError(t, err)
+	assert.True(t, r == "10.0.0.2:1000" || r == "[2607:5300:60:92e7::2]:1001")
+	assert.EqualValues(t, 0, client.Stats().UDPQueries)
+	assert.EqualValues(t, 1, client.Stats().TCPQueries)
+}
+
+func TestNetHTTPS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/dns-query", r.URL.Path)
+		assert.Equal(t, "application/dns-message", r.Header.Get("Content-Type"))
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := new(dns.Msg)
+		require.NoError(t, req.Unpack(b))
+		assert.Zero(t, req.Id)
+
+		mw := new(msgWriter)
+		handleRequest(mw, req)
+		b, err = mw.msg.Pack()
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{srv.Listener.Addr().String()}
+	client.Net = NetHTTPS
+	client.TLSConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+
+	r, err := client.SRV(testHostname)
+	require.NoError(t, err)
+	assert.True(t, r == "10.0.0.1:1000" || r == "[2607:5300:60:92e7::1]:1001")
+
+	client = SRVClient{}
+	client.ResolverAddrs = []string{srv.URL + "/dns-query"}
+	client.Net = NetHTTPS
+	client.TLSConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+}
@@ -0,0 +1,120 @@
+package srvclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// cryptoID returns a message ID read directly from crypto/rand, rather than
+// using dns.Id, which can be replaced by other packages
+func cryptoID() uint16 {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("srvclient: reading random id failed: " + err.Error())
+	}
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// hardenedUDP is the Exchanger used for UDP when HardenUDP is set. Each query
+// is sent from a new unconnected socket with a fresh ID, so that responses from
+// addresses other than the resolver can be seen and counted, rather than being
+// silently dropped by the kernel, as can responses which don't match the
+// query's ID and question. Both are ignored, and the exchange keeps waiting for
+// the real response.
+type hardenedUDP struct {
+	sc      *SRVClient
+	udpSize uint16
+}
+
+// ExchangeContext implements the Exchanger interface
+func (h *hardenedUDP) ExchangeContext(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	raddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	origID := m.Id
+	m.Id = cryptoID()
+	id := m.Id
+	b, err := m.Pack()
+	m.Id = origID
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteToUDP(b, raddr); err != nil {
+		return nil, 0, ctxErr(ctx, err)
+	}
+
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, time.Since(start), ctxErr(ctx, err)
+		}
+		if !sameSource(addr, raddr) {
+			atomic.AddInt64(&h.sc.numMismatchedSources, 1)
+			continue
+		}
+		res := new(dns.Msg)
+		if err := res.Unpack(buf[:n]); err != nil {
+			continue
+		}
+		if res.Id != id || !sameQuestion(m, res) {
+			atomic.AddInt64(&h.sc.numMismatchedIDs, 1)
+			continue
+		}
+		res.Id = origID
+		return res, time.Since(start), nil
+	}
+}
+
+// sameSource returns whether a response from addr could have come from the
+// resolver at raddr. Queries to an unspecified address, e.g. "[::]:53", are
+// sent to the local host, so those can be answered from any loopback address.
+func sameSource(addr, raddr *net.UDPAddr) bool {
+	if addr.Port != raddr.Port {
+		return false
+	} else if raddr.IP.IsUnspecified() {
+		return addr.IP.IsLoopback() || addr.IP.IsUnspecified()
+	}
+	return addr.IP.Equal(raddr.IP)
+}
+
+func sameQuestion(m, res *dns.Msg) bool {
+	if len(res.Question) != 1 || len(m.Question) != 1 {
+		return false
+	}
+	q, rq := m.Question[0], res.Question[0]
+	return q.Qtype == rq.Qtype && q.Qclass == rq.Qclass && strings.EqualFold(q.Name, rq.Name)
+}
+
+// ctxErr returns the context's error if it's done, since that's the reason a
+// read or write was interrupted, and err otherwise
+func ctxErr(ctx context.Context, err error) error {
+	if cerr := ctx.Err(); cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package srvclient
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardenUDP(t *testing.T) {
+	spoofer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer spoofer.Close()
+
+	var ids []uint16
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		ids = append(ids, r.Id)
+
+		// a response from a different port, which can't be trusted
+		m := new(dns.Msg)
+		m.SetReply(r)
+		b, _ := m.Pack()
+		spoofer.WriteTo(b, w.RemoteAddr())
+
+		// and ones with the wrong ID and question
+		m.Id++
+		w.WriteMsg(m)
+		m = new(dns.Msg)
+		m.SetReply(r)
+		m.Question[0].Name = "other.test."
+		w.WriteMsg(m)
+
+		handleRequest(w, r)
+	})
+
+	client := SRVClient{HardenUDP: true}
+	client.ResolverAddrs = []string{addr}
+	srvs, err := client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, srvs, 2)
+
+	stats := client.Stats()
+	assert.Equal(t, int64(1), stats.MismatchedSources)
+	assert.Equal(t, int64(2), stats.MismatchedIDs)
+
+	// truncated responses still fall back to TCP
+	client2 := SRVClient{HardenUDP: true}
+	client2.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	_, err = client2.SRV(testHostnameTruncated)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), client2.Stats().TruncatedResponses)
+}
@@ -0,0 +1,54 @@
+package srvclient
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// idnaProfile converts between Unicode hostnames and their punycode A-labels.
+// Underscores are allowed since they're used by SRV service and proto labels.
+var idnaProfile = idna.New(
+	idna.MapForLookup(),
+	idna.StrictDomainName(false),
+	idna.BidiRule(),
+)
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// toASCII converts a Unicode hostname to its punycode form. ASCII hostnames are
+// returned as-is.
+func toASCII(hostname string) (string, error) {
+	if isASCII(hostname) {
+		return hostname, nil
+	}
+	a, err := idnaProfile.ToASCII(strings.TrimSuffix(hostname, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid hostname %q: %w", hostname, err)
+	}
+	return dns.Fqdn(a), nil
+}
+
+// unicodeSRV returns a copy of srv with its target converted from punycode to
+// Unicode, or srv itself if its target doesn't contain any punycode labels
+func unicodeSRV(srv *dns.SRV) *dns.SRV {
+	if !strings.Contains(srv.Target, "xn--") {
+		return srv
+	}
+	u, err := idnaProfile.ToUnicode(srv.Target)
+	if err != nil {
+		return srv
+	}
+	srv = dns.Copy(srv).(*dns.SRV)
+	srv.Target = u
+	return srv
+}
@@ -0,0 +1,37 @@
+package srvclient
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnicodeTargets(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Name == "_http._tcp.xn--bcher-kva.test." {
+			m.Answer = []dns.RR{
+				newRR("_http._tcp.xn--bcher-kva.test. 60 IN SRV 0 0 80 www.xn--bcher-kva.test."),
+			}
+		}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	srvs, err := client.AllSRV("_http._tcp.bücher.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.xn--bcher-kva.test.:80"}, srvs)
+
+	client.UnicodeTargets = true
+	srvs, err = client.AllSRV("_http._tcp.bücher.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.bücher.test.:80"}, srvs)
+
+	res, err := client.LookupSRV("_http._tcp.bücher.test")
+	require.NoError(t, err)
+	assert.Equal(t, "www.bücher.test.", res.Records[0].Target)
+}
@@ -0,0 +1,43 @@
+package srvclient
+
+import (
+	"net"
+	"sort"
+)
+
+// IPPreference determines which of a SRV target's IPs are used when
+// translating it, if the target has both IPv4 and IPv6 addresses
+type IPPreference int
+
+// The IPPreference values which can be set on SRVClient.IPPreference
+const (
+	// PreferFirst uses whichever address is first in the response
+	PreferFirst IPPreference = iota
+
+	// PreferIPv4 uses an IPv4 address if there is one
+	PreferIPv4
+
+	// PreferIPv6 uses an IPv6 address if there is one
+	PreferIPv6
+
+	// PreferAll behaves like PreferFirst for methods which return a single
+	// address, but causes the translating methods which return all addresses,
+	// like AllSRVTranslate, to return an address for every IP of each target
+	PreferAll
+)
+
+// orderIPs sorts ips in place so that the preferred addresses are first, and
+// returns them
+func orderIPs(ips []net.IP, pref IPPreference) []net.IP {
+	if pref != PreferIPv4 && pref != PreferIPv6 {
+		return ips
+	}
+	sort.SliceStable(ips, func(i, j int) bool {
+		iv4, jv4 := ips[i].To4() != nil, ips[j].To4() != nil
+		if pref == PreferIPv4 {
+			return iv4 && !jv4
+		}
+		return !iv4 && jv4
+	})
+	return ips
+}
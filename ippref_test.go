@@ -0,0 +1,49 @@
+package srvclient
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPPreference(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{
+			newRR("dual.test. 60 IN SRV 0 0 1000 1.dual.test."),
+			newRR("dual.test. 60 IN SRV 1 0 1001 2.dual.test."),
+		}
+		m.Extra = []dns.RR{
+			newRR("1.dual.test. 60 IN AAAA ::1"),
+			newRR("1.dual.test. 60 IN A 10.0.0.1"),
+			newRR("2.dual.test. 60 IN A 10.0.0.2"),
+		}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	assertAddrs := func(pref IPPreference, expected ...string) {
+		client.IPPreference = pref
+		addrs, err := client.AllSRVTranslate("dual.test")
+		require.NoError(t, err)
+		assert.Equal(t, expected, addrs)
+	}
+
+	assertAddrs(PreferFirst, "[::1]:1000", "10.0.0.2:1001")
+	assertAddrs(PreferIPv4, "10.0.0.1:1000", "10.0.0.2:1001")
+	assertAddrs(PreferIPv6, "[::1]:1000", "10.0.0.2:1001")
+	assertAddrs(PreferAll, "[::1]:1000", "10.0.0.1:1000", "10.0.0.2:1001")
+
+	client.IPPreference = PreferIPv4
+	addr, err := client.SRV("dual.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:1000", addr)
+
+	res, err := client.LookupSRV("dual.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", res.Records[0].IPs[0].String())
+}
@@ -0,0 +1,21 @@
+package srvclient
+
+import "time"
+
+// jitter returns d reduced by a random amount of up to frac of it, i.e. a
+// duration in [d*(1-frac), d], using random as the source. frac is clamped to
+// [0, 1]. Reducing rather than extending means refreshes still happen before a
+// TTL runs out.
+func jitter(d time.Duration, frac float64, random func() float64) time.Duration {
+	if frac <= 0 || d <= 0 {
+		return d
+	} else if frac > 1 {
+		frac = 1
+	}
+	return d - time.Duration(random()*frac*float64(d))
+}
+
+// jitter calls jitter with the SRVClient's Jitter and random source
+func (sc *SRVClient) jitter(d time.Duration) time.Duration {
+	return jitter(d, sc.Jitter, sc.float64)
+}
@@ -0,0 +1,42 @@
+package srvclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, time.Second, jitter(time.Second, 0, randFloat64))
+	assert.Equal(t, time.Duration(0), jitter(0, 0.5, randFloat64))
+
+	var varied bool
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second, 0.2, randFloat64)
+		assert.LessOrEqual(t, d, time.Second)
+		assert.GreaterOrEqual(t, d, 800*time.Millisecond)
+		varied = varied || d != time.Second
+	}
+	assert.True(t, varied)
+
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second, 2, randFloat64)
+		assert.LessOrEqual(t, d, time.Second)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+	}
+}
+
+func TestAttemptContextJitter(t *testing.T) {
+	sc := &SRVClient{Jitter: 0.5}
+	cfg := dns.ClientConfig{Timeout: 2}
+	for i := 0; i < 20; i++ {
+		ctx, cancel := sc.attemptContext(context.Background(), cfg)
+		deadline, ok := ctx.Deadline()
+		cancel()
+		assert.True(t, ok)
+		assert.WithinRange(t, deadline, time.Now().Add(900*time.Millisecond), time.Now().Add(2*time.Second))
+	}
+}
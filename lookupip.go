@@ -0,0 +1,79 @@
+package srvclient
+
+import (
+	"context"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// LookupIP calls the LookupIP method on the DefaultSRVClient
+func LookupIP(host string) ([]net.IP, error) {
+	return DefaultSRVClient.LookupIP(host)
+}
+
+// LookupIPContext calls the LookupIPContext method on the DefaultSRVClient
+func LookupIPContext(ctx context.Context, host string) ([]net.IP, error) {
+	return DefaultSRVClient.LookupIPContext(ctx, host)
+}
+
+// LookupIP calls LookupIPContext with an empty context
+func (sc *SRVClient) LookupIP(host string) ([]net.IP, error) {
+	return sc.LookupIPContext(context.Background(), host)
+}
+
+// LookupIPContext performs A and AAAA queries for host, concurrently, and
+// returns the IPs from both, following any CNAMEs. The queries use the same
+// resolvers, transports, truncation handling and stats as the SRV methods. The
+// IPs are ordered according to IPPreference, with IPv4 addresses first for
+// PreferFirst. If host is an IP then it's returned as-is.
+//
+// An error is only returned if neither query returned any IPs, in which case
+// an ErrNotFound is returned if the queries succeeded.
+func (sc *SRVClient) LookupIPContext(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	type result struct {
+		ips    []net.IP
+		msg    *dns.Msg
+		server string
+		err    error
+	}
+	qtypes := []uint16{dns.TypeA, dns.TypeAAAA}
+	results := make([]result, len(qtypes))
+	done := make(chan struct{}, len(qtypes))
+	for i, qtype := range qtypes {
+		go func(r *result, qtype uint16) {
+			defer func() { done <- struct{}{} }()
+			r.msg, r.server, r.err = sc.lookupMsg(ctx, host, qtype, false)
+			if r.msg != nil && len(r.msg.Question) > 0 {
+				r.ips = addrsFor(r.msg.Question[0].Name, r.msg.Answer)
+			}
+		}(&results[i], qtype)
+	}
+	for range qtypes {
+		<-done
+	}
+
+	var ips []net.IP
+	for _, r := range results {
+		ips = append(ips, r.ips...)
+	}
+	if len(ips) > 0 {
+		return orderIPs(ips, sc.IPPreference), nil
+	}
+
+	for _, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	// neither query errored, so use the A response to determine the error
+	err := noAnswersErr(host, results[0].server, results[0].msg)
+	if nf, ok := err.(*ErrNotFound); ok {
+		nf.types = "A or AAAA"
+	}
+	return nil, err
+}
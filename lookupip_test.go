@@ -0,0 +1,57 @@
+package srvclient
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupIP(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Name == "host.test." && q.Qtype == dns.TypeA:
+			m.Answer = []dns.RR{newRR("host.test. 60 IN A 10.0.0.1")}
+		case q.Name == "host.test." && q.Qtype == dns.TypeAAAA:
+			m.Answer = []dns.RR{newRR("host.test. 60 IN AAAA ::1")}
+		case q.Name == "alias.test." && q.Qtype == dns.TypeA:
+			m.Answer = []dns.RR{
+				newRR("alias.test. 60 IN CNAME host.test."),
+				newRR("host.test. 60 IN A 10.0.0.1"),
+			}
+		case q.Name == "missing.test.":
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	ips, err := client.LookupIP("host.test")
+	require.NoError(t, err)
+	assert.Equal(t, []net.IP{net.ParseIP("10.0.0.1").To4(), net.ParseIP("::1")}, ips)
+
+	client.IPPreference = PreferIPv6
+	ips, err = client.LookupIP("host.test")
+	require.NoError(t, err)
+	assert.Equal(t, "::1", ips[0].String())
+
+	ips, err = client.LookupIP("alias.test")
+	require.NoError(t, err)
+	assert.Equal(t, []net.IP{net.ParseIP("10.0.0.1").To4()}, ips)
+
+	ips, err = client.LookupIP("10.0.0.5")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", ips[0].String())
+
+	_, err = client.LookupIP("missing.test")
+	assert.True(t, errors.Is(err, ErrNoRecords))
+	assert.True(t, errors.Is(err, ErrNXDomain))
+	assert.Contains(t, err.Error(), "No A or AAAA records")
+}
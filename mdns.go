@@ -0,0 +1,78 @@
+package srvclient
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// mdnsAddr is the IPv4 multicast address mDNS queries are sent to, as described
+// by RFC 6762
+var mdnsAddr = "224.0.0.251:5353"
+
+// mdnsTimeout is how long to wait for a response to an mDNS query if the
+// context has no deadline
+const mdnsTimeout = 2 * time.Second
+
+// isMDNSName returns whether the normalized fqdn should be resolved using mDNS
+func isMDNSName(fqdn string) bool {
+	return fqdn == "local." || strings.HasSuffix(fqdn, ".local.")
+}
+
+// mdnsClient is an Exchanger which sends queries over multicast, as a "one-shot"
+// querier described by RFC 6762 section 5.1. Since queries aren't sent from
+// port 5353, responders reply directly to us with a conventional unicast
+// response, and the first one received is used.
+type mdnsClient struct{}
+
+// ExchangeContext implements the Exchanger interface
+func (mdnsClient) ExchangeContext(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	raddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(mdnsTimeout)
+	}
+	conn.SetDeadline(deadline)
+	// close the connection if the context is canceled so the read returns
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	b, err := m.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	start := time.Now()
+	if _, err := conn.WriteToUDP(b, raddr); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return nil, time.Since(start), err
+		}
+		res := new(dns.Msg)
+		// ignore anything which isn't a response to our query, e.g. from other
+		// queriers
+		if res.Unpack(buf[:n]) != nil || !res.Response || res.Id != m.Id {
+			continue
+		}
+		return res, time.Since(start), nil
+	}
+}
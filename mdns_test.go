@@ -0,0 +1,44 @@
+package srvclient
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMDNS(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+		if r.Question[0].Name == "printer._ipp._tcp.local." {
+			srv := newRR("printer._ipp._tcp.local. 120 IN SRV 0 0 631 printer.local.")
+			a := newRR("printer.local. 120 IN A 192.168.1.5")
+			// mDNS responders set the cache-flush bit on unique records
+			a.Header().Class |= 1 << 15
+			m.Answer = []dns.RR{srv}
+			m.Extra = []dns.RR{a}
+		}
+		w.WriteMsg(m)
+	})
+	oldAddr := mdnsAddr
+	mdnsAddr = addr
+	defer func() { mdnsAddr = oldAddr }()
+
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	client.MDNS = true
+	srv, err := client.SRV("printer._ipp._tcp.local")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.5:631", srv)
+
+	// other hostnames still use the resolvers
+	srvs, err := client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, srvs, 2)
+
+	assert.True(t, isMDNSName("local."))
+	assert.False(t, isMDNSName("notlocal."))
+}
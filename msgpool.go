@@ -0,0 +1,77 @@
+package srvclient
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// maxPooledAnswers is the largest answer slice which is returned to srvsPool,
+// so that one unusually large response doesn't pin its memory forever
+const maxPooledAnswers = 64
+
+var (
+	// queryPool holds the messages used as queries. They can be reused once an
+	// exchange is done with them, since none of the Exchangers in this package
+	// or the hooks retain them.
+	queryPool = sync.Pool{
+		New: func() interface{} { return new(dns.Msg) },
+	}
+
+	// srvsPool holds slices for the answers of lookups which only need them
+	// until a record has been picked
+	srvsPool = sync.Pool{
+		New: func() interface{} {
+			s := make([]*dns.SRV, 0, 8)
+			return &s
+		},
+	}
+)
+
+// getQuery returns a query for the given name and type, like SetQuestion, which
+// should be passed to putQuery once it's no longer needed
+func getQuery(fqdn string, qtype uint16) *dns.Msg {
+	m := queryPool.Get().(*dns.Msg)
+	m.Id = dns.Id()
+	m.RecursionDesired = true
+	m.Question = append(m.Question, dns.Question{Name: fqdn, Qtype: qtype, Qclass: dns.ClassINET})
+	return m
+}
+
+// putQuery resets m, keeping the capacity of its slices, and returns it to the
+// pool
+func putQuery(m *dns.Msg) {
+	question, extra := m.Question[:0], m.Extra
+	for i := range extra {
+		extra[i] = nil
+	}
+	*m = dns.Msg{Question: question, Extra: extra[:0]}
+	queryPool.Put(m)
+}
+
+// poolable returns whether queries sent using the given Exchanger can be
+// returned to the pool afterwards. Exchangers set by users might hold on to
+// them, e.g. to record what was queried.
+func poolable(c Exchanger) bool {
+	switch c.(type) {
+	case *dns.Client, *dohClient, *udpMux, *hardenedUDP, mdnsClient:
+		return true
+	}
+	return false
+}
+
+func getSRVs() *[]*dns.SRV {
+	return srvsPool.Get().(*[]*dns.SRV)
+}
+
+// putSRVs clears the records out of s and returns it to the pool
+func putSRVs(s *[]*dns.SRV) {
+	if cap(*s) > maxPooledAnswers {
+		return
+	}
+	for i := range *s {
+		(*s)[i] = nil
+	}
+	*s = (*s)[:0]
+	srvsPool.Put(s)
+}
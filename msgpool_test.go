@@ -0,0 +1,44 @@
+package srvclient
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryPool(t *testing.T) {
+	m := getQuery("foo.test.", dns.TypeSRV)
+	expected := new(dns.Msg)
+	expected.SetQuestion("foo.test.", dns.TypeSRV)
+	expected.Id = m.Id
+	assert.Equal(t, expected.MsgHdr, m.MsgHdr)
+	assert.Equal(t, expected.Question, m.Question)
+	assert.Empty(t, m.Extra)
+
+	m.SetEdns0(4096, false)
+	m.Rcode = dns.RcodeServerFailure
+	putQuery(m)
+	assert.Empty(t, m.Question)
+	assert.Empty(t, m.Extra)
+	assert.Zero(t, m.Rcode)
+
+	m = getQuery("bar.test.", dns.TypeA)
+	require.Len(t, m.Question, 1)
+	assert.Equal(t, "bar.test.", m.Question[0].Name)
+	assert.Equal(t, dns.TypeA, m.Question[0].Qtype)
+	assert.Nil(t, m.IsEdns0())
+	putQuery(m)
+
+	assert.True(t, poolable(new(dns.Client)))
+	assert.False(t, poolable(new(Replayer)))
+}
+
+func TestSRVsPool(t *testing.T) {
+	s := getSRVs()
+	*s = append(*s, &dns.SRV{Target: "foo.test."})
+	putSRVs(s)
+	assert.Empty(t, *s)
+	assert.Nil(t, (*s)[:1][0])
+}
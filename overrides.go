@@ -0,0 +1,196 @@
+package srvclient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// overrideEntry is a single target of an override
+type overrideEntry struct {
+	host   string
+	port   uint16
+	weight uint16
+}
+
+// parseOverride parses a "host:port" entry from Overrides. The port can also be
+// a service name, e.g. "https".
+func parseOverride(entry string) (overrideEntry, error) {
+	host, portStr, err := net.SplitHostPort(entry)
+	if err != nil {
+		return overrideEntry{}, err
+	} else if host == "" {
+		return overrideEntry{}, fmt.Errorf("missing host in %q", entry)
+	}
+	port, err := strconv.ParseUint(portNumber(portStr), 10, 16)
+	if err != nil {
+		return overrideEntry{}, fmt.Errorf("invalid port in %q", entry)
+	}
+	return overrideEntry{host: host, port: uint16(port)}, nil
+}
+
+// staticOverride returns the entries from Overrides for the normalized fqdn,
+// and whether there were any
+func (sc *SRVClient) staticOverride(fqdn string) ([]overrideEntry, bool, error) {
+	for hostname, addrs := range sc.Overrides {
+		if strings.ToLower(dns.Fqdn(hostname)) != fqdn {
+			continue
+		}
+		entries := make([]overrideEntry, 0, len(addrs))
+		for _, addr := range addrs {
+			e, err := parseOverride(addr)
+			if err != nil {
+				return nil, true, fmt.Errorf("override for %q: %w", hostname, err)
+			}
+			entries = append(entries, e)
+		}
+		return entries, len(entries) > 0, nil
+	}
+	return nil, false, nil
+}
+
+// overridesFile holds the last successfully loaded version of OverridesFile,
+// keyed by normalized fqdn
+type overridesFile struct {
+	l       sync.Mutex
+	checked time.Time
+	modTime time.Time
+	size    int64
+	entries map[string][]overrideEntry
+	err     error
+}
+
+// parseOverridesFile parses the contents of an OverridesFile
+func parseOverridesFile(path string) (map[string][]overrideEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := map[string][]overrideEntry{}
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text, _, _ := strings.Cut(s.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) > 3 || len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected a hostname, target and optional weight", path, line)
+		}
+		e, err := parseOverride(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if len(fields) == 3 {
+			weight, err := strconv.ParseUint(fields[2], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid weight %q", path, line, fields[2])
+			}
+			e.weight = uint16(weight)
+		}
+		fqdn := strings.ToLower(dns.Fqdn(fields[0]))
+		entries[fqdn] = append(entries[fqdn], e)
+	}
+	return entries, s.Err()
+}
+
+// load reloads the file if it's been modified since it was last loaded, and
+// it hasn't been checked within the reloadInterval
+func (of *overridesFile) load(path string) {
+	now := time.Now()
+	if !of.checked.IsZero() && now.Sub(of.checked) < reloadInterval {
+		return
+	}
+	of.checked = now
+
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		of.entries, of.err = nil, nil
+		of.modTime, of.size = time.Time{}, 0
+		return
+	} else if err != nil {
+		of.err = err
+		return
+	} else if of.entries != nil && fi.ModTime().Equal(of.modTime) && fi.Size() == of.size {
+		return
+	}
+
+	entries, err := parseOverridesFile(path)
+	if err != nil {
+		of.err = err
+		return
+	}
+	of.entries, of.err = entries, nil
+	of.modTime, of.size = fi.ModTime(), fi.Size()
+}
+
+// fileOverride returns the entries from OverridesFile for the normalized fqdn,
+// and whether there were any
+func (sc *SRVClient) fileOverride(fqdn string) ([]overrideEntry, bool) {
+	if sc.OverridesFile == "" {
+		return nil, false
+	}
+	of := &sc.overridesFile
+	of.l.Lock()
+	defer of.l.Unlock()
+	of.load(sc.OverridesFile)
+	entries := of.entries[fqdn]
+	return entries, len(entries) > 0
+}
+
+// overridesFileErr returns the error from the last attempt to load
+// OverridesFile, if it failed
+func (sc *SRVClient) overridesFileErr() error {
+	of := &sc.overridesFile
+	of.l.Lock()
+	defer of.l.Unlock()
+	return of.err
+}
+
+// overrideMsg returns the SRV response which is used in place of querying the
+// resolvers for the normalized fqdn, or nil if it isn't overridden. Targets
+// which are names, rather than IPs, are made fully qualified like the ones in
+// real responses. Since the response has no extra section, they're only
+// translated into IPs by follow-up lookups, i.e. when ResolveTargets or
+// IgnoreExtra is set, and are otherwise returned as they are.
+func (sc *SRVClient) overrideMsg(fqdn string) (*dns.Msg, error) {
+	entries, ok := sc.fileOverride(fqdn)
+	if !ok {
+		var err error
+		if entries, ok, err = sc.staticOverride(fqdn); err != nil || !ok {
+			return nil, err
+		}
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(fqdn, dns.TypeSRV)
+	msg.Response = true
+	msg.Authoritative = true
+	for _, e := range entries {
+		target := e.host
+		if net.ParseIP(target) == nil {
+			target = dns.Fqdn(target)
+		}
+		msg.Answer = append(msg.Answer, &dns.SRV{
+			Hdr: dns.RR_Header{
+				Name:   fqdn,
+				Rrtype: dns.TypeSRV,
+				Class:  dns.ClassINET,
+			},
+			Weight: e.weight,
+			Port:   e.port,
+			Target: target,
+		})
+	}
+	return msg, nil
+}
@@ -0,0 +1,110 @@
+package srvclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverrides(t *testing.T) {
+	sc := SRVClient{
+		Overrides: map[string][]string{
+			"Pinned.Test.": {"10.1.0.1:2000", "[::1]:2001"},
+			"named.test":   {testHostnameNoSRV + ":https"},
+			"bad.test":     {"10.1.0.1"},
+		},
+		ResolveTargets: true,
+	}
+	sc.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+
+	addrs, err := sc.AllSRV("pinned.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.1.0.1:2000", "[::1]:2001"}, addrs)
+	// the resolvers weren't queried
+	assert.Zero(t, sc.Stats().UDPQueries)
+
+	addr, err := sc.SRV("pinned.test:80")
+	require.NoError(t, err)
+	assert.Contains(t, []string{"10.1.0.1:80", "[::1]:80"}, addr)
+
+	// names are translated using their own lookups
+	addr, err = sc.SRV("named.test")
+	require.NoError(t, err)
+	assert.Equal(t, "11.0.0.1:443", addr)
+	addr, err = sc.SRVNoTranslate("named.test")
+	require.NoError(t, err)
+	assert.Equal(t, "test.test.:443", addr)
+
+	_, err = sc.SRV("bad.test")
+	assert.Error(t, err)
+
+	// other hostnames are looked up normally
+	addrs, err = sc.AllSRVTranslate(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:1000", "[2607:5300:60:92e7::1]:1001"}, addrs)
+}
+
+func TestOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides")
+	sc := SRVClient{
+		Overrides:     map[string][]string{"pinned.test": {"10.1.0.1:2000"}},
+		OverridesFile: path,
+	}
+	sc.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	// makes the next lookup check the file
+	recheck := func() {
+		sc.overridesFile.l.Lock()
+		sc.overridesFile.checked = time.Time{}
+		sc.overridesFile.modTime = time.Time{}
+		sc.overridesFile.l.Unlock()
+	}
+	reload := func(contents string) {
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+		recheck()
+	}
+
+	// the file doesn't exist yet, so only Overrides are used
+	addrs, err := sc.AllSRV("pinned.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.1.0.1:2000"}, addrs)
+
+	reload(`
+# break-glass
+pinned.test 10.2.0.1:3000 10
+Pinned.Test. 10.2.0.2:3001   # no weight
+srv.test.test [::1]:3002 5
+`)
+	lookup := func(hostname string) []Record {
+		res, err := sc.LookupSRV(hostname)
+		require.NoError(t, err)
+		return res.Records
+	}
+	assert.Equal(t, []Record{
+		{Target: "10.2.0.1", Port: 3000, Weight: 10},
+		{Target: "10.2.0.2", Port: 3001},
+	}, lookup("pinned.test"))
+	assert.Equal(t, []Record{{Target: "::1", Port: 3002, Weight: 5}}, lookup(testHostname))
+	assert.Zero(t, sc.Stats().UDPQueries)
+	assert.Empty(t, sc.Describe().OverridesFileError)
+
+	// an invalid file is reported, and the last one keeps being used
+	reload("pinned.test 10.2.0.1\n")
+	assert.Len(t, lookup("pinned.test"), 2)
+	assert.Contains(t, sc.Describe().OverridesFileError, path+":1:")
+
+	// removing a hostname from the file falls back to DNS
+	reload("pinned.test 10.2.0.3:3000\n")
+	assert.Equal(t, []Record{{Target: "10.2.0.3", Port: 3000}}, lookup("pinned.test"))
+	assert.Len(t, lookup(testHostname), 2)
+
+	// removing the file falls back to Overrides
+	require.NoError(t, os.Remove(path))
+	recheck()
+	addrs, err = sc.AllSRV("pinned.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.1.0.1:2000"}, addrs)
+}
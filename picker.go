@@ -0,0 +1,96 @@
+package srvclient
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Picker is used to choose a single record out of the answers of a SRV lookup.
+// Pick will always be given at least one record and must not modify the slice
+// or retain it after returning, since it may be reused.
+type Picker interface {
+	Pick(srvs []*dns.SRV) *dns.SRV
+}
+
+// PickerFunc adapts a function to the Picker interface
+type PickerFunc func(srvs []*dns.SRV) *dns.SRV
+
+// Pick implements the Picker interface
+func (f PickerFunc) Pick(srvs []*dns.SRV) *dns.SRV {
+	return f(srvs)
+}
+
+// WeightedPicker is the default Picker. It chooses randomly amongst the records
+// with the lowest priority, weighted by their weights, as described in RFC
+// 2782.
+var WeightedPicker Picker = PickerFunc(pickSRV)
+
+func (sc *SRVClient) pick(srvs []*dns.SRV) *dns.SRV {
+	if sc.Picker == nil {
+		return pickWeighted(srvs, sc.intn)
+	}
+	return sc.Picker.Pick(srvs)
+}
+
+// LocalityPicker is a Picker which prefers the records considered local, either
+// because their target is an IP within one of Nets or because Local returned
+// true for them. Only the local records are passed to Fallback, unless there are
+// none, in which case all of them are.
+//
+// Since Nets can only match IPs, it has no effect on lookups which don't
+// translate targets into their respective IPs. Use Local for those.
+type LocalityPicker struct {
+	// Nets are the subnets which are considered local
+	Nets []*net.IPNet
+
+	// If non-nil, Local is called for each record not within Nets and can
+	// return true to mark the record as local
+	Local func(*dns.SRV) bool
+
+	// Fallback is used to pick amongst the preferred records. If nil then
+	// WeightedPicker is used.
+	Fallback Picker
+}
+
+// NewLocalityPicker returns a LocalityPicker which prefers targets in the given
+// CIDRs (e.g. "10.1.0.0/16").
+func NewLocalityPicker(cidrs ...string) (*LocalityPicker, error) {
+	lp := &LocalityPicker{Nets: make([]*net.IPNet, 0, len(cidrs))}
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		lp.Nets = append(lp.Nets, n)
+	}
+	return lp, nil
+}
+
+func (lp *LocalityPicker) isLocal(srv *dns.SRV) bool {
+	if ip := net.ParseIP(srv.Target); ip != nil {
+		for _, n := range lp.Nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return lp.Local != nil && lp.Local(srv)
+}
+
+// Pick implements the Picker interface
+func (lp *LocalityPicker) Pick(srvs []*dns.SRV) *dns.SRV {
+	local := make([]*dns.SRV, 0, len(srvs))
+	for _, srv := range srvs {
+		if lp.isLocal(srv) {
+			local = append(local, srv)
+		}
+	}
+	if len(local) == 0 {
+		local = srvs
+	}
+	if lp.Fallback == nil {
+		return pickSRV(local)
+	}
+	return lp.Fallback.Pick(local)
+}
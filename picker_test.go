@@ -0,0 +1,97 @@
+package srvclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPickerDistr(p Picker, srvs []*dns.SRV) map[string]int {
+	m := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		s := p.Pick(srvs)
+		m[s.Target]++
+	}
+	return m
+}
+
+func TestLocalityPicker(t *testing.T) {
+	lp, err := NewLocalityPicker("10.1.0.0/16")
+	require.NoError(t, err)
+
+	srvs := []*dns.SRV{
+		{Target: "10.1.0.1", Priority: 1, Weight: 100},
+		{Target: "10.2.0.1", Priority: 1, Weight: 100},
+		{Target: "10.1.0.2", Priority: 1, Weight: 100},
+	}
+	m := testPickerDistr(lp, srvs)
+	assert.Len(t, m, 2)
+	assert.True(t, m["10.1.0.1"] > 0)
+	assert.True(t, m["10.1.0.2"] > 0)
+
+	// nothing local so it should fallback to all of them
+	srvs = []*dns.SRV{
+		{Target: "10.2.0.1", Priority: 1, Weight: 100},
+		{Target: "10.3.0.1", Priority: 1, Weight: 100},
+	}
+	m = testPickerDistr(lp, srvs)
+	assert.Len(t, m, 2)
+
+	lp.Local = func(srv *dns.SRV) bool {
+		return srv.Target == "10.3.0.1"
+	}
+	m = testPickerDistr(lp, srvs)
+	assert.Len(t, m, 1)
+	assert.True(t, m["10.3.0.1"] > 0)
+
+	_, err = NewLocalityPicker("foo")
+	assert.Error(t, err)
+}
+
+func TestClientPicker(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs
+	client.Picker = PickerFunc(func(srvs []*dns.SRV) *dns.SRV {
+		return srvs[len(srvs)-1]
+	})
+
+	r, err := client.SRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, "[2607:5300:60:92e7::1]:1001", r)
+}
+
+func TestEWMAPicker(t *testing.T) {
+	p := new(EWMAPicker)
+	srvs := []*dns.SRV{
+		{Target: "a", Priority: 1, Weight: 100},
+		{Target: "b", Priority: 1, Weight: 100},
+		{Target: "c", Priority: 2, Weight: 100},
+	}
+
+	// no data so it should use the weights
+	m := testPickerDistr(p, srvs)
+	assert.Len(t, m, 2)
+	assert.True(t, m["a"] > 0)
+	assert.True(t, m["b"] > 0)
+
+	p.ReportLatency("a:1000", 10*time.Millisecond)
+	p.ReportLatency("b", 5*time.Millisecond)
+	p.ReportLatency("c", time.Millisecond)
+	m = testPickerDistr(p, srvs)
+	assert.Equal(t, map[string]int{"b": 1000}, m)
+
+	// decay b up past a
+	for i := 0; i < 20; i++ {
+		p.ReportLatency("b", 20*time.Millisecond)
+	}
+	l, ok := p.Latency("b:1")
+	require.True(t, ok)
+	assert.True(t, l > 10*time.Millisecond)
+	assert.Equal(t, "a", p.Pick(srvs).Target)
+
+	p.Forget("a")
+	assert.Equal(t, "b", p.Pick(srvs).Target)
+}
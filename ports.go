@@ -0,0 +1,37 @@
+package srvclient
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// splitHostPort splits the optional ":port" off of a hostname passed to one of
+// the lookup methods, returning the hostname to look up and the port override,
+// if any, resolved using portNumber. Unlike net.SplitHostPort a hostname
+// without a port, including an IPv6 address without brackets, is returned
+// whole, and the brackets around a host without a port are removed.
+func splitHostPort(hostname string) (host, port string) {
+	if h, p, err := net.SplitHostPort(hostname); err == nil && h != "" {
+		return h, portNumber(p)
+	}
+	if strings.HasPrefix(hostname, "[") && strings.HasSuffix(hostname, "]") {
+		return hostname[1 : len(hostname)-1], ""
+	}
+	return hostname, ""
+}
+
+// portNumber returns the number of the given port override, which can also be
+// a service name like "https", in the same way as the net package resolves
+// them. Ports which can't be resolved are returned as-is.
+func portNumber(port string) string {
+	if port == "" {
+		return port
+	} else if _, err := strconv.ParseUint(port, 10, 16); err == nil {
+		return port
+	}
+	if n, err := net.LookupPort("tcp", port); err == nil {
+		return strconv.Itoa(n)
+	}
+	return port
+}
@@ -0,0 +1,61 @@
+package srvclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortNumber(t *testing.T) {
+	assert.Equal(t, "", portNumber(""))
+	assert.Equal(t, "8080", portNumber("8080"))
+	assert.Equal(t, "443", portNumber("https"))
+	assert.Equal(t, "not-a-service", portNumber("not-a-service"))
+}
+
+func TestSplitHostPort(t *testing.T) {
+	for _, c := range []struct {
+		hostname, host, port string
+	}{
+		{"srv.test", "srv.test", ""},
+		{"srv.test:1000", "srv.test", "1000"},
+		{"srv.test:https", "srv.test", "443"},
+		{"srv.test:", "srv.test", ""},
+		{"[srv.test]:1000", "srv.test", "1000"},
+		{"[srv.test]", "srv.test", ""},
+		{"[::1]:1000", "::1", "1000"},
+		{"[::1]", "::1", ""},
+		{"::1", "::1", ""},
+		{":1000", ":1000", ""},
+	} {
+		host, port := splitHostPort(c.hostname)
+		assert.Equal(t, c.host, host, c.hostname)
+		assert.Equal(t, c.port, port, c.hostname)
+	}
+}
+
+func TestSRVPortName(t *testing.T) {
+	r, err := SRV(testHostname + ":https")
+	require.NoError(t, err)
+	assert.True(t, r == "10.0.0.1:443" || r == "[2607:5300:60:92e7::1]:443", r)
+
+	rs, err := AllSRV(testHostname + ":http")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.srv.test.:80", "2.srv.test.:80"}, rs)
+
+	// parsing is the same across the methods
+	rs, err = AllSRV("[" + testHostname + "]:1234")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.srv.test.:1234", "2.srv.test.:1234"}, rs)
+	r, err = SRV("[::1]:1234")
+	require.NoError(t, err)
+	assert.Equal(t, "[::1]:1234", r)
+
+	recs, err := SRVAllIPs(context.Background(), testHostname+":ssh")
+	require.NoError(t, err)
+	for _, rec := range recs {
+		assert.Equal(t, uint16(22), rec.Port)
+	}
+}
@@ -0,0 +1,58 @@
+package srvclient
+
+import (
+	"context"
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context which causes lookups using it to only use the
+// SRV records with the given priority, e.g. to only list the backup targets. If
+// there are none then the lookup fails with an ErrNotFound. PriorityClasses is
+// ignored for these lookups.
+func WithPriority(ctx context.Context, priority uint16) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFromContext(ctx context.Context) (uint16, bool) {
+	priority, ok := ctx.Value(priorityKey{}).(uint16)
+	return priority, ok
+}
+
+// filterPriorities removes the records which aren't in the priority given by
+// WithPriority, or in the lowest PriorityClasses priorities, from ans and
+// returns the result. The records are filtered in place.
+func (sc *SRVClient) filterPriorities(ctx context.Context, ans []*dns.SRV) []*dns.SRV {
+	keep := func(*dns.SRV) bool { return true }
+	if priority, ok := priorityFromContext(ctx); ok {
+		keep = func(srv *dns.SRV) bool { return srv.Priority == priority }
+	} else if sc.PriorityClasses > 0 {
+		priorities := make([]uint16, 0, len(ans))
+		seen := make(map[uint16]bool, len(ans))
+		for _, srv := range ans {
+			if !seen[srv.Priority] {
+				seen[srv.Priority] = true
+				priorities = append(priorities, srv.Priority)
+			}
+		}
+		if len(priorities) <= sc.PriorityClasses {
+			return ans
+		}
+		sort.Slice(priorities, func(i, j int) bool { return priorities[i] < priorities[j] })
+		highest := priorities[sc.PriorityClasses-1]
+		keep = func(srv *dns.SRV) bool { return srv.Priority <= highest }
+	} else {
+		return ans
+	}
+
+	filtered := ans[:0]
+	for _, srv := range ans {
+		if keep(srv) {
+			filtered = append(filtered, srv)
+		}
+	}
+	return filtered
+}
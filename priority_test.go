@@ -0,0 +1,60 @@
+package srvclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityClasses(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{
+			newRR("srv.test. 60 IN SRV 20 0 1003 4.srv.test."),
+			newRR("srv.test. 60 IN SRV 0 0 1000 1.srv.test."),
+			newRR("srv.test. 60 IN SRV 10 0 1002 3.srv.test."),
+			newRR("srv.test. 60 IN SRV 0 0 1001 2.srv.test."),
+		}
+		w.WriteMsg(m)
+	})
+	lookup := func(ctx context.Context, classes int) ([]string, error) {
+		sc := &SRVClient{PriorityClasses: classes}
+		sc.ResolverAddrs = []string{addr}
+		return sc.AllSRVContext(ctx, testHostname)
+	}
+
+	ctx := context.Background()
+	addrs, err := lookup(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, addrs, 4)
+
+	addrs, err = lookup(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.srv.test.:1000", "2.srv.test.:1001"}, addrs)
+
+	addrs, err = lookup(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.srv.test.:1000", "2.srv.test.:1001", "3.srv.test.:1002"}, addrs)
+
+	addrs, err = lookup(ctx, 5)
+	require.NoError(t, err)
+	assert.Len(t, addrs, 4)
+
+	// a specific priority takes precedence
+	addrs, err = lookup(WithPriority(ctx, 20), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4.srv.test.:1003"}, addrs)
+
+	_, err = lookup(WithPriority(ctx, 5), 0)
+	assert.ErrorIs(t, err, ErrNoRecords)
+
+	sc := &SRVClient{PriorityClasses: 1}
+	sc.ResolverAddrs = []string{addr}
+	res, err := sc.LookupSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, res.Records, 2)
+}
@@ -0,0 +1,134 @@
+package srvclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type proxyErrKey struct{}
+
+// proxyLookupTimeout limits the shared lookups made by ProxyRewrite when the
+// SRVClient has no DefaultTimeout, since they aren't bound by any request
+const proxyLookupTimeout = 10 * time.Second
+
+// proxyTarget caches the answer for the hostname being proxied to, so that a
+// backend can be picked for every request without a lookup
+type proxyTarget struct {
+	sc       *SRVClient
+	hostname string
+	port     string
+	scheme   string
+
+	// refreshL is held while looking up, so that concurrent requests which
+	// find the answer expired only make a single lookup between them
+	refreshL sync.Mutex
+
+	l       sync.RWMutex
+	ans     []*dns.SRV
+	err     error
+	expires time.Time
+}
+
+func (pt *proxyTarget) answer(ctx context.Context) ([]*dns.SRV, error) {
+	pt.l.RLock()
+	ans, err, expires := pt.ans, pt.err, pt.expires
+	pt.l.RUnlock()
+	if time.Now().Before(expires) {
+		return ans, err
+	}
+
+	pt.refreshL.Lock()
+	defer pt.refreshL.Unlock()
+	pt.l.RLock()
+	ans, err, expires = pt.ans, pt.err, pt.expires
+	pt.l.RUnlock()
+	if time.Now().Before(expires) {
+		// another request refreshed it while we were waiting
+		return ans, err
+	}
+
+	// the lookup is shared by every waiting request, so it shouldn't be
+	// canceled along with this one, but it's still bounded so that a hung
+	// resolver doesn't hold up every request behind refreshL
+	timeout := pt.sc.DefaultTimeout
+	if timeout <= 0 {
+		timeout = proxyLookupTimeout
+	}
+	lctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	newAns, err := pt.sc.lookupSRV(lctx, pt.hostname, true, false)
+	pt.l.Lock()
+	defer pt.l.Unlock()
+	if len(newAns) > 0 {
+		pt.ans, pt.err = newAns, nil
+	} else if len(pt.ans) == 0 {
+		pt.err = err
+	}
+	// failures are retried after the minimum interval, but the last
+	// successful answer keeps being used until then
+	pt.expires = time.Now().Add(refreshInterval(newAns, 0, 0))
+	return pt.ans, pt.err
+}
+
+func (pt *proxyTarget) rewrite(pr *httputil.ProxyRequest) {
+	ans, err := pt.answer(pr.In.Context())
+	if len(ans) == 0 {
+		// the URL is left without a host so that the request fails, and the
+		// Transport set by ReverseProxy returns err as the reason
+		pr.Out.URL.Scheme, pr.Out.URL.Host = pt.scheme, ""
+		pr.Out = pr.Out.WithContext(context.WithValue(pr.Out.Context(), proxyErrKey{}, err))
+		return
+	}
+	pr.SetURL(&url.URL{Scheme: pt.scheme, Host: srvToStr(pt.sc.pick(ans), pt.port)})
+}
+
+// ProxyRewrite returns a function which can be used as the Rewrite function of
+// an httputil.ReverseProxy, which sends each request to a backend picked from
+// the SRV records of hostname, in the same way as SRV, using the given scheme,
+// e.g. "http". A backend is picked for every request, so requests are spread
+// across the backends by their weights. The answer is cached until its TTL
+// runs out, and if a lookup fails then the last successful answer keeps being
+// used. Lookups are limited to the SRVClient's DefaultTimeout, or 10 seconds if
+// it isn't set. Like SRV, if the hostname contains a port then that port is
+// used.
+//
+// The request's path is joined to the backend's, and its Host header is
+// replaced with the backend's address, as with ProxyRequest.SetURL. If no
+// backend could be found then the request is failed, ideally using the
+// Transport from ReverseProxy so that the ErrorHandler is given the lookup's
+// error.
+func (sc *SRVClient) ProxyRewrite(hostname, scheme string) func(*httputil.ProxyRequest) {
+	host, port := splitHostPort(hostname)
+	pt := &proxyTarget{sc: sc, hostname: host, port: port, scheme: scheme}
+	return pt.rewrite
+}
+
+// ReverseProxy returns an httputil.ReverseProxy which proxies to the backends
+// of hostname using ProxyRewrite. Its Transport is http.DefaultTransport, which
+// can be replaced by wrapping the existing one.
+func (sc *SRVClient) ReverseProxy(hostname, scheme string) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite:   sc.ProxyRewrite(hostname, scheme),
+		Transport: proxyTransport{http.DefaultTransport},
+	}
+}
+
+// proxyTransport fails requests which ProxyRewrite couldn't find a backend for
+// with the lookup's error
+type proxyTransport struct {
+	http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t proxyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err, _ := r.Context().Value(proxyErrKey{}).(error); err != nil {
+		return nil, err
+	}
+	return t.RoundTripper.RoundTrip(r)
+}
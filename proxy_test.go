@@ -0,0 +1,108 @@
+package srvclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseProxy(t *testing.T) {
+	var backends []string
+	for i := 0; i < 2; i++ {
+		i := i
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%d %s", i, r.URL.Path)
+		}))
+		t.Cleanup(s.Close)
+		backends = append(backends, s.Listener.Addr().String())
+	}
+
+	var lookups int64
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if q := r.Question[0]; q.Name == "backends.test." && q.Qtype == dns.TypeSRV {
+			atomic.AddInt64(&lookups, 1)
+			for i, backend := range backends {
+				_, port, _ := net.SplitHostPort(backend)
+				m.Answer = append(m.Answer, newRR(fmt.Sprintf("backends.test. 60 IN SRV 0 1 %s b%d.test.", port, i)))
+				m.Extra = append(m.Extra, newRR(fmt.Sprintf("b%d.test. 60 IN A 127.0.0.1", i)))
+			}
+		}
+		w.WriteMsg(m)
+	})
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+
+	proxy := httptest.NewServer(client.ReverseProxy("backends.test", "http"))
+	defer proxy.Close()
+
+	seen := map[string]int{}
+	for i := 0; i < 50; i++ {
+		res, err := http.Get(proxy.URL + "/foo")
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		seen[string(body)]++
+	}
+	// requests are spread across both backends, but the answer is cached
+	assert.Len(t, seen, 2)
+	assert.Contains(t, seen, "0 /foo")
+	assert.Contains(t, seen, "1 /foo")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&lookups))
+
+	// the port in the hostname overrides the records'
+	_, port, _ := net.SplitHostPort(backends[1])
+	proxy2 := httptest.NewServer(client.ReverseProxy("backends.test:"+port, "http"))
+	defer proxy2.Close()
+	res, err := http.Get(proxy2.URL + "/bar")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "1 /bar", string(body))
+
+	// failed lookups are passed to the ErrorHandler
+	rp := client.ReverseProxy("missing.test", "http")
+	var proxyErr error
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		proxyErr = err
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	proxy3 := httptest.NewServer(rp)
+	defer proxy3.Close()
+	res, err = http.Get(proxy3.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+	assert.True(t, errors.Is(proxyErr, &ErrNotFound{}), "%v", proxyErr)
+
+	// a hung resolver only holds requests up until the lookup times out
+	client = SRVClient{DefaultTimeout: 100 * time.Millisecond}
+	client.ResolverAddrs = []string{startTestServer(t, func(dns.ResponseWriter, *dns.Msg) {})}
+	rp = client.ReverseProxy("backends.test", "http")
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		proxyErr = err
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	proxy4 := httptest.NewServer(rp)
+	defer proxy4.Close()
+	start := time.Now()
+	res, err = http.Get(proxy4.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, errors.Is(proxyErr, &ErrTimeout{}), "%v", proxyErr)
+}
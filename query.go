@@ -0,0 +1,26 @@
+package srvclient
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// Query calls the Query method on the DefaultSRVClient
+func Query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	return DefaultSRVClient.Query(ctx, name, qtype)
+}
+
+// Query performs a query of any type (e.g. dns.TypeA) for the given name using
+// the same resolvers, transports and truncation handling as the SRV methods,
+// and returns the response as-is. Preprocess is only called on SRV responses.
+// The response may be shared with other lookups and the cache, so it must not
+// be modified.
+//
+// An error is only returned if no response could be retrieved, so the rcode of
+// the response must be checked by the caller. Like AllSRV, a response can be
+// returned along with an error if the last successful response was used.
+func (sc *SRVClient) Query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	msg, _, err := sc.lookupMsg(ctx, name, qtype, false)
+	return msg, err
+}
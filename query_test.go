@@ -0,0 +1,32 @@
+package srvclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	m, err := Query(context.Background(), testHostnameNoSRV, dns.TypeA)
+	require.NoError(t, err)
+	require.Len(t, m.Answer, 1)
+	assert.Equal(t, dns.TypeA, m.Question[0].Qtype)
+	assert.Equal(t, "11.0.0.1", m.Answer[0].(*dns.A).A.String())
+
+	m, err = Query(context.Background(), testHostname, dns.TypeSRV)
+	require.NoError(t, err)
+	assert.Len(t, m.Answer, 2)
+
+	// make sure different types don't share the cache
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs
+	client.EnableCacheLast()
+	_, err = client.Query(context.Background(), testHostname, dns.TypeSRV)
+	require.NoError(t, err)
+	_, err = client.Query(context.Background(), testHostname, dns.TypeA)
+	require.NoError(t, err)
+	assert.Len(t, client.cacheLast, 2)
+}
@@ -0,0 +1,110 @@
+package srvclient
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// queryLogL serializes writes to every QueryLog, since clones share theirs and
+// an io.Writer isn't necessarily safe for concurrent use
+var queryLogL sync.Mutex
+
+// QueryLogEntry is the JSON object written to QueryLog for every lookup
+type QueryLogEntry struct {
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Qtype    string    `json:"qtype"`
+
+	// DurationMS is how long the lookup took, in milliseconds
+	DurationMS float64 `json:"duration_ms"`
+
+	// Resolver and Transport are the address of the resolver which answered
+	// and the network it was queried over, e.g. "udp". They're empty if the
+	// answer was cached or overridden, in which case Cached is set, or if no
+	// resolver answered.
+	Resolver  string `json:"resolver,omitempty"`
+	Transport string `json:"transport,omitempty"`
+	Cached    bool   `json:"cached,omitempty"`
+
+	// Rcode is empty if there wasn't a response
+	Rcode   string `json:"rcode,omitempty"`
+	Answers int    `json:"answers"`
+	Error   string `json:"error,omitempty"`
+}
+
+// queryLogNets records the network each resolver's last response during a
+// lookup was received over, so the Transport of the one which answered is known
+type queryLogNets struct {
+	l    sync.Mutex
+	nets map[string]string
+}
+
+func (q *queryLogNets) set(server, net string) {
+	q.l.Lock()
+	q.nets[server] = net
+	q.l.Unlock()
+}
+
+func (q *queryLogNets) get(server string) string {
+	q.l.Lock()
+	defer q.l.Unlock()
+	return q.nets[server]
+}
+
+type queryLogKey struct{}
+
+func queryLogFromContext(ctx context.Context) *queryLogNets {
+	q, _ := ctx.Value(queryLogKey{}).(*queryLogNets)
+	return q
+}
+
+// startQueryLog returns the context to use for a lookup which is to be logged
+// to QueryLog, along with a function to call with its outcome
+func (sc *SRVClient) startQueryLog(ctx context.Context, hostname string, qtype uint16) (context.Context, func(*dns.Msg, string, error)) {
+	if sc.QueryLog == nil {
+		return ctx, func(*dns.Msg, string, error) {}
+	}
+	q := &queryLogNets{nets: map[string]string{}}
+	ctx = context.WithValue(ctx, queryLogKey{}, q)
+	start := time.Now()
+	return ctx, func(msg *dns.Msg, server string, err error) {
+		e := QueryLogEntry{
+			Time:       start,
+			Hostname:   hostname,
+			Qtype:      dns.TypeToString[qtype],
+			DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+			Resolver:   server,
+			Transport:  q.get(server),
+			Cached:     msg != nil && server == "",
+		}
+		if msg != nil {
+			e.Rcode = rcodeString(msg.Rcode)
+			e.Answers = len(msg.Answer)
+		}
+		if err == nil && msg != nil && len(msg.Answer) == 0 {
+			// the caller turns these into errors, so they're logged as such
+			err = noAnswersErr(hostname, server, msg)
+		}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		sc.writeQueryLog(e)
+	}
+}
+
+// writeQueryLog writes e to QueryLog as a single line. Errors writing are
+// ignored, so that logging never fails a lookup.
+func (sc *SRVClient) writeQueryLog(e QueryLogEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	queryLogL.Lock()
+	defer queryLogL.Unlock()
+	sc.QueryLog.Write(b)
+}
@@ -0,0 +1,80 @@
+package srvclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queryLogEntries(t *testing.T, buf *bytes.Buffer) []QueryLogEntry {
+	var entries []QueryLogEntry
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var e QueryLogEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &e), line)
+		entries = append(entries, e)
+	}
+	buf.Reset()
+	return entries
+}
+
+func TestQueryLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	client := SRVClient{
+		ResolverAddrs:  DefaultSRVClient.ResolverAddrs[:1],
+		ResolveTargets: true,
+		QueryLog:       buf,
+	}
+	_, err := client.SRV(testHostname)
+	require.NoError(t, err)
+
+	// the follow-up lookups of the targets aren't logged
+	entries := queryLogEntries(t, buf)
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, testHostname, e.Hostname)
+	assert.Equal(t, "SRV", e.Qtype)
+	assert.Equal(t, client.ResolverAddrs[0], e.Resolver)
+	assert.Equal(t, "udp", e.Transport)
+	assert.False(t, e.Cached)
+	assert.Equal(t, "NOERROR", e.Rcode)
+	assert.Equal(t, 2, e.Answers)
+	assert.Empty(t, e.Error)
+	assert.False(t, e.Time.IsZero())
+	assert.Greater(t, e.DurationMS, 0.0)
+
+	_, err = client.SRV(testHostnameTruncated)
+	require.NoError(t, err)
+	entries = queryLogEntries(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "tcp", entries[0].Transport)
+
+	client = SRVClient{
+		ResolverAddrs: []string{rcodeServer(t, dns.RcodeServerFailure)},
+		QueryLog:      buf,
+	}
+	_, err = client.SRV(testHostname)
+	require.True(t, errors.Is(err, ErrServFail), "%v", err)
+	entries = queryLogEntries(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "SERVFAIL", entries[0].Rcode)
+	assert.Equal(t, 0, entries[0].Answers)
+	assert.Equal(t, err.Error(), entries[0].Error)
+
+	client = SRVClient{
+		ResolverAddrs: []string{closedAddr(t)},
+		QueryLog:      buf,
+	}
+	_, err = client.SRV(testHostname)
+	require.Error(t, err)
+	entries = queryLogEntries(t, buf)
+	require.Len(t, entries, 1)
+	assert.Empty(t, entries[0].Resolver)
+	assert.Empty(t, entries[0].Rcode)
+	assert.NotEmpty(t, entries[0].Error)
+}
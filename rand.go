@@ -0,0 +1,42 @@
+package srvclient
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// userRandL serializes calls to every SRVClient.Rand, since a *rand.Rand isn't
+// safe to use concurrently and Clone shares it
+var userRandL sync.Mutex
+
+func randIntn(n int) int {
+	r := randPool.Get().(*rand.Rand)
+	defer randPool.Put(r)
+	return r.Intn(n)
+}
+
+func randFloat64() float64 {
+	r := randPool.Get().(*rand.Rand)
+	defer randPool.Put(r)
+	return r.Float64()
+}
+
+// intn returns a random int in [0, n) using Rand, if set
+func (sc *SRVClient) intn(n int) int {
+	if sc.Rand == nil {
+		return randIntn(n)
+	}
+	userRandL.Lock()
+	defer userRandL.Unlock()
+	return sc.Rand.Intn(n)
+}
+
+// float64 returns a random float64 in [0, 1) using Rand, if set
+func (sc *SRVClient) float64() float64 {
+	if sc.Rand == nil {
+		return randFloat64()
+	}
+	userRandL.Lock()
+	defer userRandL.Unlock()
+	return sc.Rand.Float64()
+}
@@ -0,0 +1,33 @@
+package srvclient
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRand(t *testing.T) {
+	var srvs []*dns.SRV
+	for i := 0; i < 10; i++ {
+		srvs = append(srvs, &dns.SRV{Target: "t.test.", Port: uint16(i), Weight: 10})
+	}
+	picks := func(sc *SRVClient) []uint16 {
+		var ports []uint16
+		for i := 0; i < 50; i++ {
+			ports = append(ports, sc.pick(srvs).Port)
+		}
+		return ports
+	}
+
+	sc1 := &SRVClient{Rand: rand.New(rand.NewSource(1)), Jitter: 0.5}
+	sc2 := &SRVClient{Rand: rand.New(rand.NewSource(1)), Jitter: 0.5}
+	assert.Equal(t, picks(sc1), picks(sc2))
+	assert.Equal(t, sc1.jitter(time.Second), sc2.jitter(time.Second))
+
+	// a different seed picks differently
+	sc3 := &SRVClient{Rand: rand.New(rand.NewSource(2))}
+	assert.NotEqual(t, picks(sc1), picks(sc3))
+}
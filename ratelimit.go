@@ -0,0 +1,84 @@
+package srvclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple token bucket rate limiter which refills at rate
+// tokens per second up to burst tokens
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	l      sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller needs to wait before
+// using it
+func (tb *tokenBucket) reserve() time.Duration {
+	tb.l.Lock()
+	defer tb.l.Unlock()
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// cancel gives back a token taken by reserve which ended up not being used
+func (tb *tokenBucket) cancel() {
+	tb.l.Lock()
+	defer tb.l.Unlock()
+	tb.tokens++
+}
+
+func (tb *tokenBucket) wait(ctx context.Context) error {
+	d := tb.reserve()
+	if d == 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		tb.cancel()
+		return ctx.Err()
+	}
+}
+
+// rateLimitWait waits until a query is allowed to be sent to the given server,
+// if ResolverRateLimit is set
+func (sc *SRVClient) rateLimitWait(ctx context.Context, server string) error {
+	if sc.ResolverRateLimit <= 0 {
+		return nil
+	}
+	tbi, ok := sc.rateLimiters.Load(server)
+	if !ok {
+		tbi, _ = sc.rateLimiters.LoadOrStore(server, newTokenBucket(sc.ResolverRateLimit, sc.ResolverRateBurst))
+	}
+	return tbi.(*tokenBucket).wait(ctx)
+}
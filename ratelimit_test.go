@@ -0,0 +1,40 @@
+package srvclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tb := newTokenBucket(10, 2)
+	assert.Zero(t, tb.reserve())
+	assert.Zero(t, tb.reserve())
+	d := tb.reserve()
+	assert.True(t, d > 50*time.Millisecond && d <= 100*time.Millisecond, "%v", d)
+
+	// the token taken by the canceled wait should be given back
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, tb.wait(ctx))
+	d = tb.reserve()
+	assert.True(t, d > 100*time.Millisecond && d <= 200*time.Millisecond, "%v", d)
+}
+
+func TestResolverRateLimit(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	client.ResolverRateLimit = 20
+	client.ResolverRateBurst = 2
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := client.SRV(testHostname)
+		require.NoError(t, err)
+	}
+	// 2 were allowed immediately and the next 2 had to wait 50ms each
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+}
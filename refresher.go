@@ -0,0 +1,181 @@
+package srvclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultRefreshMinInterval is the smallest interval a Refresher will wait
+	// between lookups of a hostname when MinInterval isn't set
+	DefaultRefreshMinInterval = time.Second
+
+	// DefaultRefreshMaxInterval is the largest interval a Refresher will wait
+	// between lookups of a hostname when MaxInterval isn't set
+	DefaultRefreshMaxInterval = 5 * time.Minute
+)
+
+var errNotResolved = errors.New("hostname has not been resolved yet")
+
+type refresherEntry struct {
+	ans []*dns.SRV
+	err error
+}
+
+// Refresher keeps the SRV answers for a set of hostnames fresh by looking them
+// up in the background whenever the TTL of their last answer runs out. Reads
+// through Get never block on DNS.
+//
+// If a refresh fails then the last successful answer keeps being used.
+type Refresher struct {
+	// MinInterval and MaxInterval bound the time between lookups of a single
+	// hostname, regardless of the TTLs. They can only be changed before calling
+	// Start.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	sc        *SRVClient
+	hostnames []string
+
+	l       sync.RWMutex
+	entries map[string]*refresherEntry
+
+	stopCh chan struct{}
+	stopO  sync.Once
+	wg     sync.WaitGroup
+}
+
+// NewRefresher returns a Refresher which refreshes the given hostnames using
+// the given client, or DefaultSRVClient if nil. Start must be called for it to
+// begin resolving.
+func NewRefresher(sc *SRVClient, hostnames ...string) *Refresher {
+	if sc == nil {
+		sc = DefaultSRVClient
+	}
+	r := &Refresher{
+		sc:        sc,
+		hostnames: hostnames,
+		entries:   make(map[string]*refresherEntry, len(hostnames)),
+		stopCh:    make(chan struct{}),
+	}
+	for _, h := range hostnames {
+		r.entries[h] = &refresherEntry{err: errNotResolved}
+	}
+	return r
+}
+
+// Start begins refreshing every hostname in the background. The first lookup
+// of each hostname happens immediately.
+func (r *Refresher) Start() {
+	for _, h := range r.hostnames {
+		r.wg.Add(1)
+		go r.loop(h)
+	}
+}
+
+// Stop stops all background refreshing and waits for it to finish. It's safe to
+// call more than once.
+func (r *Refresher) Stop() {
+	r.stopO.Do(func() { close(r.stopCh) })
+	r.wg.Wait()
+}
+
+func minTTL(srvs []*dns.SRV) uint32 {
+	var ttl uint32
+	for i, srv := range srvs {
+		if i == 0 || srv.Hdr.Ttl < ttl {
+			ttl = srv.Hdr.Ttl
+		}
+	}
+	return ttl
+}
+
+func refreshInterval(ans []*dns.SRV, minI, maxI time.Duration) time.Duration {
+	if minI <= 0 {
+		minI = DefaultRefreshMinInterval
+	}
+	if maxI <= 0 {
+		maxI = DefaultRefreshMaxInterval
+	}
+	if len(ans) == 0 {
+		return minI
+	}
+	i := time.Duration(minTTL(ans)) * time.Second
+	if i < minI {
+		return minI
+	} else if i > maxI {
+		return maxI
+	}
+	return i
+}
+
+func (r *Refresher) interval(ans []*dns.SRV) time.Duration {
+	return r.sc.jitter(refreshInterval(ans, r.MinInterval, r.MaxInterval))
+}
+
+func (r *Refresher) refresh(ctx context.Context, hostname string) []*dns.SRV {
+	name, _ := splitHostPort(hostname)
+	ans, err := r.sc.lookupSRV(ctx, name, true, false)
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	e := r.entries[hostname]
+	if len(ans) > 0 {
+		e.ans = ans
+		e.err = nil
+	} else if len(e.ans) == 0 {
+		e.err = err
+	}
+	return ans
+}
+
+func (r *Refresher) loop(hostname string) {
+	defer r.wg.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stopCh
+		cancel()
+	}()
+
+	for {
+		ans := r.refresh(ctx, hostname)
+		t := time.NewTimer(r.interval(ans))
+		select {
+		case <-t.C:
+		case <-r.stopCh:
+			t.Stop()
+			return
+		}
+	}
+}
+
+// Get returns an address ("host:port") for the given hostname from the latest
+// answer, chosen in the same way as SRV. The hostname must have been passed to
+// NewRefresher. Like SRV, if the hostname contained a port then that port is
+// used in the result.
+func (r *Refresher) Get(hostname string) (string, error) {
+	r.l.RLock()
+	e, ok := r.entries[hostname]
+	var ans []*dns.SRV
+	var err error
+	if ok {
+		ans, err = e.ans, e.err
+	}
+	r.l.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("%q is not being refreshed", hostname)
+	}
+	if len(ans) == 0 {
+		return "", err
+	}
+
+	_, portStr := splitHostPort(hostname)
+	return srvToStr(r.sc.pick(ans), portStr), nil
+}
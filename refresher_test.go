@@ -0,0 +1,68 @@
+package srvclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresher(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs
+
+	r := NewRefresher(&client, testHostname, testHostname+":9999", "fail")
+	_, err := r.Get(testHostname)
+	assert.Equal(t, errNotResolved, err)
+
+	r.Start()
+	defer r.Stop()
+	assert.Eventually(t, func() bool {
+		_, err := r.Get(testHostname)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	addr, err := r.Get(testHostname)
+	require.NoError(t, err)
+	assert.True(t, addr == "10.0.0.1:1000" || addr == "[2607:5300:60:92e7::1]:1001")
+
+	assert.Eventually(t, func() bool {
+		_, err := r.Get(testHostname + ":9999")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	addr, err = r.Get(testHostname + ":9999")
+	require.NoError(t, err)
+	assert.True(t, addr == "10.0.0.1:9999" || addr == "[2607:5300:60:92e7::1]:9999")
+
+	assert.Eventually(t, func() bool {
+		_, err := r.Get("fail")
+		return err != errNotResolved
+	}, time.Second, 10*time.Millisecond)
+	_, err = r.Get("fail")
+	assert.IsType(t, &ErrNotFound{}, err)
+
+	_, err = r.Get("unknown")
+	assert.Error(t, err)
+}
+
+func TestRefresherStopTwice(t *testing.T) {
+	r := NewRefresher(nil, testHostname)
+	r.Start()
+	r.Stop()
+	assert.NotPanics(t, r.Stop)
+}
+
+func TestRefresherInterval(t *testing.T) {
+	r := NewRefresher(nil)
+	ans := []*dns.SRV{
+		{Hdr: dns.RR_Header{Ttl: 60}},
+		{Hdr: dns.RR_Header{Ttl: 30}},
+	}
+	assert.Equal(t, 30*time.Second, r.interval(ans))
+	assert.Equal(t, DefaultRefreshMinInterval, r.interval(nil))
+
+	r.MaxInterval = 10 * time.Second
+	assert.Equal(t, 10*time.Second, r.interval(ans))
+}
@@ -0,0 +1,202 @@
+// Package register publishes and withdraws a process's own SRV records, and the
+// A and AAAA records of their targets, using RFC 2136 dynamic updates
+// authenticated with TSIG, so that services which are discovered using
+// srvclient can also register themselves.
+package register
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultTTL is the TTL of the published records when TTL isn't set
+	DefaultTTL = 30 * time.Second
+
+	// DefaultTimeout is the timeout of each update when Timeout isn't set
+	DefaultTimeout = 5 * time.Second
+)
+
+// ErrUpdate is returned when the server rejected an update
+type ErrUpdate struct {
+	Zone   string
+	Server string
+	Rcode  int
+}
+
+// Error implements the error interface
+func (err *ErrUpdate) Error() string {
+	rcode, ok := dns.RcodeToString[err.Rcode]
+	if !ok {
+		rcode = fmt.Sprintf("rcode %d", err.Rcode)
+	}
+	return fmt.Sprintf("%s updating %q on %s", rcode, err.Zone, err.Server)
+}
+
+// Service describes the records published for an instance of a service
+type Service struct {
+	// Name is the name of the SRV record, e.g. "_web._tcp.example.com"
+	Name string
+
+	// Target is the hostname the SRV record points to, e.g. the host the
+	// process is running on, and Port is the port it's listening on
+	Target string
+	Port   uint16
+
+	Priority uint16
+	Weight   uint16
+
+	// IPs, if set, are published as A and AAAA records for Target
+	IPs []net.IP
+}
+
+// Registrar sends dynamic updates for a zone to its primary server
+type Registrar struct {
+	// Server is the address ("ip:port") of the zone's primary server
+	Server string
+
+	// Zone is the zone being updated, e.g. "example.com". The names of the
+	// services must be within it.
+	Zone string
+
+	// TSIGName and TSIGSecret are the name and base64 encoded secret of the
+	// key the updates are signed with, using TSIGAlgorithm, which defaults
+	// to dns.HmacSHA256. If TSIGName is empty then updates aren't signed.
+	TSIGName      string
+	TSIGSecret    string
+	TSIGAlgorithm string
+
+	// TTL is the TTL of the published records. Defaults to DefaultTTL.
+	TTL time.Duration
+
+	// Net is the transport updates are sent over, "tcp" (the default) or
+	// "udp"
+	Net string
+
+	// Timeout is the timeout of each update. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// OnError, if set, is called with the errors from the heartbeats made by
+	// Run, which otherwise keeps going
+	OnError func(error)
+}
+
+func (r *Registrar) ttl() time.Duration {
+	if r.TTL > 0 {
+		return r.TTL
+	}
+	return DefaultTTL
+}
+
+func (r *Registrar) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+	return DefaultTimeout
+}
+
+// records returns the records published for svc
+func (r *Registrar) records(svc Service) []dns.RR {
+	ttl := uint32(r.ttl() / time.Second)
+	target := dns.Fqdn(svc.Target)
+	rrs := []dns.RR{&dns.SRV{
+		Hdr:      dns.RR_Header{Name: dns.Fqdn(svc.Name), Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: ttl},
+		Priority: svc.Priority,
+		Weight:   svc.Weight,
+		Port:     svc.Port,
+		Target:   target,
+	}}
+	for _, ip := range svc.IPs {
+		if ip4 := ip.To4(); ip4 != nil {
+			rrs = append(rrs, &dns.A{
+				Hdr: dns.RR_Header{Name: target, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+				A:   ip4,
+			})
+		} else {
+			rrs = append(rrs, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: target, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: ttl},
+				AAAA: ip,
+			})
+		}
+	}
+	return rrs
+}
+
+// update sends the update built by fn to the server
+func (r *Registrar) update(ctx context.Context, fn func(*dns.Msg)) error {
+	zone := dns.Fqdn(r.Zone)
+	m := new(dns.Msg)
+	m.SetUpdate(zone)
+	fn(m)
+
+	c := &dns.Client{Net: r.Net, Timeout: r.timeout()}
+	if c.Net == "" {
+		c.Net = "tcp"
+	}
+	if r.TSIGName != "" {
+		name := dns.Fqdn(strings.ToLower(r.TSIGName))
+		alg := r.TSIGAlgorithm
+		if alg == "" {
+			alg = dns.HmacSHA256
+		}
+		c.TsigSecret = map[string]string{name: r.TSIGSecret}
+		m.SetTsig(name, alg, 300, time.Now().Unix())
+	}
+
+	res, _, err := c.ExchangeContext(ctx, m, r.Server)
+	if err != nil {
+		return fmt.Errorf("updating %q on %s: %w", r.Zone, r.Server, err)
+	} else if res.Rcode != dns.RcodeSuccess {
+		return &ErrUpdate{Zone: r.Zone, Server: r.Server, Rcode: res.Rcode}
+	}
+	return nil
+}
+
+// Register publishes the records for svc. Records which already exist are
+// left as they are, so it can be called repeatedly.
+func (r *Registrar) Register(ctx context.Context, svc Service) error {
+	return r.update(ctx, func(m *dns.Msg) {
+		m.Insert(r.records(svc))
+	})
+}
+
+// Deregister withdraws the records for svc, leaving any other records with the
+// same names, e.g. those of other instances, as they are
+func (r *Registrar) Deregister(ctx context.Context, svc Service) error {
+	return r.update(ctx, func(m *dns.Msg) {
+		m.Remove(r.records(svc))
+	})
+}
+
+// Run registers svc and then registers it again every half a TTL, as a
+// heartbeat which replaces the records if something else removed them, until
+// the context is canceled, at which point svc is deregistered. The error from
+// the first registration is returned immediately, otherwise the error from
+// deregistering is returned.
+func (r *Registrar) Run(ctx context.Context, svc Service) error {
+	if err := r.Register(ctx, svc); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(r.ttl() / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := r.Register(ctx, svc); err != nil && ctx.Err() == nil && r.OnError != nil {
+				r.OnError(err)
+			}
+		case <-ctx.Done():
+			// the context is already canceled, so the deregistration needs
+			// its own
+			dctx, cancel := context.WithTimeout(context.Background(), r.timeout())
+			defer cancel()
+			return r.Deregister(dctx, svc)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package register
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKey    = "test-key."
+	testSecret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+)
+
+// testServer applies the updates it's sent to its records, rejecting ones
+// which aren't signed
+type testServer struct {
+	addr string
+
+	l       sync.Mutex
+	records []dns.RR
+	updates int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := new(testServer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s.addr = l.Addr().String()
+
+	srv := &dns.Server{
+		Listener:   l,
+		TsigSecret: map[string]string{testKey: testSecret},
+		Handler:    dns.HandlerFunc(s.handle),
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			if int(dh.Bits>>11)&0xF == dns.OpcodeUpdate {
+				return dns.MsgAccept
+			}
+			return dns.DefaultMsgAcceptFunc(dh)
+		},
+	}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+	return s
+}
+
+func (s *testServer) handle(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if r.IsTsig() == nil || w.TsigStatus() != nil {
+		m.Rcode = dns.RcodeRefused
+	} else {
+		s.l.Lock()
+		s.updates++
+		for _, rr := range r.Ns {
+			rr = dns.Copy(rr)
+			class := rr.Header().Class
+			rr.Header().Class = dns.ClassINET
+			for i := 0; i < len(s.records); i++ {
+				if dns.IsDuplicate(rr, s.records[i]) {
+					s.records = append(s.records[:i], s.records[i+1:]...)
+					i--
+				}
+			}
+			if class == dns.ClassINET {
+				s.records = append(s.records, rr)
+			}
+		}
+		s.l.Unlock()
+		m.SetTsig(testKey, dns.HmacSHA256, 300, time.Now().Unix())
+	}
+	w.WriteMsg(m)
+}
+
+func (s *testServer) get() ([]string, int) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	var rrs []string
+	for _, rr := range s.records {
+		rrs = append(rrs, rr.String())
+	}
+	return rrs, s.updates
+}
+
+func TestRegistrar(t *testing.T) {
+	s := newTestServer(t)
+	r := &Registrar{
+		Server:     s.addr,
+		Zone:       "example.test",
+		TSIGName:   testKey,
+		TSIGSecret: testSecret,
+		TTL:        time.Second,
+	}
+	svc := Service{
+		Name:   "_web._tcp.example.test",
+		Target: "host1.example.test",
+		Port:   8080,
+		Weight: 1,
+		IPs:    []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("::1")},
+	}
+	other := Service{Name: svc.Name, Target: "host2.example.test", Port: 8080}
+	ctx := context.Background()
+
+	require.NoError(t, r.Register(ctx, other))
+	require.NoError(t, r.Register(ctx, svc))
+	rrs, _ := s.get()
+	assert.Equal(t, []string{
+		"_web._tcp.example.test.\t1\tIN\tSRV\t0 0 8080 host2.example.test.",
+		"_web._tcp.example.test.\t1\tIN\tSRV\t0 1 8080 host1.example.test.",
+		"host1.example.test.\t1\tIN\tA\t10.0.0.1",
+		"host1.example.test.\t1\tIN\tAAAA\t::1",
+	}, rrs)
+
+	// only svc's records are removed
+	require.NoError(t, r.Deregister(ctx, svc))
+	rrs, _ = s.get()
+	assert.Len(t, rrs, 1)
+
+	// unsigned updates are refused
+	unsigned := *r
+	unsigned.TSIGName = ""
+	err := unsigned.Register(ctx, svc)
+	var uerr *ErrUpdate
+	require.True(t, errors.As(err, &uerr), "%v", err)
+	assert.Equal(t, dns.RcodeRefused, uerr.Rcode)
+
+	// Run keeps registering until canceled, and then deregisters
+	_, before := s.get()
+	ctx, cancel := context.WithTimeout(ctx, 1200*time.Millisecond)
+	defer cancel()
+	require.NoError(t, r.Run(ctx, svc))
+	rrs, after := s.get()
+	assert.Len(t, rrs, 1)
+	// the first registration, 2 heartbeats and the deregistration
+	assert.Equal(t, 4, after-before)
+}
@@ -0,0 +1,83 @@
+package srvclient
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Flush discards everything the SRVClient has cached: the last successful
+// responses, if EnableCacheLast was called, the responses in the TTL cache, and
+// which resolvers didn't support EDNS0. If Cache is set and has a Flush method,
+// like MemoryCache, then that's called too, which affects every SRVClient
+// sharing it. The OverridesFile is checked for changes on the next lookup.
+func (sc *SRVClient) Flush() {
+	sc.cacheLastL.Lock()
+	if sc.cacheLast != nil {
+		sc.cacheLast = map[string]*dns.Msg{}
+	}
+	sc.cacheLastL.Unlock()
+
+	if f, ok := sc.Cache.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	sc.flushTTLCache()
+	sc.noEDNS.Range(func(server, _ interface{}) bool {
+		sc.noEDNS.Delete(server)
+		return true
+	})
+
+	sc.overridesFile.l.Lock()
+	sc.overridesFile.checked = time.Time{}
+	sc.overridesFile.modTime = time.Time{}
+	sc.overridesFile.l.Unlock()
+}
+
+// Reload calls Flush, and reloads the resolver configuration from
+// /etc/resolv.conf immediately, rather than waiting for it to be noticed as
+// changed. Since the configuration is shared, every SRVClient picks it up.
+func (sc *SRVClient) Reload() {
+	sc.Flush()
+	dnsReload()
+}
+
+// ReloadOn calls Reload every time a value is received from ch, until ctx is
+// canceled or ch is closed. It blocks, so should be called in a goroutine.
+func (sc *SRVClient) ReloadOn(ctx context.Context, ch <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+			sc.Reload()
+		}
+	}
+}
+
+// ReloadOnSignal calls Reload every time the process receives one of the given
+// signals, or SIGHUP if none are given, until ctx is canceled, the conventional
+// way of telling a long-lived daemon to drop its caches. It blocks, so should be
+// called in a goroutine.
+func (sc *SRVClient) ReloadOnSignal(ctx context.Context, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			sc.Reload()
+		}
+	}
+}
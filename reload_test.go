@@ -0,0 +1,68 @@
+package srvclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlush(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	client.EnableCacheLast()
+	_, err := client.SRV(testHostname)
+	require.NoError(t, err)
+	client.noEDNS.Store("127.0.0.1:53", time.Now())
+
+	client.Flush()
+	client.cacheLastL.RLock()
+	assert.NotNil(t, client.cacheLast)
+	assert.Empty(t, client.cacheLast)
+	client.cacheLastL.RUnlock()
+	assert.False(t, client.ednsUnsupported("127.0.0.1:53"))
+
+	cache := NewMemoryCache()
+	cache.Set("foo", new(dns.Msg), 0)
+	client = SRVClient{Cache: cache}
+	client.Flush()
+	assert.Zero(t, cache.Len())
+	assert.Zero(t, cache.Bytes())
+}
+
+func TestReloadOn(t *testing.T) {
+	reloads := make(chan dns.ClientConfig, 1)
+	client := SRVClient{
+		OnConfigReload: func(cfg dns.ClientConfig) { reloads <- cfg },
+	}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	_, err := client.SRV(testHostname)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.ReloadOn(ctx, ch)
+	}()
+	// the send only returns once the reload has been picked up by ReloadOn,
+	// and the second once the first reload has finished
+	ch <- struct{}{}
+	ch <- struct{}{}
+
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+	select {
+	case cfg := <-reloads:
+		assert.Equal(t, client.ResolverAddrs, cfg.Servers)
+	default:
+		t.Fatal("config wasn't reloaded")
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,14 @@
+package srvclient
+
+import "context"
+
+// Resolver describes the lookup methods of SRVClient, so code depending on
+// them can be given something else, like srvclienttest.Fake, in tests
+type Resolver interface {
+	SRVContext(ctx context.Context, hostname string) (string, error)
+	AllSRVContext(ctx context.Context, hostname string) ([]string, error)
+	LookupSRVContext(ctx context.Context, hostname string) (*Result, error)
+	MaybeSRVE(ctx context.Context, host string) (string, bool, error)
+}
+
+var _ Resolver = new(SRVClient)
@@ -0,0 +1,99 @@
+package srvclient
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ResolversEnv is the environment variable which, when set to a comma
+// separated list of resolver ips or addresses ("ip:port"), is used as the
+// resolver list for any SRVClient without ResolverAddrs set, instead of
+// /etc/resolv.conf
+const ResolversEnv = "SRVCLIENT_RESOLVERS"
+
+// ParseResolverAddrs parses a comma separated list of resolver ips or addresses
+// ("ip:port"). Any ips without a port get defaultPort appended to them. IPv6
+// addresses can be given without brackets, including ones with a zone like
+// "fe80::1%eth0", in which case they're added.
+func ParseResolverAddrs(s, defaultPort string) []string {
+	addrs := splitResolverAddrs(s)
+	for i, r := range addrs {
+		addrs[i] = resolverAddr(r, defaultPort)
+	}
+	return addrs
+}
+
+// splitResolverAddrs splits a comma separated list of resolvers, without
+// adding any ports
+func splitResolverAddrs(s string) []string {
+	var addrs []string
+	for _, r := range strings.Split(s, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			addrs = append(addrs, r)
+		}
+	}
+	return addrs
+}
+
+// resolverAddr returns the address ("ip:port") of a resolver, appending
+// defaultPort if it's a bare ip, with or without brackets. Anything else, e.g.
+// an address which already has a port or a URL, is returned as-is.
+func resolverAddr(addr, defaultPort string) string {
+	ip := strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if _, err := netip.ParseAddr(ip); err != nil {
+		return addr
+	}
+	return net.JoinHostPort(ip, defaultPort)
+}
+
+// resolverAddrs returns the addresses of the resolvers using resolverAddr,
+// only allocating a new slice if any of them changed
+func resolverAddrs(addrs []string, defaultPort string) []string {
+	out := addrs
+	var copied bool
+	for i, addr := range addrs {
+		if a := resolverAddr(addr, defaultPort); a != addr {
+			if !copied {
+				out, copied = append([]string(nil), addrs...), true
+			}
+			out[i] = a
+		}
+	}
+	return out
+}
+
+// defaultPort returns the port used for resolvers given without one, based on
+// Net
+func (sc *SRVClient) defaultPort() string {
+	switch sc.Net {
+	case NetTLS:
+		return "853"
+	case NetHTTPS:
+		return "443"
+	}
+	return "53"
+}
+
+// ResolversFromEnv returns the resolvers set in the ResolversEnv environment
+// variable, if any
+func ResolversFromEnv() []string {
+	return ParseResolverAddrs(os.Getenv(ResolversEnv), "53")
+}
+
+var (
+	envResolvers     []string
+	envResolversOnce sync.Once
+)
+
+// defaultResolvers returns the resolvers from the environment, which are only
+// read once. Bare ips are returned without a port, so that each SRVClient can
+// add the default one for its Net.
+func defaultResolvers() []string {
+	envResolversOnce.Do(func() {
+		envResolvers = splitResolverAddrs(os.Getenv(ResolversEnv))
+	})
+	return envResolvers
+}
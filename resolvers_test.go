@@ -0,0 +1,70 @@
+package srvclient
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResolverAddrs(t *testing.T) {
+	assert.Empty(t, ParseResolverAddrs("", "53"))
+	assert.Equal(t,
+		[]string{"1.2.3.4:53", "5.6.7.8:5353", "resolver.local:53"},
+		ParseResolverAddrs("1.2.3.4, 5.6.7.8:5353,,resolver.local:53", "53"),
+	)
+	assert.Equal(t, []string{"1.2.3.4:853"}, ParseResolverAddrs("1.2.3.4", "853"))
+}
+
+func TestResolversFromEnv(t *testing.T) {
+	t.Setenv(ResolversEnv, "1.2.3.4,5.6.7.8:5353")
+	assert.Equal(t, []string{"1.2.3.4:53", "5.6.7.8:5353"}, ResolversFromEnv())
+
+	t.Setenv(ResolversEnv, "")
+	assert.Empty(t, ResolversFromEnv())
+}
+
+func TestResolversEnvDefaultPort(t *testing.T) {
+	// the environment is only read once, so it has to be read again here
+	resetEnv := func() {
+		envResolvers, envResolversOnce = nil, sync.Once{}
+	}
+	resetEnv()
+	t.Cleanup(resetEnv)
+	t.Setenv(ResolversEnv, "1.2.3.4,::1,5.6.7.8:5353")
+
+	sc := &SRVClient{Net: NetTLS}
+	servers, err := sc.CurrentResolvers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4:853", "[::1]:853", "5.6.7.8:5353"}, servers)
+
+	sc = &SRVClient{Net: NetHTTPS}
+	servers, err = sc.CurrentResolvers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4:443", "[::1]:443", "5.6.7.8:5353"}, servers)
+
+	servers, err = new(SRVClient).CurrentResolvers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4:53", "[::1]:53", "5.6.7.8:5353"}, servers)
+}
+
+func TestResolverAddrIPv6(t *testing.T) {
+	assert.Equal(t,
+		[]string{"[::1]:53", "[fe80::1%eth0]:53", "[2001:db8::1]:53", "[::1]:5353"},
+		ParseResolverAddrs("::1,fe80::1%eth0,[2001:db8::1],[::1]:5353", "53"),
+	)
+	assert.Equal(t, "https://dns.test/dns-query", resolverAddr("https://dns.test/dns-query", "443"))
+
+	addrs := []string{"1.2.3.4:53"}
+	assert.Equal(t, addrs, resolverAddrs(addrs, "53"))
+	addrs = []string{"1.2.3.4:53", "::1"}
+	assert.Equal(t, []string{"1.2.3.4:53", "[::1]:53"}, resolverAddrs(addrs, "53"))
+	assert.Equal(t, "::1", addrs[1])
+
+	// ResolverAddrs can be bare ips too, using the default port for Net
+	sc := &SRVClient{ResolverAddrs: []string{"::1"}, Net: NetTLS}
+	servers, err := sc.CurrentResolvers()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"[::1]:853"}, servers)
+}
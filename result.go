@@ -0,0 +1,210 @@
+package srvclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Record describes a single SRV record returned from a lookup
+type Record struct {
+	Target   string `json:"target"`
+	Port     uint16 `json:"port"`
+	Priority uint16 `json:"priority"`
+	Weight   uint16 `json:"weight"`
+	TTL      uint32 `json:"ttl"`
+
+	// IPs contains the A and AAAA records for Target which the resolver
+	// included in its response, if any
+	IPs []net.IP `json:"ips,omitempty"`
+}
+
+// Result describes the full result of a SRV lookup
+type Result struct {
+	Hostname string `json:"hostname"`
+
+	// Resolver is the address of the resolver which answered. It's empty if the
+	// answer didn't come directly from a resolver, e.g. it came from the cache.
+	Resolver string `json:"resolver,omitempty"`
+
+	// Records are sorted by priority and then weight, like AllSRV
+	Records []Record `json:"records"`
+
+	// TTL is the smallest TTL of the records, and Expires is when the answer
+	// expires, i.e. when it was looked up plus the TTL. Callers with their own
+	// caches can use it to refresh them in line with DNS.
+	TTL     uint32    `json:"ttl"`
+	Expires time.Time `json:"expires"`
+
+	// TXT contains the strings of the TXT records for each target, keyed by
+	// Target, which the resolver included in its response, e.g. metadata like
+	// a version published alongside the SRV records. No queries are made for
+	// them, so targets without any aren't included.
+	TXT map[string][]string `json:"txt,omitempty"`
+}
+
+// targetIPs returns the IPs for the given SRV target included in a response,
+// following any CNAMEs for it
+func targetIPs(target string, extra []dns.RR) []net.IP {
+	return addrsFor(target, extra)
+}
+
+// targetTXT returns the strings of the TXT records for the given SRV target
+// included in a response
+func targetTXT(target string, extra []dns.RR) []string {
+	var txts []string
+	for _, rr := range extra {
+		if txt, ok := rr.(*dns.TXT); ok && strings.EqualFold(txt.Hdr.Name, target) {
+			txts = append(txts, txt.Txt...)
+		}
+	}
+	return txts
+}
+
+// LookupSRV calls the LookupSRV method on the DefaultSRVClient
+func LookupSRV(hostname string) (*Result, error) {
+	return DefaultSRVClient.LookupSRV(hostname)
+}
+
+// LookupSRVContext calls the LookupSRVContext method on the DefaultSRVClient
+func LookupSRVContext(ctx context.Context, hostname string) (*Result, error) {
+	return DefaultSRVClient.LookupSRVContext(ctx, hostname)
+}
+
+// LookupSRV calls LookupSRVContext with an empty context
+func (sc *SRVClient) LookupSRV(hostname string) (*Result, error) {
+	return sc.LookupSRVContext(context.Background(), hostname)
+}
+
+// LookupSRVContext performs a SRV request on the given hostname and returns all
+// of the records, along with whichever IPs the resolver provided for each of
+// their targets. Unlike the other methods the hostname must not contain a port.
+//
+// Like AllSRV, a non-nil Result can be returned along with an error, e.g. when
+// the last successful response was used because the query failed.
+func (sc *SRVClient) LookupSRVContext(ctx context.Context, hostname string) (*Result, error) {
+	return sc.lookupResult(ctx, hostname, false, sc.shouldResolveTargets())
+}
+
+// lookupResult implements LookupSRVContext. If resolveTargets is true then
+// follow-up queries are made for targets which the response didn't include any
+// IPs for.
+func (sc *SRVClient) lookupResult(ctx context.Context, hostname string, skipCache, resolveTargets bool) (*Result, error) {
+	msg, server, err := sc.lookupMsg(ctx, hostname, dns.TypeSRV, skipCache)
+	if msg == nil {
+		return nil, err
+	}
+
+	ans := sc.answersFromMsg(ctx, nil, msg, false)
+	if sc.PostProcess != nil && len(ans) > 0 {
+		ans = sc.PostProcess(ans)
+	}
+	if len(ans) == 0 {
+		return nil, noAnswersErr(hostname, server, msg)
+	}
+	sortSRVs(ans)
+
+	ttl := minTTL(ans)
+	res := &Result{
+		Hostname: hostname,
+		Resolver: server,
+		Records:  make([]Record, len(ans)),
+		TTL:      ttl,
+		Expires:  time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+	lookup := sc.targetIPsLookup(ctx, skipCache)
+	for i, srv := range ans {
+		ips := targetIPs(srv.Target, sc.extra(msg))
+		if len(ips) == 0 && resolveTargets {
+			ips = lookup(srv.Target)
+		}
+		txts := targetTXT(srv.Target, sc.extra(msg))
+		if sc.UnicodeTargets {
+			srv = unicodeSRV(srv)
+		}
+		if len(txts) > 0 {
+			if res.TXT == nil {
+				res.TXT = map[string][]string{}
+			}
+			if _, ok := res.TXT[srv.Target]; !ok {
+				res.TXT[srv.Target] = txts
+			}
+		}
+		res.Records[i] = Record{
+			Target:   srv.Target,
+			Port:     srv.Port,
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+			TTL:      srv.Hdr.Ttl,
+			IPs:      orderIPs(ips, sc.IPPreference),
+		}
+	}
+	return res, err
+}
+
+// allSRVAllIPs implements AllSRVTranslate for PreferAll, returning an address
+// for every IP of each target, or the target itself if it has none
+func (sc *SRVClient) allSRVAllIPs(ctx context.Context, hostname, port string, skipCache bool) ([]string, error) {
+	res, err := sc.lookupResult(ctx, hostname, skipCache, sc.shouldResolveTargets())
+	if res == nil {
+		return nil, err
+	}
+	if sc.WeightedShuffle {
+		shuffleRecords(res.Records, sc.intn)
+	}
+	var addrs []string
+	for _, r := range res.Records {
+		p := port
+		if p == "" {
+			p = strconv.Itoa(int(r.Port))
+		}
+		if len(r.IPs) == 0 {
+			addrs = append(addrs, net.JoinHostPort(r.Target, p))
+		}
+		for _, ip := range r.IPs {
+			addrs = append(addrs, net.JoinHostPort(ip.String(), p))
+		}
+	}
+	return addrs, err
+}
+
+// SRVAllIPs calls the SRVAllIPs method on the DefaultSRVClient
+func SRVAllIPs(ctx context.Context, hostname string) ([]Record, error) {
+	return DefaultSRVClient.SRVAllIPs(ctx, hostname)
+}
+
+// SRVAllIPs performs a SRV request on the given hostname and returns all of the
+// records, sorted by priority and then weight, each with every A and AAAA
+// address found for its target. Addresses come from the response if it
+// included any for the target, otherwise from follow-up queries, regardless of
+// ResolveTargets. They're ordered according to IPPreference. This is meant for
+// callers which dial the addresses themselves, e.g. with happy eyeballs.
+//
+// Like SRV, if hostname contains a port, or a service name like "https", then it
+// replaces the port of all of the records.
+func (sc *SRVClient) SRVAllIPs(ctx context.Context, hostname string) ([]Record, error) {
+	var port uint16
+	if h, p := splitHostPort(hostname); p != "" {
+		pi, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in %q: %w", hostname, err)
+		}
+		hostname, port = h, uint16(pi)
+	}
+
+	res, err := sc.lookupResult(ctx, hostname, false, true)
+	if res == nil {
+		return nil, err
+	}
+	if port != 0 {
+		for i := range res.Records {
+			res.Records[i].Port = port
+		}
+	}
+	return res.Records, err
+}
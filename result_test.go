@@ -0,0 +1,105 @@
+package srvclient
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupSRVResult(t *testing.T) {
+	res, err := LookupSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, testHostname, res.Hostname)
+	assert.Equal(t, DefaultSRVClient.ResolverAddrs[0], res.Resolver)
+	require.Len(t, res.Records, 2)
+
+	r := res.Records[0]
+	assert.Equal(t, "1.srv.test.", r.Target)
+	assert.EqualValues(t, 1000, r.Port)
+	assert.EqualValues(t, 60, r.TTL)
+	require.Len(t, r.IPs, 1)
+	assert.Equal(t, "10.0.0.1", r.IPs[0].String())
+
+	r = res.Records[1]
+	assert.Equal(t, "2.srv.test.", r.Target)
+	assert.EqualValues(t, 1001, r.Port)
+	require.Len(t, r.IPs, 1)
+	assert.Equal(t, "2607:5300:60:92e7::1", r.IPs[0].String())
+
+	_, err = LookupSRV("fail")
+	assert.IsType(t, &ErrNotFound{}, err)
+}
+
+func TestLookupSRVTXT(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		name := r.Question[0].Name
+		m.Answer = []dns.RR{
+			newRR(name + " 60 IN SRV 0 0 1000 a.srv.test."),
+			newRR(name + " 60 IN SRV 0 0 1001 a.srv.test."),
+			newRR(name + " 60 IN SRV 0 0 1000 b.srv.test."),
+		}
+		m.Extra = []dns.RR{
+			newRR(`A.srv.test. 60 IN TXT "version=1.2" "zone=us-east"`),
+			newRR(`a.srv.test. 60 IN TXT "canary"`),
+			newRR(`other.srv.test. 60 IN TXT "ignored"`),
+			newRR("b.srv.test. 60 IN A 10.0.0.2"),
+		}
+		w.WriteMsg(m)
+	})
+
+	sc := &SRVClient{ResolverAddrs: []string{addr}}
+	res, err := sc.LookupSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"a.srv.test.": {"version=1.2", "zone=us-east", "canary"},
+	}, res.TXT)
+
+	sc = &SRVClient{ResolverAddrs: []string{addr}, IgnoreExtra: true}
+	res, err = sc.LookupSRV(testHostname)
+	require.NoError(t, err)
+	assert.Nil(t, res.TXT)
+}
+
+func TestSRVAllIPs(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Qtype == dns.TypeSRV:
+			m.Answer = []dns.RR{
+				newRR("all.test. 60 IN SRV 0 0 1000 1.all.test."),
+				newRR("all.test. 60 IN SRV 1 0 1001 2.all.test."),
+			}
+			m.Extra = []dns.RR{
+				newRR("1.all.test. 60 IN A 10.0.0.1"),
+				newRR("1.all.test. 60 IN AAAA ::1"),
+			}
+		case q.Name == "2.all.test." && q.Qtype == dns.TypeA:
+			m.Answer = []dns.RR{newRR("2.all.test. 60 IN A 10.0.0.2")}
+		case q.Name == "2.all.test." && q.Qtype == dns.TypeAAAA:
+			m.Answer = []dns.RR{newRR("2.all.test. 60 IN AAAA ::2")}
+		}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	records, err := client.SRVAllIPs(context.Background(), "all.test")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "1.all.test.", records[0].Target)
+	assert.Equal(t, []net.IP{net.ParseIP("10.0.0.1").To4(), net.ParseIP("::1")}, records[0].IPs)
+	assert.Len(t, records[1].IPs, 2)
+
+	records, err = client.SRVAllIPs(context.Background(), "all.test:80")
+	require.NoError(t, err)
+	assert.EqualValues(t, 80, records[0].Port)
+	assert.EqualValues(t, 80, records[1].Port)
+}
@@ -0,0 +1,86 @@
+package srvclient
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// searchNames returns the names which are queried, in order, for hostname.
+// Unless Search is set, or if the hostname ends in a dot, that's only the
+// hostname itself. Otherwise, like libc, the hostname is tried with each search
+// domain appended, and by itself first if it has at least ndots dots or last if
+// it doesn't.
+func (sc *SRVClient) searchNames(hostname string) []string {
+	if !sc.Search || strings.HasSuffix(hostname, ".") {
+		return []string{hostname}
+	}
+
+	domains, ndots := sc.SearchDomains, sc.Ndots
+	if len(domains) == 0 || ndots == 0 {
+		// the resolvers are checked again by the lookup itself, so an error
+		// here only means that the search options aren't known
+		if _, _, cfg, err := sc.clientConfig(); err == nil {
+			if len(domains) == 0 {
+				domains = cfg.Search
+			}
+			if ndots == 0 {
+				ndots = cfg.Ndots
+			}
+		}
+	}
+
+	names := make([]string, 0, len(domains)+1)
+	for _, domain := range domains {
+		if domain = strings.Trim(domain, "."); domain != "" {
+			names = append(names, hostname+"."+domain+".")
+		}
+	}
+	if strings.Count(hostname, ".") >= ndots {
+		return append([]string{hostname + "."}, names...)
+	}
+	return append(names, hostname+".")
+}
+
+// isNotFoundMsg returns whether the response means that the name it was for has
+// no records of the given type, in which case the next search name is tried
+func isNotFoundMsg(msg *dns.Msg, qtype uint16) bool {
+	if msg == nil {
+		return false
+	} else if msg.Rcode == dns.RcodeNameError {
+		return true
+	} else if msg.Rcode != dns.RcodeSuccess {
+		return false
+	}
+	for _, rr := range msg.Answer {
+		if rr.Header().Rrtype == qtype {
+			return false
+		}
+	}
+	return true
+}
+
+// withServicePrefix returns hostname with ServicePrefix prepended, unless it's
+// unset or hostname already starts with a service or protocol label
+func (sc *SRVClient) withServicePrefix(hostname string) string {
+	prefix := strings.Trim(sc.ServicePrefix, ".")
+	if prefix == "" || strings.HasPrefix(hostname, "_") {
+		return hostname
+	}
+	return prefix + "." + hostname
+}
+
+// unprefixedOverride returns the override for hostname as it was passed, before
+// ServicePrefix is prepended, so that Overrides can be keyed by logical names.
+// The prefixed name's overrides are checked by the lookup itself.
+func (sc *SRVClient) unprefixedOverride(hostname string, qtype uint16) (*dns.Msg, error) {
+	if qtype != dns.TypeSRV || sc.withServicePrefix(hostname) == hostname {
+		return nil, nil
+	}
+	fqdn, err := normalizeHostname(hostname)
+	if err != nil {
+		// the lookup of the prefixed name reports this
+		return nil, nil
+	}
+	return sc.overrideMsg(fqdn)
+}
@@ -0,0 +1,154 @@
+package srvclient
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	var l sync.Mutex
+	var queried []string
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		l.Lock()
+		queried = append(queried, r.Question[0].Name)
+		l.Unlock()
+		m := new(dns.Msg)
+		m.SetReply(r)
+		switch r.Question[0].Name {
+		case "_svc._tcp.b.test.":
+			m.Answer = []dns.RR{newRR("_svc._tcp.b.test. 60 IN SRV 0 0 1000 1.b.test.")}
+		case "_svc._tcp.":
+			m.Answer = []dns.RR{newRR("_svc._tcp. 60 IN SRV 0 0 2000 1.root.")}
+		case "_svc._tcp.a.test.":
+			// no records, so the next name is tried
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	})
+	lookup := func(sc *SRVClient, hostname string) ([]string, []string) {
+		l.Lock()
+		queried = nil
+		l.Unlock()
+		sc.ResolverAddrs = []string{addr}
+		addrs, _ := sc.AllSRV(hostname)
+		l.Lock()
+		defer l.Unlock()
+		return addrs, append([]string(nil), queried...)
+	}
+
+	// without Search hostnames are always fully qualified
+	addrs, got := lookup(new(SRVClient), "_svc._tcp")
+	assert.Equal(t, []string{"1.root.:2000"}, addrs)
+	assert.Equal(t, []string{"_svc._tcp."}, got)
+
+	// with fewer than ndots dots the search domains are tried first
+	sc := &SRVClient{Search: true, SearchDomains: []string{"a.test", ".b.test."}, Ndots: 2}
+	addrs, got = lookup(sc, "_svc._tcp")
+	assert.Equal(t, []string{"1.b.test.:1000"}, addrs)
+	assert.Equal(t, []string{"_svc._tcp.a.test.", "_svc._tcp.b.test."}, got)
+
+	// otherwise the hostname is tried by itself first
+	sc = &SRVClient{Search: true, SearchDomains: []string{"a.test", "b.test"}, Ndots: 1}
+	addrs, got = lookup(sc, "_svc._tcp")
+	assert.Equal(t, []string{"1.root.:2000"}, addrs)
+	assert.Equal(t, []string{"_svc._tcp."}, got)
+
+	// a trailing dot forces the hostname to be looked up as it is
+	sc = &SRVClient{Search: true, SearchDomains: []string{"b.test"}, Ndots: 5}
+	addrs, got = lookup(sc, "_other._tcp.")
+	assert.Empty(t, addrs)
+	assert.Equal(t, []string{"_other._tcp."}, got)
+
+	// when none of the names have records the error is for the hostname
+	sc = &SRVClient{Search: true, SearchDomains: []string{"a.test"}, Ndots: 5}
+	sc.ResolverAddrs = []string{addr}
+	_, err := sc.AllSRV("_other._tcp")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNoRecords)
+	assert.Contains(t, err.Error(), `"_other._tcp"`)
+}
+
+func TestServicePrefix(t *testing.T) {
+	var l sync.Mutex
+	var queried []string
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		q := r.Question[0]
+		l.Lock()
+		queried = append(queried, dns.TypeToString[q.Qtype]+" "+q.Name)
+		l.Unlock()
+		m := new(dns.Msg)
+		m.SetReply(r)
+		switch {
+		case q.Qtype == dns.TypeSRV && q.Name == "_grpc._tcp.billing.test.":
+			m.Answer = []dns.RR{newRR(q.Name + " 60 IN SRV 0 0 1000 1.billing.test.")}
+		case q.Qtype == dns.TypeSRV && q.Name == "_http._tcp.billing.test.":
+			m.Answer = []dns.RR{newRR(q.Name + " 60 IN SRV 0 0 80 1.billing.test.")}
+		case q.Qtype == dns.TypeA && q.Name == "1.billing.test.":
+			m.Answer = []dns.RR{newRR(q.Name + " 60 IN A 10.0.0.5")}
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	})
+	reset := func() []string {
+		l.Lock()
+		defer l.Unlock()
+		q := queried
+		queried = nil
+		return q
+	}
+
+	sc := &SRVClient{
+		ResolverAddrs:  []string{addr},
+		ServicePrefix:  "_grpc._tcp.",
+		ResolveTargets: true,
+	}
+	got, err := sc.SRV("billing.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5:1000", got)
+	// the prefix isn't added to the lookup of the target
+	q := reset()
+	assert.Equal(t, "SRV _grpc._tcp.billing.test.", q[0])
+	assert.Contains(t, q, "A 1.billing.test.")
+
+	// full SRV names are used as they are
+	got, err = sc.SRV("_http._tcp.billing.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5:80", got)
+	assert.Equal(t, "SRV _http._tcp.billing.test.", reset()[0])
+
+	// and it's added after the search domains
+	sc = &SRVClient{
+		ResolverAddrs: []string{addr},
+		ServicePrefix: "_grpc._tcp",
+		Search:        true,
+		SearchDomains: []string{"test"},
+		Ndots:         1,
+	}
+	_, err = sc.AllSRV("billing")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SRV _grpc._tcp.billing.test."}, reset())
+	assert.Equal(t, "_grpc._tcp", sc.Describe().ServicePrefix)
+
+	// overrides can be keyed by the name with or without the prefix
+	sc = &SRVClient{
+		ResolverAddrs: []string{addr},
+		ServicePrefix: "_grpc._tcp.",
+		Overrides: map[string][]string{
+			"billing.test":            {"10.0.0.6:2000"},
+			"_grpc._tcp.payroll.test": {"10.0.0.7:3000"},
+		},
+	}
+	got, err = sc.SRV("billing.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.6:2000", got)
+	got, err = sc.SRV("payroll.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.7:3000", got)
+	assert.Empty(t, reset())
+}
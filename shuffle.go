@@ -0,0 +1,103 @@
+package srvclient
+
+import (
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// weightedOrder returns the order records with the given priorities and
+// weights should be used in, as described by RFC 2782: by priority, and within
+// each priority a weighted random permutation, where records with a weight of 0
+// have a small chance of being first, and records which all have a weight of 0
+// are shuffled evenly. The records must already be sorted by
+// priority.
+func weightedOrder(priorities, weights []uint16, intn func(int) int) []int {
+	order := make([]int, 0, len(priorities))
+	group := make([]int, 0, len(priorities))
+	for start := 0; start < len(priorities); {
+		end := start
+		for end < len(priorities) && priorities[end] == priorities[start] {
+			end++
+		}
+
+		// the records start in a random order, except that the zero weight
+		// ones go first, so that they can only be picked when the random
+		// number is 0
+		group = group[:0]
+		for i := start; i < end; i++ {
+			group = append(group, i)
+		}
+		for i := len(group) - 1; i > 0; i-- {
+			j := intn(i + 1)
+			group[i], group[j] = group[j], group[i]
+		}
+		sort.SliceStable(group, func(a, b int) bool {
+			return weights[group[a]] == 0 && weights[group[b]] != 0
+		})
+		var sum int
+		for _, i := range group {
+			sum += int(weights[i])
+		}
+
+		for len(group) > 0 {
+			r := intn(sum + 1)
+			pick := len(group) - 1
+			var running int
+			for j, i := range group {
+				running += int(weights[i])
+				if running >= r {
+					pick = j
+					break
+				}
+			}
+			order = append(order, group[pick])
+			sum -= int(weights[group[pick]])
+			group = append(group[:pick], group[pick+1:]...)
+		}
+		start = end
+	}
+	return order
+}
+
+// shuffleSRVs orders ans in the way described by weightedOrder
+func shuffleSRVs(ans []*dns.SRV, intn func(int) int) {
+	sortSRVs(ans)
+	priorities := make([]uint16, len(ans))
+	weights := make([]uint16, len(ans))
+	for i, srv := range ans {
+		priorities[i], weights[i] = srv.Priority, srv.Weight
+	}
+	order := weightedOrder(priorities, weights, intn)
+	shuffled := make([]*dns.SRV, len(ans))
+	for i, j := range order {
+		shuffled[i] = ans[j]
+	}
+	copy(ans, shuffled)
+}
+
+// shuffleRecords orders records, which must already be sorted, in the way
+// described by weightedOrder
+func shuffleRecords(records []Record, intn func(int) int) {
+	priorities := make([]uint16, len(records))
+	weights := make([]uint16, len(records))
+	for i, r := range records {
+		priorities[i], weights[i] = r.Priority, r.Weight
+	}
+	order := weightedOrder(priorities, weights, intn)
+	shuffled := make([]Record, len(records))
+	for i, j := range order {
+		shuffled[i] = records[j]
+	}
+	copy(records, shuffled)
+}
+
+// orderSRVs orders the answers returned by AllSRV, which are shuffled if
+// WeightedShuffle is set and otherwise sorted
+func (sc *SRVClient) orderSRVs(ans []*dns.SRV) {
+	if sc.WeightedShuffle {
+		shuffleSRVs(ans, sc.intn)
+	} else {
+		sortSRVs(ans)
+	}
+}
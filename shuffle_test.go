@@ -0,0 +1,56 @@
+package srvclient
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedOrder(t *testing.T) {
+	// priorities are still respected, and every record is used once
+	order := weightedOrder([]uint16{0, 0, 0, 1}, []uint16{5, 0, 5, 1}, randIntn)
+	assert.ElementsMatch(t, []int{0, 1, 2}, order[:3])
+	assert.Equal(t, 3, order[3])
+
+	// the heaviest record is first most often, but not always
+	srvs := []*dns.SRV{
+		{Target: "a.", Weight: 10},
+		{Target: "b.", Weight: 30},
+		{Target: "c.", Priority: 1, Weight: 100},
+	}
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		ans := append([]*dns.SRV(nil), srvs...)
+		shuffleSRVs(ans, randIntn)
+		require.Len(t, ans, 3)
+		assert.Equal(t, "c.", ans[2].Target)
+		counts[ans[0].Target]++
+	}
+	assert.Greater(t, counts["b."], counts["a."])
+	assert.Greater(t, counts["a."], 0)
+
+	// zero weight records only have a small chance of being first
+	counts = map[string]int{}
+	for i := 0; i < 1000; i++ {
+		order := weightedOrder([]uint16{0, 0}, []uint16{0, 100}, randIntn)
+		counts[fmt.Sprint(order)]++
+	}
+	assert.Greater(t, counts["[1 0]"], 900)
+	assert.Greater(t, counts["[0 1]"], 0)
+}
+
+func TestAllSRVWeightedShuffle(t *testing.T) {
+	client := SRVClient{WeightedShuffle: true}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		srvs, err := client.AllSRV(testHostname)
+		require.NoError(t, err)
+		require.Len(t, srvs, 2)
+		seen[srvs[0]] = true
+	}
+	assert.Len(t, seen, 2)
+}
@@ -6,8 +6,9 @@ package srvclient
 
 import (
 	"context"
-	"errors"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
 	"sort"
@@ -25,48 +26,392 @@ func init() {
 }
 
 type inFlightRes struct {
-	msg  *dns.Msg
-	err  error
+	rawLookup
 	done chan struct{}
 }
 
+// rawLookup holds the responses from the resolvers for a lookup, before they've
+// been preprocessed or cached. It's what's shared between in-flight lookups,
+// since those steps depend on each caller's options. Apart from Preprocess
+// nothing modifies the responses, records are replaced instead, so they can be
+// shared as-is when there isn't one.
+type rawLookup struct {
+	res, tres          *dns.Msg
+	resServer, tServer string
+	err                error
+}
+
+// copy returns a rawLookup with copies of the responses, so they can be
+// modified
+func (r rawLookup) copy() rawLookup {
+	if r.res != nil {
+		r.res = r.res.Copy()
+	}
+	if r.tres != nil {
+		r.tres = r.tres.Copy()
+	}
+	return r
+}
+
+// merge records the outcome of querying another resolver after the ones r
+// already has the outcome of. The truncated response is kept from an earlier
+// resolver if the new one didn't have one.
+func (r *rawLookup) merge(sr rawLookup) {
+	r.res, r.resServer, r.err = sr.res, sr.resServer, sr.err
+	if sr.tres != nil {
+		r.tres, r.tServer = sr.tres, sr.tServer
+	}
+}
+
 // SRVClient is a holder for methods related to SRV lookups. Use new(SRVClient)
 // to initialize one.
 type SRVClient struct {
 	cacheLast     map[string]*dns.Msg
 	cacheLastL    sync.RWMutex
-	client        *dns.Client
-	tcpClient     *dns.Client
+	client        Exchanger
+	tcpClient     Exchanger
 	lastConfig    clientConfig
 	clientConfigL sync.RWMutex
 	inFlights     sync.Map
+	exchangeSem   chan struct{}
+	exchangeSemO  sync.Once
+	rateLimiters  sync.Map
+	noEDNS        sync.Map
+	overridesFile overridesFile
+	ttlCache      *MemoryCache
+	ttlCacheO     sync.Once
+	ttlEntries    sync.Map
 
 	// OnExchangeError specifies an optional function to call for exchange errors
 	// that otherwise might be ignored if another server did not error.
 	OnExchangeError func(ctx context.Context, hostname string, server string, error error)
 
+	// OnConfigReload specifies an optional function to call when a change to
+	// /etc/resolv.conf has been picked up, with the new configuration. Its
+	// Servers are the resolvers which will be used from then on.
+	OnConfigReload func(cfg dns.ClientConfig)
+
+	// OnExchange specifies an optional function to call after every exchange
+	// with a resolver, successful or not, with how long it took. msg is the
+	// response, if there was one, and must not be modified.
+	OnExchange func(ctx context.Context, hostname string, server string, rtt time.Duration, msg *dns.Msg, err error)
+
 	// UDPSize specifies the maximum receive buffer for UDP messages
 	UDPSize uint16
 
+	// EDNS determines how EDNS0 is used to advertise UDPSize. Defaults to
+	// EDNSFallback.
+	EDNS EDNSPolicy
+
+	// MaxAnswers, if set, is the largest number of records a response's answer
+	// section can contain, and MaxResponseSize is the largest size in bytes a
+	// response can be. Responses over either limit are rejected with an
+	// ErrLimitExceeded, wrapped in an ErrExchange, rather than processed. This
+	// protects against zones which return thousands of records. Otherwise only
+	// the first 1024 answers of a response are processed.
+	MaxAnswers      int
+	MaxResponseSize int
+
 	// If IgnoreTruncated is true, then lookups will NOT fallback to TCP when
 	// they were truncated over UDP.
+	//
+	// Deprecated: set Truncation to TruncationUseTruncated instead.
 	IgnoreTruncated bool
 
+	// Truncation determines what happens when a UDP response is truncated.
+	// Defaults to TruncationTCP, unless IgnoreTruncated is set.
+	Truncation TruncationPolicy
+
+	// MaxUDPSize, if larger than UDPSize, makes queries whose UDP responses
+	// were truncated be retried over UDP first, doubling the advertised EDNS
+	// buffer each time up to MaxUDPSize, before falling back to TCP. This
+	// helps on networks which drop DNS over TCP but allow large UDP responses.
+	// With TruncationRetryUDP it's the largest buffer retried with, rather
+	// than the largest possible one. It doesn't apply to the other
+	// TruncationPolicy values.
+	MaxUDPSize uint16
+
+	// TCPHostnames maps hostnames, e.g. ones whose responses are known to
+	// always be truncated over UDP, to the TCPPolicy used for them instead of
+	// the default TCPFallback. It only applies when Net is NetUDP. This can
+	// only be updated before the SRVClient is used for the first time.
+	TCPHostnames map[string]TCPPolicy
+
+	// Net specifies the transport used for queries, one of NetUDP (the default,
+	// which falls back to TCP for truncated responses), NetTCP, NetTLS (DNS over
+	// TLS) or NetHTTPS (DNS over HTTPS). When using NetHTTPS the resolver
+	// addresses can either be a "host:port", in which case the /dns-query path
+	// is used, or a full URL. Queries over transports other than UDP are counted
+	// as TCPQueries. This can only be updated before the SRVClient is used for
+	// the first time.
+	Net string
+
+	// If ReuseUDPSockets is true then, rather than dialing a new UDP socket for
+	// every query, a single socket is kept open per resolver and used for all
+	// queries to it, with responses matched to queries by their ID and
+	// question. This reduces socket churn under heavy load, at the cost of the
+	// source port no longer being randomized per query. Sockets are closed
+	// once they've been idle for a minute. This can only be updated before
+	// the SRVClient is used for the first time.
+	ReuseUDPSockets bool
+
+	// If HardenUDP is true then every UDP query is sent with a message ID read
+	// directly from crypto/rand, from a new socket on a random port, and
+	// responses are checked to come from the resolver's address and port and
+	// to match the query's ID and question. Mismatched responses, which may be
+	// spoofing attempts, are ignored and counted in the MismatchedSources and
+	// MismatchedIDs stats. It takes precedence over ReuseUDPSockets and can
+	// only be updated before the SRVClient is used for the first time.
+	HardenUDP bool
+
+	// TLSConfig is used for the NetTLS and NetHTTPS transports. If nil then the
+	// default configuration is used.
+	TLSConfig *tls.Config
+
+	// Exchanger, if set, is used for all exchanges instead of the clients
+	// normally created from Net and TLSConfig, e.g. a Replayer. Since it's
+	// also used after truncated responses it should not truncate responses
+	// itself. This can only be updated before the SRVClient is used for the
+	// first time.
+	Exchanger Exchanger
+
+	// Cache, if set, is used to store the last successful responses, as if
+	// EnableCacheLast was called, instead of a cache private to the SRVClient.
+	// This allows many SRVClients to share one cache, e.g. NewMemoryCache(),
+	// or to bound its memory usage using NewBoundedMemoryCache.
+	// Responses are cached by hostname, so SRVClients sharing a cache should
+	// be using equivalent resolvers.
+	Cache Cache
+
+	// TTLCache, if set, answers lookups from Cache, or a cache private to the
+	// SRVClient if Cache isn't set, until the smallest TTL of the response's
+	// answers runs out, rather than querying the resolvers every time. The
+	// TTLs of cached responses are reduced by how long they've been cached,
+	// so SRVWithTTL and LookupSRV report how much longer they're valid for.
+	TTLCache bool
+
+	// StaleWhileRefresh is how long past its TTL a response in the TTL cache
+	// can still be used. The first lookup for it after the TTL runs out
+	// refreshes it, and other lookups are given the expiring response until
+	// that finishes, so that many callers don't all query the resolvers at
+	// once. If the refresh fails then the expiring response is used for it
+	// too. Defaults to DefaultStaleWhileRefresh, and if negative then
+	// responses aren't used past their TTL.
+	StaleWhileRefresh time.Duration
+
+	// Events, if set, is sent an Event for each step of every lookup, e.g. to
+	// trace intermittent wrong answers from a debugging goroutine. Events are
+	// sent without blocking, so it should be buffered, and ones which don't
+	// fit are dropped and counted in the DroppedEvents stat.
+	Events chan<- Event
+
+	// Dnstap, if set, is used to log every query sent and response received in
+	// the dnstap format
+	Dnstap *DnstapLogger
+
+	// QueryLog, if set, is written a QueryLogEntry as a line of JSON for every
+	// lookup, e.g. for DNS SLO reporting. Follow-up lookups of the targets
+	// aren't logged separately.
+	QueryLog io.Writer
+
 	// A list of addresses ("ip:port") which should be used as the resolver
-	// list. If none are set then the resolver settings in /etc/resolv.conf are
-	// used. This can only be updated before the SRVClient is used for the first
-	// time.
+	// list. Bare ips, including IPv6 ones without brackets, are given the
+	// default port for Net, e.g. 53. If none are set then the resolvers in the
+	// ResolversEnv environment variable are used, or if that's not set then the
+	// resolver settings in /etc/resolv.conf are used. This can only be updated
+	// before the SRVClient is used for the first time.
 	ResolverAddrs []string
 
-	// If non-nill, will be called on messages returned from dns servers prior
-	// to them being processed (i.e. before they are cached, sorted,
+	// StandbyResolverAddrs is a list of addresses ("ip:port") of resolvers
+	// which are only queried when every one of the normal resolvers failed,
+	// e.g. central resolvers which back up datacenter-local ones. They're
+	// queried using the ResolverStrategy, like the normal ones, but aren't used
+	// for StubZones or resolvers set with WithResolvers. Lookups which fail
+	// over to them are counted in the StandbyLookups and StandbyErrors stats.
+	// This can only be updated before the SRVClient is used for the first
+	// time.
+	StandbyResolverAddrs []string
+
+	// StubZones maps domains to the resolvers ("ip:port") which should be used
+	// for them and their subdomains instead of the normal ones, e.g.
+	// {"consul": {"127.0.0.1:8600"}}. A leading "*." on a domain is ignored. If
+	// a hostname is in more than one domain then the longest one is used. This
+	// can only be updated before the SRVClient is used for the first time.
+	StubZones map[string][]string
+
+	// If Search is true then hostnames which don't end in a dot are looked up
+	// using the search list and ndots option, like libc does, rather than
+	// always being treated as fully qualified. Each search domain is appended
+	// to the hostname in turn, and the hostname is tried by itself first if it
+	// contains at least Ndots dots, or last if it doesn't, until one of them
+	// has records. Hostnames ending in a dot are only ever looked up as they
+	// are. SearchDomains and Ndots default to the search and ndots options in
+	// /etc/resolv.conf.
+	Search        bool
+	SearchDomains []string
+	Ndots         int
+
+	// ServicePrefix, if set, is prepended to the hostnames of SRV lookups,
+	// e.g. "_grpc._tcp" to look up "_grpc._tcp.billing" for "billing", so
+	// that callers can use logical service names and leave the SRV naming
+	// convention to the client. Hostnames whose first label already starts
+	// with an underscore are looked up as they are. It's prepended after the
+	// search domains are appended, and isn't used for follow-up lookups of the
+	// targets. Overrides can be keyed by the hostname either with or without
+	// the prefix, and ones without it take precedence.
+	ServicePrefix string
+
+	// If MDNS is true then hostnames in the "local" domain are resolved using
+	// multicast DNS on the local network, as described by RFC 6762, instead of
+	// the resolvers. StubZones take precedence.
+	MDNS bool
+
+	// Overrides maps hostnames to the targets ("host:port") which are used for
+	// them instead of querying the resolvers, e.g. to pin a service's targets
+	// during an incident without touching DNS. The hosts can be IPs or names,
+	// which are translated like normal targets, and the targets all have the
+	// same priority and weight. Overridden lookups aren't cached, and have a
+	// TTL of 0. When ServicePrefix is set the hostnames can be given with or
+	// without it. This can only be updated before the SRVClient is used for the
+	// first time.
+	Overrides map[string][]string
+
+	// OverridesFile, if set, is the path of a file of overrides which take
+	// precedence over both Overrides and the resolvers, e.g. for air-gapped or
+	// break-glass setups. Each line is a hostname, a target ("host:port") and
+	// an optional weight, separated by whitespace, and there can be a line per
+	// target. Blank lines and everything after a "#" are ignored. The file is
+	// checked for changes at most every 5 seconds and reloaded when it's
+	// modified. If it doesn't exist then nothing is overridden, and if it can't
+	// be loaded then the last version which could be keeps being used.
+	OverridesFile string
+
+	// If non-nill, will be called on SRV messages returned from dns servers
+	// prior to them being processed (i.e. before they are cached, sorted,
 	// ip-replaced, etc...)
 	Preprocess func(*dns.Msg)
 
+	// If non-nil, ValidateRecord is called with each SRV record of a lookup
+	// before any other processing, and the ones it returns false for are
+	// dropped, e.g. placeholders published by a zone. ValidSRV drops the ones
+	// which can't be connected to. Dropped records are counted in the
+	// DroppedRecords stat, and if every record is dropped then the lookup
+	// fails with an ErrNotFound.
+	ValidateRecord func(*dns.SRV) bool
+
+	// If non-nil, PostProcess is called with the SRV records of every lookup,
+	// after they've been parsed and their targets translated to IPs, and
+	// before one is picked or they're returned. It returns the records to use
+	// instead, e.g. with some removed or reordered, and if it returns none
+	// then the lookup fails with an ErrNotFound. The records may be shared
+	// with the cache, so they must be copied rather than modified. For
+	// LookupSRV the targets haven't been translated, since it returns their
+	// IPs separately.
+	PostProcess func([]*dns.SRV) []*dns.SRV
+
+	// If ResolveTargets is true then, when the IPs of SRV targets are needed
+	// (e.g. by SRV or AllSRVTranslate), targets which the response didn't
+	// include any IPs for are looked up with follow-up A and AAAA queries.
+	// CNAMEs for targets included in a response are always followed.
+	ResolveTargets bool
+
+	// If IgnoreExtra is true then the additional section of SRV responses is
+	// ignored, so that IPs are never taken from glue records which may be
+	// stale or wrong, and the IPs of targets are only ever obtained using
+	// follow-up queries, as if ResolveTargets was set.
+	IgnoreExtra bool
+
+	// IPPreference determines which IP is used when translating a SRV target
+	// which has both IPv4 and IPv6 addresses. Defaults to PreferFirst.
+	IPPreference IPPreference
+
+	// If UnicodeTargets is true then SRV targets which are punycode A-labels
+	// are converted back to Unicode in results. Hostnames being looked up can
+	// always be Unicode, they're converted to punycode before being queried.
+	UnicodeTargets bool
+
+	// MinAnswers, if set, is the fewest SRV records a successful response can
+	// contain. Responses with fewer, but at least one, are treated as failures
+	// with an ErrTooFewAnswers, and the last response is used in their place if
+	// EnableCacheLast was called. This protects against partial zone updates
+	// which briefly publish only some of the records.
+	MinAnswers int
+
+	// If DedupeAnswers is true then SRV records with the same target and port
+	// are merged into one, with the lowest of their priorities and the sum of
+	// their weights, before a record is picked or they're returned. Some load
+	// balanced setups return the same target multiple times.
+	DedupeAnswers bool
+
+	// PriorityClasses, if set, restricts the records used by lookups to the
+	// ones with the lowest PriorityClasses distinct priorities, e.g. 1 to only
+	// use the primary targets. Backup targets published with higher priority
+	// values then never appear in AllSRV results. WithPriority can be used to
+	// select a specific priority instead.
+	PriorityClasses int
+
+	// If WeightedShuffle is true then the AllSRV methods return the records in
+	// the order described by RFC 2782, i.e. by priority and then a random
+	// permutation weighted by their weights, rather than by priority and then
+	// weight. Callers which try the records in order then spread their
+	// attempts over the targets instead of always trying the highest weight
+	// one first.
+	WeightedShuffle bool
+
 	// SingleInFlight will combine duplicate lookups and only issue a single DNS
 	// query, mirroring the response to all callers.
 	SingleInFlight bool
 
+	// Picker is used to choose the record returned by the SRV methods. If nil
+	// then WeightedPicker is used.
+	Picker Picker
+
+	// MaxConcurrentExchanges limits the number of DNS exchanges this client will
+	// have outstanding at once. Exchanges over the limit wait for a slot to free
+	// up, or for their context to be canceled. 0 means no limit. This can only
+	// be updated before the SRVClient is used for the first time.
+	MaxConcurrentExchanges int
+
+	// ResolverRateLimit limits the number of queries per second sent to each
+	// resolver, with bursts of up to ResolverRateBurst queries allowed. Queries
+	// over the limit wait until they're allowed, or for their context to be
+	// canceled. 0 means no limit. These can only be updated before the
+	// SRVClient is used for the first time.
+	ResolverRateLimit float64
+	ResolverRateBurst int
+
+	// Jitter, if set, randomly shortens each resolver timeout, and the delay
+	// between lookups made by Refresher and Subscribe, by up to that fraction,
+	// e.g. 0.1 for up to 10%. This prevents many instances which started at the
+	// same time from querying and retrying against the resolvers in lockstep.
+	Jitter float64
+
+	// ResolverStrategy determines how the resolvers are queried when there's
+	// more than one, trading off load on the resolvers against the latency of
+	// lookups when some of them are slow or down. Defaults to
+	// StrategySequential.
+	ResolverStrategy ResolverStrategy
+
+	// HedgeDelay is how long StrategyHedged waits for a response from a
+	// resolver before also querying the next one. Defaults to 100ms.
+	HedgeDelay time.Duration
+
+	// DefaultTimeout, if set, limits how long a lookup can take in total,
+	// including trying every resolver, when the context it's made with has no
+	// deadline, e.g. when using the methods without a context like SRV and
+	// MaybeSRV. Otherwise each resolver is given the timeout from
+	// /etc/resolv.conf in turn. Follow-up queries for the IPs of targets are
+	// limited separately.
+	DefaultTimeout time.Duration
+
+	// Rand, if set, is used for the random choices made by the SRVClient,
+	// i.e. the picks made by WeightedPicker when Picker is nil and the
+	// Jitter, instead of a randomly seeded source. Setting it to e.g.
+	// rand.New(rand.NewSource(1)) makes the picks reproducible in tests. Calls
+	// to it are serialized, even between SRVClients sharing it, so it
+	// shouldn't be used by anything else.
+	Rand *rand.Rand
+
 	numUDPQueries         int64
 	numTCPQueries         int64
 	numTruncatedResponses int64
@@ -74,11 +419,21 @@ type SRVClient struct {
 	numCacheLastHits      int64
 	numCacheLastMisses    int64
 	numInFlightHits       int64
+	numMismatchedSources  int64
+	numMismatchedIDs      int64
+	numStandbyLookups     int64
+	numStandbyErrors      int64
+	numDroppedEvents      int64
+	numRejectedResponses  int64
+	numTTLCacheHits       int64
+	numTTLCacheStaleHits  int64
+	numDroppedRecords     int64
 }
 
 // EnableCacheLast is used to make SRVClient cache the last successful SRV
 // response for each domain requested, and if the next request results in some
-// kind of error it will use that last response instead.
+// kind of error it will use that last response instead. Setting Cache also
+// enables this, using that cache instead of one private to the SRVClient.
 func (sc *SRVClient) EnableCacheLast() {
 	sc.cacheLastL.Lock()
 	if sc.cacheLast == nil {
@@ -92,50 +447,88 @@ func (sc *SRVClient) EnableCacheLast() {
 // of the methods being used in order to modify their behavior
 var DefaultSRVClient = new(SRVClient)
 
-func replaceSRVTarget(r *dns.SRV, extra []dns.RR) *dns.SRV {
-	for _, e := range extra {
-		if eA, ok := e.(*dns.A); ok && eA.Hdr.Name == r.Target {
-			r.Target = eA.A.String()
-		} else if eAAAA, ok := e.(*dns.AAAA); ok && eAAAA.Hdr.Name == r.Target {
-			r.Target = eAAAA.AAAA.String()
-		}
+// replaceSRVTarget returns a copy of r with its Target replaced by the preferred
+// IP for it in extra, or r itself if there aren't any. The record is copied
+// since the response may be cached.
+func replaceSRVTarget(r *dns.SRV, extra []dns.RR, pref IPPreference) *dns.SRV {
+	ips := orderIPs(targetIPs(r.Target, extra), pref)
+	if len(ips) == 0 {
+		return r
 	}
+	r = dns.Copy(r).(*dns.SRV)
+	r.Target = ips[0].String()
 	return r
 }
 
 func (sc *SRVClient) doCacheLast(hostname string, res *dns.Msg) *dns.Msg {
-	if sc.cacheLast == nil {
+	if sc.Cache == nil && sc.cacheLast == nil {
 		return res
 	}
 
 	if res == nil || len(res.Answer) == 0 {
-		sc.cacheLastL.RLock()
-		defer sc.cacheLastL.RUnlock()
-		if cres, ok := sc.cacheLast[hostname]; ok {
+		if cres := sc.cacheLastGet(hostname); cres != nil {
 			res = cres
 			atomic.AddInt64(&sc.numCacheLastHits, 1)
+			sc.emit(Event{Type: EventCacheLastServed, Question: cacheLastQuestion(hostname)})
 		} else {
 			atomic.AddInt64(&sc.numCacheLastMisses, 1)
 		}
 		return res
 	}
 
+	sc.cacheLastSet(hostname, res)
+	return res
+}
+
+// cacheLastGet returns the last response cached under key, either from Cache
+// or the SRVClient's own cache
+func (sc *SRVClient) cacheLastGet(key string) *dns.Msg {
+	if sc.Cache != nil {
+		return sc.Cache.Get(key)
+	}
+	sc.cacheLastL.RLock()
+	defer sc.cacheLastL.RUnlock()
+	return sc.cacheLast[key]
+}
+
+func (sc *SRVClient) cacheLastSet(key string, res *dns.Msg) {
+	if sc.Cache != nil {
+		// the last response is kept until it's replaced
+		sc.Cache.Set(key, res, 0)
+		return
+	}
 	sc.cacheLastL.Lock()
 	defer sc.cacheLastL.Unlock()
-	sc.cacheLast[hostname] = res
-	return res
+	sc.cacheLast[key] = res
 }
 
-func (sc *SRVClient) newClient(cfg dns.ClientConfig) *dns.Client {
+func (sc *SRVClient) newClient(cfg dns.ClientConfig, network string) Exchanger {
+	var timeout time.Duration
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	if network == NetHTTPS {
+		return newDoHClient(sc.TLSConfig, timeout)
+	}
+
+	udpSize := sc.UDPSize
+	if udpSize == 0 {
+		udpSize = dns.DefaultMsgSize
+	}
+	if network == NetUDP && sc.HardenUDP {
+		return &hardenedUDP{sc: sc, udpSize: udpSize}
+	} else if network == NetUDP && sc.ReuseUDPSockets {
+		return newUDPMux(udpSize)
+	}
+
 	c := new(dns.Client)
-	if sc.UDPSize != 0 {
-		c.UDPSize = sc.UDPSize
-	} else {
-		c.UDPSize = dns.DefaultMsgSize
+	if network != NetUDP {
+		c.Net = network
 	}
+	c.TLSConfig = sc.TLSConfig
+	c.UDPSize = udpSize
 	// we don't use dns's SingleInFlight because of https://github.com/miekg/dns/issues/1449
-	if cfg.Timeout > 0 {
-		timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout > 0 {
 		c.DialTimeout = timeout
 		c.ReadTimeout = timeout
 		c.WriteTimeout = timeout
@@ -143,59 +536,181 @@ func (sc *SRVClient) newClient(cfg dns.ClientConfig) *dns.Client {
 	return c
 }
 
-func (sc *SRVClient) clientConfig() (*dns.Client, *dns.Client, dns.ClientConfig, error) {
+func (sc *SRVClient) isUDP() bool {
+	return sc.Net == "" || sc.Net == NetUDP
+}
+
+func (sc *SRVClient) clientConfig() (Exchanger, Exchanger, dns.ClientConfig, error) {
 	cfg, err := dnsGetConfig()
 	if err != nil {
 		return nil, nil, cfg.ClientConfig, err
 	}
 	if len(sc.ResolverAddrs) > 0 {
-		cfg.Servers = sc.ResolverAddrs
+		cfg.Servers = resolverAddrs(sc.ResolverAddrs, sc.defaultPort())
+	} else if env := defaultResolvers(); len(env) > 0 {
+		cfg.Servers = resolverAddrs(env, sc.defaultPort())
 	}
 
 	sc.clientConfigL.RLock()
-	shouldUpdate := sc.client == nil || sc.lastConfig.updated.Before(cfg.updated)
-	if shouldUpdate {
-		sc.clientConfigL.RUnlock()
-		sc.clientConfigL.Lock()
+	if sc.client != nil && !sc.lastConfig.updated.Before(cfg.updated) {
+		defer sc.clientConfigL.RUnlock()
+		return sc.client, sc.tcpClient, sc.lastConfig.ClientConfig, nil
+	}
+	sc.clientConfigL.RUnlock()
+
+	sc.clientConfigL.Lock()
+	// another call may have updated it while we were waiting for the lock
+	if sc.client != nil && !sc.lastConfig.updated.Before(cfg.updated) {
 		defer sc.clientConfigL.Unlock()
-		sc.client = sc.newClient(cfg.ClientConfig)
-		tcpClient := sc.newClient(cfg.ClientConfig)
-		tcpClient.Net = "tcp"
-		sc.tcpClient = tcpClient
-		sc.lastConfig = cfg
+		return sc.client, sc.tcpClient, sc.lastConfig.ClientConfig, nil
+	}
+	reloaded := sc.client != nil
+	network := sc.Net
+	if network == "" {
+		network = NetUDP
+	}
+	if sc.Exchanger != nil {
+		sc.client, sc.tcpClient = sc.Exchanger, sc.Exchanger
 	} else {
-		defer sc.clientConfigL.RUnlock()
+		sc.client = sc.newClient(cfg.ClientConfig, network)
+		sc.tcpClient = sc.newClient(cfg.ClientConfig, NetTCP)
+	}
+	sc.lastConfig = cfg
+	c, tcpc := sc.client, sc.tcpClient
+	sc.clientConfigL.Unlock()
+
+	// called once we've released the lock, in case it calls back into the
+	// SRVClient
+	if reloaded && sc.OnConfigReload != nil {
+		sc.OnConfigReload(cfg.ClientConfig)
+	}
+	return c, tcpc, cfg.ClientConfig, nil
+}
+
+// CurrentResolvers returns the addresses of the resolvers currently being used,
+// either ResolverAddrs or the ones from the environment or /etc/resolv.conf.
+// StubZones aren't included.
+func (sc *SRVClient) CurrentResolvers() ([]string, error) {
+	_, _, cfg, err := sc.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), cfg.Servers...), nil
+}
+
+func (sc *SRVClient) exchange(ctx context.Context, c Exchanger, m *dns.Msg, server string) (*dns.Msg, error) {
+	// wait on the rate limit first so we don't hold a concurrency slot while
+	// we're waiting
+	if err := sc.rateLimitWait(ctx, server); err != nil {
+		return nil, err
+	}
+
+	sc.exchangeSemO.Do(func() {
+		if sc.MaxConcurrentExchanges > 0 {
+			sc.exchangeSem = make(chan struct{}, sc.MaxConcurrentExchanges)
+		}
+	})
+	if sc.exchangeSem != nil {
+		select {
+		case sc.exchangeSem <- struct{}{}:
+			defer func() { <-sc.exchangeSem }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 
-	return sc.client, sc.tcpClient, sc.lastConfig.ClientConfig, nil
+	start := time.Now()
+	if sc.Dnstap != nil {
+		sc.Dnstap.log(dnstapStubQuery, exchangerNet(c), server, m, start, time.Time{})
+	}
+	res, rtt, err := c.ExchangeContext(ctx, m, server)
+	if rtt == 0 {
+		rtt = time.Since(start)
+	}
+	if sc.Dnstap != nil && res != nil {
+		sc.Dnstap.log(dnstapStubResponse, exchangerNet(c), server, res, start, start.Add(rtt))
+	}
+	if q := queryLogFromContext(ctx); q != nil && res != nil {
+		q.set(server, exchangerNet(c))
+	}
+	if t := transcriptFromContext(ctx); t != nil {
+		t.add(server, exchangerNet(c), m, res, rtt, err)
+	}
+	if sc.OnExchange != nil {
+		sc.OnExchange(ctx, m.Question[0].Name, server, rtt, res, err)
+	}
+	if sc.Events != nil {
+		sc.emit(Event{
+			Type:     EventResolverTried,
+			Question: m.Question[0].Name,
+			Qtype:    m.Question[0].Qtype,
+			Resolver: server,
+			Net:      exchangerNet(c),
+			RTT:      rtt,
+			Err:      err,
+		})
+	}
+	if fn := traceFromContext(ctx); fn != nil {
+		fn(Attempt{
+			Question: m.Question[0].Name,
+			Resolver: server,
+			Net:      exchangerNet(c),
+			RTT:      rtt,
+			Msg:      res,
+			Err:      err,
+		})
+	}
+	return res, err
 }
 
-func (sc *SRVClient) doExchange(ctx context.Context, c *dns.Client, fqdn, server string) (*dns.Msg, error) {
-	m := new(dns.Msg)
-	m.SetQuestion(fqdn, dns.TypeSRV)
-	var size uint16
-	if c.Net != "tcp" && c.UDPSize != 0 {
-		size = c.UDPSize
-		m.SetEdns0(c.UDPSize, false)
+func (sc *SRVClient) doExchange(ctx context.Context, c Exchanger, fqdn string, qtype uint16, server string) (*dns.Msg, error) {
+	return sc.doExchangeSize(ctx, c, fqdn, qtype, server, udpSize(c))
+}
+
+// doExchangeSize implements doExchange, advertising the given EDNS buffer size
+// rather than the Exchanger's
+func (sc *SRVClient) doExchangeSize(ctx context.Context, c Exchanger, fqdn string, qtype uint16, server string, size uint16) (*dns.Msg, error) {
+	m := getQuery(fqdn, qtype)
+	if poolable(c) {
+		defer putQuery(m)
+	}
+	if sc.EDNS == EDNSDisabled || (sc.EDNS == EDNSFallback && size != 0 && sc.ednsUnsupported(server)) {
+		size = 0
+	}
+	if size != 0 {
+		m.SetEdns0(size, false)
 	}
 
-	res, _, err := c.ExchangeContext(ctx, m, server)
+	res, err := sc.exchange(ctx, c, m, server)
+	if err == nil {
+		err = sc.validate(m, res, server)
+	}
 	if err != nil {
 		if sc.OnExchangeError != nil {
 			sc.OnExchangeError(ctx, fqdn, server, err)
 		}
-		return res, err
+		return nil, err
 	}
-	if res.Rcode != dns.RcodeFormatError || size == 0 {
+	if res.Rcode != dns.RcodeFormatError || size == 0 || sc.EDNS == EDNSNoFallback {
 		return res, nil
 	}
 
 	// At this point we got a response, but it was just to tell us that
 	// edns0 isn't supported, so we try again without it
-	m2 := new(dns.Msg)
-	m2.SetQuestion(fqdn, dns.TypeSRV)
-	res, _, err = c.ExchangeContext(ctx, m2, server)
+	m2 := getQuery(fqdn, qtype)
+	if poolable(c) {
+		defer putQuery(m2)
+	}
+	res, err = sc.exchange(ctx, c, m2, server)
+	if err == nil {
+		err = sc.validate(m2, res, server)
+	}
+	if err == nil && res.Rcode != dns.RcodeFormatError {
+		// the query is fine, it was the EDNS0 the resolver didn't like
+		sc.noEDNS.Store(server, time.Now())
+	}
 	if err != nil {
+		res = nil
 		if sc.OnExchangeError != nil {
 			sc.OnExchangeError(ctx, fqdn, server, err)
 		}
@@ -203,96 +718,360 @@ func (sc *SRVClient) doExchange(ctx context.Context, c *dns.Client, fqdn, server
 	return res, err
 }
 
-func (sc *SRVClient) innerLookupSRV(ctx context.Context, fqdn string, c, tcpc *dns.Client, cfg dns.ClientConfig, skipCache bool) (*dns.Msg, error) {
-	var res *dns.Msg
-	var tres *dns.Msg
-	var err error
-	for _, server := range cfg.Servers {
+// attemptContext returns the context to use for a single attempt at querying a
+// resolver, which is canceled after the configured timeout or when ctx is,
+// whichever is sooner. Every Exchanger then respects the caller's deadline,
+// rather than only its own timeouts. The timeout is jittered by Jitter.
+func (sc *SRVClient) attemptContext(ctx context.Context, cfg dns.ClientConfig) (context.Context, context.CancelFunc) {
+	if cfg.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	return context.WithTimeout(ctx, sc.jitter(timeout))
+}
+
+// lookupContext returns the context to use for a lookup, which is limited to
+// DefaultTimeout if ctx doesn't already have a deadline
+func (sc *SRVClient) lookupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || sc.DefaultTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, sc.DefaultTimeout)
+}
+
+// exchangeServers queries the resolvers for fqdn, as determined by the
+// ResolverStrategy, until one responds. hostname is only used for errors.
+func (sc *SRVClient) exchangeServers(ctx context.Context, hostname, fqdn string, qtype uint16, c, tcpc Exchanger, cfg dns.ClientConfig, standby []string) rawLookup {
+	udp := sc.isUDP()
+	policy := sc.tcpPolicy(fqdn, c)
+	if policy == TCPOnly {
+		c, udp = tcpc, false
+	}
+	exchange := func(ctx context.Context, server string) (rawLookup, bool) {
+		return sc.exchangeServer(ctx, hostname, fqdn, qtype, c, tcpc, udp, policy, server, cfg)
+	}
+
+	r, ok := sc.exchangeList(ctx, cfg.Servers, exchange)
+	if !ok && len(standby) > 0 && ctx.Err() == nil {
+		r = sc.exchangeStandby(ctx, r, standby, exchange)
+	}
+	return r
+}
+
+// exchangeList queries the given resolvers using the ResolverStrategy, and
+// returns the outcome along with whether any of them were successful
+func (sc *SRVClient) exchangeList(ctx context.Context, servers []string, exchange func(context.Context, string) (rawLookup, bool)) (rawLookup, bool) {
+	if len(servers) > 1 && sc.ResolverStrategy != StrategySequential {
+		return sc.exchangeConcurrently(ctx, servers, exchange)
+	}
+
+	var r rawLookup
+	for _, server := range servers {
+		sr, ok := exchange(ctx, server)
+		r.merge(sr)
+		if ok {
+			return r, true
+		}
+	}
+	return r, false
+}
+
+// exchangeServer queries a single resolver for fqdn, falling back to TCP or
+// racing it as needed, and returns the outcome along with whether it was
+// successful, i.e. whether no other resolvers need to be queried
+func (sc *SRVClient) exchangeServer(ctx context.Context, hostname, fqdn string, qtype uint16, c, tcpc Exchanger, udp bool, policy TCPPolicy, server string, cfg dns.ClientConfig) (rawLookup, bool) {
+	var r rawLookup
+	if policy == TCPRace {
+		res, tres, err := sc.raceExchange(ctx, c, tcpc, fqdn, qtype, server, cfg)
+		if tres != nil {
+			r.tres, r.tServer = tres, server
+		}
+		if res == nil {
+			r.err = wrapExchangeErr(hostname, server, err)
+			if tres != nil {
+				r.err = &ErrTruncated{Hostname: hostname, Resolver: server, Err: r.err}
+			}
+			return r, false
+		}
+		r.res, r.resServer = res, server
+		return r, !sc.rejected(res)
+	}
+
+	if udp {
 		atomic.AddInt64(&sc.numUDPQueries, 1)
-		res, err = sc.doExchange(ctx, c, fqdn, server)
-		if err != nil || res == nil {
-			atomic.AddInt64(&sc.numExchangeErrors, 1)
-			continue
+	} else {
+		atomic.AddInt64(&sc.numTCPQueries, 1)
+	}
+	actx, cancel := sc.attemptContext(ctx, cfg)
+	r.res, r.err = sc.doExchange(actx, c, fqdn, qtype, server)
+	cancel()
+	if r.err != nil || r.res == nil {
+		atomic.AddInt64(&sc.numExchangeErrors, 1)
+		r.err = wrapExchangeErr(hostname, server, r.err)
+		return r, false
+	}
+	r.resServer = server
+	if sc.rejected(r.res) {
+		return r, false
+	}
+	if r.res.Truncated {
+		atomic.AddInt64(&sc.numTruncatedResponses, 1)
+		sc.emit(Event{Type: EventTruncated, Question: fqdn, Qtype: qtype, Resolver: server})
+		// store truncated in case TCP fails
+		r.tres = r.res
+		r.tServer = server
+		truncation := sc.truncation()
+		switch truncation {
+		case TruncationUseTruncated:
+			return r, false
+		case TruncationFail:
+			r.res, r.tres = nil, nil
+			r.err = &ErrTruncated{Hostname: hostname, Resolver: server, Err: errTruncatedPolicy}
+			return r, false
 		}
-		if res.Truncated {
-			atomic.AddInt64(&sc.numTruncatedResponses, 1)
-			// store truncated in case TCP fails
-			tres = res
-			// try using TCP now
-			if !sc.IgnoreTruncated {
-				atomic.AddInt64(&sc.numTCPQueries, 1)
-				res, err = sc.doExchange(ctx, tcpc, fqdn, server)
-				if err != nil || res == nil {
-					atomic.AddInt64(&sc.numExchangeErrors, 1)
-					continue
-				}
-			} else {
-				continue
+		if udp {
+			if res := sc.retryTruncatedUDP(ctx, c, fqdn, qtype, server, cfg); res != nil {
+				r.res = res
+				return r, true
+			} else if truncation == TruncationRetryUDP {
+				return r, false
 			}
 		}
-		// no error so stop
-		break
+		// try using TCP now
+		atomic.AddInt64(&sc.numTCPQueries, 1)
+		sc.emit(Event{Type: EventTCPFallback, Question: fqdn, Qtype: qtype, Resolver: server})
+		actx, cancel := sc.attemptContext(ctx, cfg)
+		r.res, r.err = sc.doExchange(actx, tcpc, fqdn, qtype, server)
+		cancel()
+		if r.err != nil || r.res == nil {
+			atomic.AddInt64(&sc.numExchangeErrors, 1)
+			r.err = &ErrTruncated{
+				Hostname: hostname,
+				Resolver: server,
+				Err:      wrapExchangeErr(hostname, server, r.err),
+			}
+			return r, false
+		}
+	}
+	return r, true
+}
+
+// rejected returns whether the resolver refused to answer res, or doesn't
+// implement the query, in which case the next resolver is tried like it is for
+// exchange errors. The response is kept in case every resolver rejects it.
+func (sc *SRVClient) rejected(res *dns.Msg) bool {
+	if res.Rcode != dns.RcodeRefused && res.Rcode != dns.RcodeNotImplemented {
+		return false
 	}
+	atomic.AddInt64(&sc.numRejectedResponses, 1)
+	return true
+}
 
-	if sc.Preprocess != nil {
+// finishLookup preprocesses the responses from exchangeServers, caches them
+// under lastKey, and returns the one which should be used
+func (sc *SRVClient) finishLookup(lastKey string, qtype uint16, r rawLookup, skipCache bool) (*dns.Msg, string, error) {
+	res, resServer := r.res, r.resServer
+	if sc.Preprocess != nil && qtype == dns.TypeSRV {
 		// preprocess both since we don't know which one we'll use yet
 		if res != nil {
 			sc.Preprocess(res)
 		}
-		if tres != nil {
-			sc.Preprocess(tres)
+		if r.tres != nil {
+			sc.Preprocess(r.tres)
+		}
+	}
+
+	if qtype == dns.TypeSRV && sc.MinAnswers > 0 {
+		// responses with too few answers are treated as failures, so the last
+		// response is used in their place if there is one
+		if err := sc.checkMinAnswers(res, resServer); err != nil {
+			res, r.err = nil, err
+		}
+		if err := sc.checkMinAnswers(r.tres, r.tServer); err != nil {
+			r.tres = nil
+			if res == nil {
+				r.err = err
+			}
 		}
 	}
 
 	if !skipCache {
 		// Handles caching this response if it's a successful one, or replacing res
 		// with the last response if not. Does nothing if sc.cacheLast is false.
-		res = sc.doCacheLast(fqdn, res)
+		if cres := sc.doCacheLast(lastKey, res); cres != res {
+			res = cres
+			resServer = ""
+		}
 	}
 
 	// if we got a truncated error from a server but it was a success, use it
 	// we check this AFTER the cache in case we have a better one in the cache
-	if res != nil && res.Rcode != dns.RcodeSuccess && tres != nil && tres.Rcode == dns.RcodeSuccess {
-		res = tres
+	if (res == nil || res.Rcode != dns.RcodeSuccess) && r.tres != nil && r.tres.Rcode == dns.RcodeSuccess {
+		res = r.tres
+		resServer = r.tServer
 		if !skipCache {
 			// cache tres instead
-			res = sc.doCacheLast(fqdn, tres)
+			res = sc.doCacheLast(lastKey, r.tres)
 		}
 	}
 
-	return res, err
+	return res, resServer, r.err
 }
 
-func answersFromMsg(m *dns.Msg, replaceWithIPs bool) []*dns.SRV {
-	ans := make([]*dns.SRV, 0, len(m.Answer))
+// answersFromMsg appends the SRV records in m's answer section to dst and
+// returns the result. If dst is nil then a slice of the appropriate size is
+// allocated.
+func (sc *SRVClient) answersFromMsg(ctx context.Context, dst []*dns.SRV, m *dns.Msg, replaceWithIPs bool) []*dns.SRV {
+	ans := dst
+	if ans == nil {
+		ans = make([]*dns.SRV, 0, len(m.Answer))
+	}
+	start := len(ans)
 	for i := range m.Answer {
 		if ansSRV, ok := m.Answer[i].(*dns.SRV); ok {
-			if replaceWithIPs {
-				// attempt to replace SRV's Target with the actual IP
-				ansSRV = replaceSRVTarget(ansSRV, m.Extra)
+			if sc.ValidateRecord != nil && !sc.ValidateRecord(ansSRV) {
+				atomic.AddInt64(&sc.numDroppedRecords, 1)
+				continue
 			}
 			ans = append(ans, ansSRV)
 		}
 	}
+	if sc.DedupeAnswers {
+		ans = append(ans[:start], dedupeSRVs(ans[start:])...)
+	}
+	ans = append(ans[:start], sc.filterPriorities(ctx, ans[start:])...)
+	if replaceWithIPs {
+		for i := start; i < len(ans); i++ {
+			// attempt to replace SRV's Target with the actual IP
+			ans[i] = replaceSRVTarget(ans[i], sc.extra(m), sc.IPPreference)
+		}
+	}
 	return ans
 }
 
-func cacheKey(fqdn string, cfg dns.ClientConfig) string {
-	return fmt.Sprintf("%s:%v", fqdn, cfg.Servers)
+// normalizeHostname returns the fqdn which is queried for hostname, and which is
+// used as the key for caching and in-flight lookups. Hostnames which differ only
+// in case or by a trailing dot are normalized to the same fqdn, and Unicode
+// hostnames are converted to punycode.
+func normalizeHostname(hostname string) (string, error) {
+	fqdn, err := toASCII(hostname)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(dns.Fqdn(fqdn)), nil
+}
+
+// unicodeTargets converts the targets of the records to Unicode if
+// UnicodeTargets is set. The records are replaced, rather than modified, since
+// they may be shared with the cache.
+func (sc *SRVClient) unicodeTargets(ans []*dns.SRV) {
+	if !sc.UnicodeTargets {
+		return
+	}
+	for i := range ans {
+		ans[i] = unicodeSRV(ans[i])
+	}
 }
 
-func (sc *SRVClient) lookupSRV(ctx context.Context, hostname string, replaceWithIPs bool, skipCache bool) ([]*dns.SRV, error) {
+func cacheKey(fqdn string, qtype uint16, cfg dns.ClientConfig) string {
+	return fmt.Sprintf("%s:%d:%v", fqdn, qtype, cfg.Servers)
+}
+
+// cacheLastKey returns the key used for the cacheLast map. SRV responses are
+// keyed by just their fqdn.
+func cacheLastKey(fqdn string, qtype uint16) string {
+	if qtype == dns.TypeSRV {
+		return fqdn
+	}
+	return fqdn + ":" + dns.TypeToString[qtype]
+}
+
+// lookupMsg performs the query for hostname and returns the response along
+// with the address of the resolver which answered, if known. If the returned
+// msg is nil then the error will be non-nil.
+func (sc *SRVClient) lookupMsg(ctx context.Context, hostname string, qtype uint16, skipCache bool) (*dns.Msg, string, error) {
+	ctx, cancel := sc.lookupContext(ctx)
+	defer cancel()
+	ctx, logQuery := sc.startQueryLog(ctx, hostname, qtype)
+
+	var msg *dns.Msg
+	var server string
+	var err error
+	names := sc.searchNames(hostname)
+	for i, name := range names {
+		server = ""
+		if msg, err = sc.unprefixedOverride(name, qtype); msg == nil && err == nil {
+			if qtype == dns.TypeSRV {
+				name = sc.withServicePrefix(name)
+			}
+			msg, server, err = sc.lookupMsgInFlight(ctx, name, qtype, skipCache, sc.SingleInFlight)
+		}
+		if i == len(names)-1 || !isNotFoundMsg(msg, qtype) {
+			break
+		}
+	}
+	if src := answerSourceFromContext(ctx); src != nil && msg != nil {
+		src.set(server)
+	}
+	logQuery(msg, server, err)
+	return msg, server, err
+}
+
+// lookupMsgInFlight implements lookupMsg, only combining duplicate lookups if
+// singleInFlight is true
+func (sc *SRVClient) lookupMsgInFlight(ctx context.Context, hostname string, qtype uint16, skipCache, singleInFlight bool) (*dns.Msg, string, error) {
+	fqdn, err := normalizeHostname(hostname)
+	if err != nil {
+		return nil, "", err
+	}
+	if qtype == dns.TypeSRV {
+		// overrides are used even if the resolvers can't be loaded
+		if msg, err := sc.overrideMsg(fqdn); msg != nil || err != nil {
+			return msg, "", err
+		}
+	}
+	// follow-up lookups for targets don't go through lookupMsg
+	ctx, cancel := sc.lookupContext(ctx)
+	defer cancel()
+
+	sc.emit(Event{Type: EventQueryStarted, Question: fqdn, Qtype: qtype})
+
 	c, tcpc, cfg, err := sc.clientConfig()
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	skipCache = skipCache || noCacheFromContext(ctx)
+	lastKey := cacheLastKey(fqdn, qtype)
+	var standby []string
+	if servers := resolversFromContext(ctx); len(servers) > 0 {
+		cfg.Servers = servers
+		// responses from overridden resolvers mustn't be used in place of
+		// the normal ones, or vice versa
+		lastKey += fmt.Sprintf(":%v", servers)
+	} else if servers := sc.stubZoneServers(fqdn); servers != nil {
+		cfg.Servers = servers
+	} else if sc.MDNS && isMDNSName(fqdn) {
+		c, tcpc = mdnsClient{}, mdnsClient{}
+		cfg.Servers = []string{mdnsAddr}
+	} else {
+		standby = sc.standbyResolvers()
 	}
 
-	fqdn := dns.Fqdn(hostname)
+	var stale *dns.Msg
+	if sc.TTLCache && !skipCache {
+		var refresh bool
+		if stale, refresh = sc.ttlCacheGet(lastKey); stale != nil && !refresh {
+			return stale, "", nil
+		} else if refresh {
+			defer sc.ttlCacheDone(lastKey)
+		}
+	}
 
 	var msg *dns.Msg
-	if sc.SingleInFlight {
+	var server string
+	if singleInFlight {
 		var res *inFlightRes
-		key := cacheKey(fqdn, cfg)
+		key := cacheKey(fqdn, qtype, cfg)
 		resi, loaded := sc.inFlights.Load(key)
 		if loaded {
 			res = resi.(*inFlightRes)
@@ -311,7 +1090,7 @@ func (sc *SRVClient) lookupSRV(ctx context.Context, hostname string, replaceWith
 			do := func(ctx context.Context) {
 				defer close(res.done)
 				defer sc.inFlights.Delete(key)
-				res.msg, res.err = sc.innerLookupSRV(ctx, fqdn, c, tcpc, cfg, skipCache)
+				res.rawLookup = sc.exchangeServers(ctx, hostname, fqdn, qtype, c, tcpc, cfg, standby)
 			}
 			// check for an empty context and we don't need to make a goroutine since
 			// we can rely on the context not being cancelled
@@ -329,25 +1108,66 @@ func (sc *SRVClient) lookupSRV(ctx context.Context, hostname string, replaceWith
 		case <-ctx.Done():
 			err = ctx.Err()
 		case <-res.done:
-			if res.msg != nil {
-				msg = res.msg.Copy()
+			// the responses are otherwise never modified, so they only need to
+			// be copied if each caller is going to preprocess its own
+			raw := res.rawLookup
+			if sc.Preprocess != nil && qtype == dns.TypeSRV {
+				raw = raw.copy()
 			}
-			err = res.err
+			msg, server, err = sc.finishLookup(lastKey, qtype, raw, skipCache)
 		}
 	} else {
-		msg, err = sc.innerLookupSRV(ctx, fqdn, c, tcpc, cfg, skipCache)
+		raw := sc.exchangeServers(ctx, hostname, fqdn, qtype, c, tcpc, cfg, standby)
+		msg, server, err = sc.finishLookup(lastKey, qtype, raw, skipCache)
 	}
 
+	if sc.TTLCache && !skipCache && server != "" {
+		sc.ttlCacheSet(lastKey, msg)
+	}
+	if stale != nil && (msg == nil || len(msg.Answer) == 0) {
+		// the refresh failed, so the expiring response is used until it
+		// succeeds
+		return stale, "", nil
+	}
+	if msg == nil && err == nil {
+		err = &ErrNoNameservers{Hostname: hostname}
+	}
+	return msg, server, err
+}
+
+// noAnswersErr returns the error for a response which didn't contain any SRV
+// records
+func noAnswersErr(hostname, server string, msg *dns.Msg) error {
+	if msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError {
+		return &ErrRcode{Hostname: hostname, Resolver: server, Rcode: msg.Rcode}
+	}
+	return &ErrNotFound{hostname: hostname, resolver: server, rcode: msg.Rcode}
+}
+
+func (sc *SRVClient) lookupSRV(ctx context.Context, hostname string, replaceWithIPs bool, skipCache bool) ([]*dns.SRV, error) {
+	return sc.appendSRV(ctx, nil, hostname, replaceWithIPs, skipCache)
+}
+
+// appendSRV is like lookupSRV but appends the answers to dst, which can be a
+// pooled slice
+func (sc *SRVClient) appendSRV(ctx context.Context, dst []*dns.SRV, hostname string, replaceWithIPs bool, skipCache bool) ([]*dns.SRV, error) {
+	msg, server, err := sc.lookupMsg(ctx, hostname, dns.TypeSRV, skipCache)
 	if msg == nil {
-		if err == nil {
-			err = errors.New("no available nameservers")
-		}
 		return nil, err
 	}
 
-	ans := answersFromMsg(msg, replaceWithIPs)
+	ans := sc.answersFromMsg(ctx, dst, msg, replaceWithIPs)
 	if len(ans) == 0 {
-		return nil, &ErrNotFound{hostname}
+		return nil, noAnswersErr(hostname, server, msg)
+	}
+	if replaceWithIPs {
+		sc.resolveTargets(ctx, ans, skipCache)
+	}
+	sc.unicodeTargets(ans)
+	if sc.PostProcess != nil {
+		if ans = sc.PostProcess(ans); len(ans) == 0 {
+			return nil, noAnswersErr(hostname, server, msg)
+		}
 	}
 
 	return ans, err
@@ -361,27 +1181,44 @@ func srvToStr(srv *dns.SRV, port string) string {
 }
 
 func (sc *SRVClient) srv(ctx context.Context, hostname string, replaceWithIPs bool, skipCache bool) (string, error) {
-	var portStr string
-	if h, p, _ := net.SplitHostPort(hostname); p != "" && h != "" {
-		// check for host being an IP and if so, just return what they sent
-		if ip := net.ParseIP(h); ip != nil {
-			return hostname, nil
-		}
-		hostname = h
-		portStr = p
-	}
+	addr, _, err := sc.srvTTL(ctx, hostname, replaceWithIPs, skipCache)
+	return addr, err
+}
 
-	ans, err := sc.lookupSRV(ctx, hostname, replaceWithIPs, skipCache)
+// srvTTL implements srv, also returning the smallest TTL of the answers
+func (sc *SRVClient) srvTTL(ctx context.Context, hostname string, replaceWithIPs bool, skipCache bool) (string, uint32, error) {
+	host, portStr := splitHostPort(hostname)
+	// check for host being an IP and if so, just return what they sent
+	if portStr != "" && net.ParseIP(host) != nil {
+		return hostname, 0, nil
+	}
+	hostname = host
+
+	// the answers are only needed until one has been picked, unless PostProcess
+	// could have kept hold of them or returned a slice of its own
+	var pooled *[]*dns.SRV
+	var buf []*dns.SRV
+	if sc.PostProcess == nil {
+		pooled = getSRVs()
+		defer putSRVs(pooled)
+		buf = *pooled
+	}
+	ans, err := sc.appendSRV(ctx, buf, hostname, replaceWithIPs, skipCache)
+	if pooled != nil && ans != nil {
+		*pooled = ans
+	}
 	// only return an error here if we also didn't get an answer
 	if len(ans) == 0 && err != nil {
-		return "", err
+		return "", 0, err
 	}
 
-	// lookupSRV returns &ErrNotFound{hostname} if ans is empty so we MUST have at
+	// lookupSRV returns an ErrNotFound if ans is empty so we MUST have at
 	// least 1 record here
-	srv := pickSRV(ans)
+	srv := sc.pick(ans)
+	addr := srvToStr(srv, portStr)
+	sc.emit(Event{Type: EventAnswerChosen, Question: hostname, Answer: addr})
 
-	return srvToStr(srv, portStr), err
+	return addr, minTTL(ans), err
 }
 
 // SRV calls the SRV method on the DefaultSRVClient
@@ -407,7 +1244,9 @@ func (sc *SRVClient) SRV(hostname string) (string, error) {
 // target replaced with its respective IP.
 //
 // If the given hostname already has a ":port" appended to it, only the ip will
-// be looked up from the SRV request, but the port given will be returned
+// be looked up from the SRV request, but the port given will be returned. The
+// port can also be a service name, e.g. "https", which is returned as its
+// number.
 //
 // If the given hostname is "ip:port", it'll just immediately return what you
 // sent.
@@ -479,6 +1318,34 @@ type SRVStats struct {
 	CacheLastHits      int64
 	CacheLastMisses    int64
 	InFlightHits       int64
+
+	// MismatchedSources and MismatchedIDs count the UDP responses which were
+	// ignored because they came from the wrong address, or didn't match the
+	// query's ID or question, when HardenUDP is set
+	MismatchedSources int64
+	MismatchedIDs     int64
+
+	// StandbyLookups counts the lookups which failed over to the
+	// StandbyResolverAddrs, and StandbyErrors the ones which then failed
+	StandbyLookups int64
+	StandbyErrors  int64
+
+	// DroppedEvents counts the events which weren't sent to Events because
+	// it was full
+	DroppedEvents int64
+
+	// RejectedResponses counts the responses with a REFUSED or NOTIMP rcode,
+	// which cause the next resolver to be tried
+	RejectedResponses int64
+
+	// TTLCacheHits counts the lookups answered from the TTL cache, and
+	// TTLCacheStaleHits the ones given an expiring response while it was
+	// being refreshed
+	TTLCacheHits      int64
+	TTLCacheStaleHits int64
+
+	// DroppedRecords counts the SRV records which ValidateRecord rejected
+	DroppedRecords int64
 }
 
 // Stats returns the latest SRVStats struct for the given client
@@ -491,6 +1358,15 @@ func (sc *SRVClient) Stats() SRVStats {
 		CacheLastHits:      atomic.LoadInt64(&sc.numCacheLastHits),
 		CacheLastMisses:    atomic.LoadInt64(&sc.numCacheLastMisses),
 		InFlightHits:       atomic.LoadInt64(&sc.numInFlightHits),
+		MismatchedSources:  atomic.LoadInt64(&sc.numMismatchedSources),
+		MismatchedIDs:      atomic.LoadInt64(&sc.numMismatchedIDs),
+		StandbyLookups:     atomic.LoadInt64(&sc.numStandbyLookups),
+		StandbyErrors:      atomic.LoadInt64(&sc.numStandbyErrors),
+		DroppedEvents:      atomic.LoadInt64(&sc.numDroppedEvents),
+		RejectedResponses:  atomic.LoadInt64(&sc.numRejectedResponses),
+		TTLCacheHits:       atomic.LoadInt64(&sc.numTTLCacheHits),
+		TTLCacheStaleHits:  atomic.LoadInt64(&sc.numTTLCacheStaleHits),
+		DroppedRecords:     atomic.LoadInt64(&sc.numDroppedRecords),
 	}
 }
 
@@ -516,10 +1392,10 @@ func AllSRVTranslateContext(ctx context.Context, hostname string) ([]string, err
 }
 
 func (sc *SRVClient) allSRV(ctx context.Context, hostname string, translateIPs bool, skipCache bool) ([]string, error) {
-	var ogPort string
-	if parts := strings.Split(hostname, ":"); len(parts) == 2 {
-		hostname = parts[0]
-		ogPort = parts[1]
+	hostname, ogPort := splitHostPort(hostname)
+
+	if translateIPs && sc.IPPreference == PreferAll {
+		return sc.allSRVAllIPs(ctx, hostname, ogPort, skipCache)
 	}
 
 	ans, err := sc.lookupSRV(ctx, hostname, translateIPs, skipCache)
@@ -528,15 +1404,7 @@ func (sc *SRVClient) allSRV(ctx context.Context, hostname string, translateIPs b
 		return nil, err
 	}
 
-	// sort the lowest priority to the front and if priorities match
-	// sort the highest weights to the front
-	// use a stable sort in case the server's order is meaningful
-	sort.SliceStable(ans, func(i, j int) bool {
-		if ans[i].Priority == ans[j].Priority {
-			return ans[i].Weight > ans[j].Weight
-		}
-		return ans[i].Priority < ans[j].Priority
-	})
+	sc.orderSRVs(ans)
 
 	res := make([]string, len(ans))
 	for i := range ans {
@@ -545,15 +1413,27 @@ func (sc *SRVClient) allSRV(ctx context.Context, hostname string, translateIPs b
 	return res, err
 }
 
+// sortSRVs sorts the lowest priority to the front and if priorities match sorts
+// the highest weights to the front. It uses a stable sort in case the server's
+// order is meaningful.
+func sortSRVs(ans []*dns.SRV) {
+	sort.SliceStable(ans, func(i, j int) bool {
+		if ans[i].Priority == ans[j].Priority {
+			return ans[i].Weight > ans[j].Weight
+		}
+		return ans[i].Priority < ans[j].Priority
+	})
+}
+
 // AllSRV calls AllSRVContext with an empty context
 func (sc *SRVClient) AllSRV(hostname string) ([]string, error) {
 	return sc.AllSRVContext(context.Background(), hostname)
 }
 
 // AllSRVContext returns the list of all hostnames and ports for the SRV lookup
-// The results are sorted by priority and then weight. Like SRV, if hostname
-// contained a port then the port on all results will be replaced with the
-// originally-passed port
+// The results are sorted by priority and then weight, unless WeightedShuffle is
+// set. Like SRV, if hostname contained a port then the port on all results will
+// be replaced with the originally-passed port
 // AllSRVContext will NOT replace hostnames with their respective IPs
 func (sc *SRVClient) AllSRVContext(ctx context.Context, hostname string) ([]string, error) {
 	return sc.allSRV(ctx, hostname, false, false)
@@ -565,9 +1445,9 @@ func (sc *SRVClient) AllSRVTranslate(hostname string) ([]string, error) {
 }
 
 // AllSRVTranslateContext returns the list of all IPs and ports for the SRV lookup
-// The results are sorted by priority and then weight. Like SRV, if hostname
-// contained a port then the port on all results will be replaced with the
-// originally-passed port
+// The results are sorted by priority and then weight, unless WeightedShuffle is
+// set. Like SRV, if hostname contained a port then the port on all results will
+// be replaced with the originally-passed port
 func (sc *SRVClient) AllSRVTranslateContext(ctx context.Context, hostname string) ([]string, error) {
 	return sc.allSRV(ctx, hostname, true, false)
 }
@@ -591,14 +1471,64 @@ func (sc *SRVClient) MaybeSRV(host string) string {
 // if the SRV lookup succeeds it'll rewrite the host and return it with the
 // lookup result. If it fails it'll just return the host originally sent
 func (sc *SRVClient) MaybeSRVContext(ctx context.Context, host string) string {
-	if _, p, _ := net.SplitHostPort(host); p == "" {
-		if addr, err := sc.SRVContext(ctx, host); err == nil {
-			host = addr
-		}
-	}
+	host, _, _ = sc.MaybeSRVE(ctx, host)
 	return host
 }
 
+// MaybeSRVE calls the MaybeSRVE method on the DefaultSRVClient
+func MaybeSRVE(ctx context.Context, host string) (string, bool, error) {
+	return DefaultSRVClient.MaybeSRVE(ctx, host)
+}
+
+// MaybeSRVE behaves the same as MaybeSRVContext, but also returns whether the
+// host was rewritten with the SRV lookup result and, if it wasn't, the error
+// from the lookup. If no lookup was attempted because the host already
+// contained a port then the error is nil. An ErrNotFound error means the
+// lookup succeeded but there were no records.
+func (sc *SRVClient) MaybeSRVE(ctx context.Context, host string) (string, bool, error) {
+	if _, p := splitHostPort(host); p != "" {
+		return host, false, nil
+	}
+	addr, err := sc.SRVContext(ctx, host)
+	if err != nil {
+		return host, false, err
+	}
+	return addr, true, nil
+}
+
+// MaybeSRVDefaultPort calls the MaybeSRVDefaultPort method on the
+// DefaultSRVClient
+func MaybeSRVDefaultPort(host, defaultPort string) string {
+	return DefaultSRVClient.MaybeSRVDefaultPort(host, defaultPort)
+}
+
+// MaybeSRVDefaultPortContext calls the MaybeSRVDefaultPortContext method on the
+// DefaultSRVClient
+func MaybeSRVDefaultPortContext(ctx context.Context, host, defaultPort string) string {
+	return DefaultSRVClient.MaybeSRVDefaultPortContext(ctx, host, defaultPort)
+}
+
+// MaybeSRVDefaultPort calls MaybeSRVDefaultPortContext with an empty context
+func (sc *SRVClient) MaybeSRVDefaultPort(host, defaultPort string) string {
+	return sc.MaybeSRVDefaultPortContext(context.Background(), host, defaultPort)
+}
+
+// MaybeSRVDefaultPortContext behaves the same as MaybeSRVContext, except that if
+// the host is returned without being rewritten and it doesn't contain a port
+// then defaultPort is added to it, so that the result can always be passed to
+// net.Dial. The port can also be a service name, e.g. "https".
+func (sc *SRVClient) MaybeSRVDefaultPortContext(ctx context.Context, host, defaultPort string) string {
+	addr, ok, _ := sc.MaybeSRVE(ctx, host)
+	if ok {
+		return addr
+	}
+	h, p := splitHostPort(host)
+	if p != "" {
+		return host
+	}
+	return net.JoinHostPort(h, portNumber(defaultPort))
+}
+
 var (
 	randPool = sync.Pool{
 		New: func() interface{} {
@@ -609,6 +1539,11 @@ var (
 )
 
 func pickSRV(srvs []*dns.SRV) *dns.SRV {
+	return pickWeighted(srvs, randIntn)
+}
+
+// pickWeighted implements pickSRV using the given random source
+func pickWeighted(srvs []*dns.SRV, intn func(int) int) *dns.SRV {
 	lowPrio := srvs[0].Priority
 	picks := make([]*dns.SRV, 0, len(srvs))
 	weights := make([]int, 0, len(srvs))
@@ -633,9 +1568,7 @@ func pickSRV(srvs []*dns.SRV) *dns.SRV {
 	}
 
 	if sum > 0 {
-		rand := randPool.Get().(*rand.Rand)
-		defer randPool.Put(rand)
-		r := rand.Intn(sum)
+		r := intn(sum)
 		for i := range weights {
 			r -= weights[i]
 			if r < 0 {
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/levenlabs/go-srvclient"
+)
+
+type benchResult struct {
+	queries   int
+	errors    int
+	elapsed   time.Duration
+	latencies []time.Duration
+	stats     srvclient.SRVStats
+}
+
+// bench performs n lookups, spread across the hostnames, using c concurrent
+// workers
+func bench(ctx context.Context, sc *srvclient.SRVClient, o opts, hostnames []string, n, c int) benchResult {
+	if c < 1 {
+		c = 1
+	}
+	latencies := make([]time.Duration, n)
+	var next, errs int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < c; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := atomic.AddInt64(&next, 1) - 1
+				if i >= int64(n) {
+					return
+				}
+				qStart := time.Now()
+				_, err := resolveOnce(ctx, sc, o, hostnames[int(i)%len(hostnames)])
+				latencies[i] = time.Since(qStart)
+				if err != nil {
+					atomic.AddInt64(&errs, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return benchResult{
+		queries:   n,
+		errors:    int(errs),
+		elapsed:   time.Since(start),
+		latencies: latencies,
+		stats:     sc.Stats(),
+	}
+}
+
+func (br benchResult) percentile(p float64) time.Duration {
+	if len(br.latencies) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(br.latencies)-1))
+	return br.latencies[i]
+}
+
+func (br benchResult) print(w io.Writer) {
+	fmt.Fprintf(w, "queries:    %d in %s (%.1f qps)\n", br.queries, br.elapsed, float64(br.queries)/br.elapsed.Seconds())
+	fmt.Fprintf(w, "errors:     %d\n", br.errors)
+	fmt.Fprintf(w, "truncated:  %d\n", br.stats.TruncatedResponses)
+	fmt.Fprintf(w, "exchanges:  %d udp, %d tcp, %d errors\n", br.stats.UDPQueries, br.stats.TCPQueries, br.stats.ExchangeErrors)
+	fmt.Fprintf(w, "latency:    p50 %s, p90 %s, p99 %s, max %s\n",
+		br.percentile(0.5), br.percentile(0.9), br.percentile(0.99), br.percentile(1))
+}
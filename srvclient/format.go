@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/levenlabs/go-srvclient"
+	"github.com/miekg/dns"
+)
+
+// formatData is what's passed to the -format template for each record
+type formatData struct {
+	srvclient.Record
+	Hostname string
+	Resolver string
+}
+
+// formatRecords executes the template for each of the records in the result, or
+// for a single weighted random record if all is false
+func formatRecords(tpl *template.Template, res *srvclient.Result, all bool) ([]string, error) {
+	records := res.Records
+	if !all && len(records) > 0 {
+		srvs := make([]*dns.SRV, len(records))
+		for i, r := range records {
+			srvs[i] = &dns.SRV{Priority: r.Priority, Weight: r.Weight}
+		}
+		picked := srvclient.WeightedPicker.Pick(srvs)
+		for i := range srvs {
+			if srvs[i] == picked {
+				records = records[i : i+1]
+				break
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(records))
+	for _, r := range records {
+		var sb strings.Builder
+		err := tpl.Execute(&sb, formatData{
+			Record:   r,
+			Hostname: res.Hostname,
+			Resolver: res.Resolver,
+		})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines, nil
+}
+
+// longRecords returns the lines printed by -long: a header and then a line for
+// each record, with the columns aligned
+func longRecords(records []srvclient.Record) []string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PRIORITY\tWEIGHT\tTTL\tTARGET\tPORT\tIPS")
+	for _, r := range records {
+		ips := "-"
+		if len(r.IPs) > 0 {
+			strs := make([]string, len(r.IPs))
+			for i, ip := range r.IPs {
+				strs[i] = ip.String()
+			}
+			ips = strings.Join(strs, ",")
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%d\t%s\n", r.Priority, r.Weight, r.TTL, r.Target, r.Port, ips)
+	}
+	tw.Flush()
+	return strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+}
@@ -1,52 +1,458 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/levenlabs/go-srvclient"
+	"github.com/miekg/dns"
 )
 
+// The exit codes used by the binary
+const (
+	exitOK        = 0
+	exitUsage     = 1
+	exitNoRecords = 2
+	exitResolver  = 3
+	exitTimeout   = 4
+)
+
+// errNoAnswers is returned for non-SRV queries which had no answers
+var errNoAnswers = errors.New("no records")
+
+// exitCode returns the exit code which should be used for the given error
+func exitCode(err error) int {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, srvclient.ErrNoRecords), errors.Is(err, srvclient.ErrNXDomain), errors.Is(err, errNoAnswers):
+		return exitNoRecords
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return exitTimeout
+	default:
+		return exitResolver
+	}
+}
+
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: srvclient [options] <hostname>\n")
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: srvclient [options] <hostname> [hostname...]\n")
 		flag.PrintDefaults()
+		fmt.Fprintf(out, "\nExit codes: %d success, %d usage, %d no records, %d resolver failure, %d timeout\n",
+			exitOK, exitUsage, exitNoRecords, exitResolver, exitTimeout)
 	}
-	resolvers := flag.String("resolvers", "", "Comma separated list of resolver ips or addresses (ip:port) which should be used instead of /etc/resolv.conf")
+	resolvers := flag.String("resolvers", "", "Comma separated list of resolver ips or addresses (ip:port), with IPv6 ips optionally unbracketed, which should be used instead of /etc/resolv.conf. Defaults to the "+srvclient.ResolversEnv+" environment variable")
 	// this matches the flag for dig
 	ignore := flag.Bool("ignore", false, "Whether to ignore truncated responses")
-	flag.Parse()
+	jsonOut := flag.Bool("json", false, "Print every record, along with the resolver which answered, as JSON")
+	all := flag.Bool("all", false, "Print every record, sorted by priority and weight, instead of a single weighted random pick")
+	long := flag.Bool("long", false, "Print every record in aligned columns of priority, weight, TTL, target, port and resolved IPs, like dig +noall +answer")
+	watch := flag.Bool("watch", false, "Continuously re-resolve the hostname and print changes to its records as they happen")
+	interval := flag.Duration("interval", 5*time.Second, "How often to re-resolve the hostname when using -watch")
+	timeout := flag.Duration("timeout", 0, "Maximum time to spend on each attempt at resolving a hostname, 0 means no limit")
+	retries := flag.Int("retries", 0, "Number of times to retry resolving a hostname if it fails for a reason other than it having no records")
+	// -tcp matches +tcp for dig
+	useTCP := flag.Bool("tcp", false, "Send queries over TCP instead of UDP")
+	useTLS := flag.Bool("tls", false, "Send queries using DNS over TLS (resolver ips default to port 853)")
+	useHTTPS := flag.Bool("https", false, "Send queries using DNS over HTTPS (resolver ips default to port 443 and the /dns-query path)")
+	noTranslate := flag.Bool("no-translate", false, "Print the targets' names rather than translating them into their respective IPs")
+	noPort := flag.Bool("no-port", false, "Print only the host of each address, without the port")
+	format := flag.String("format", "", "Go template used to print each record, e.g. '{{.Target}} {{.Port}} {{.Weight}}'. Available fields are Hostname, Resolver, Target, Port, Priority, Weight, TTL and IPs")
+	qtypeStr := flag.String("type", "SRV", "The type of record to query for, e.g. A, AAAA, TXT or SVCB. Types other than SRV print every answer record and ignore the other output flags")
+	verbose := flag.Bool("verbose", false, "Print every exchange made with a resolver, including the transport, rcode and round-trip time, to stderr")
+	benchN := flag.Int("bench", 0, "Instead of printing results, perform this many lookups of the hostnames and report the throughput, latency percentiles and error counts")
+	concurrency := flag.Int("concurrency", 1, "Number of lookups to perform concurrently when using -bench")
+	quiet := flag.Bool("q", false, "Don't print anything, only set the exit code")
+	stdin := flag.Bool("stdin", false, "Read hostnames to resolve from stdin, one per line, in addition to any given as arguments")
+	// the default of exiting with 2 would be indistinguishable from
+	// exitNoRecords, and the error has already been printed
+	flag.CommandLine.Init("srvclient", flag.ContinueOnError)
+	if quietArg(os.Args[1:]) {
+		// parsing errors are printed before -q is known
+		flag.CommandLine.SetOutput(io.Discard)
+	}
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			exit(exitOK)
+		}
+		exit(exitUsage)
+	}
 	argv := flag.Args()
 
-	if len(argv) < 1 {
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if *quiet {
+		stdout, stderr = io.Discard, io.Discard
+	}
+	flag.CommandLine.SetOutput(stderr)
+
+	if *stdin {
+		hosts, err := readHostnames(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "error reading stdin: %s\n", err)
+			exit(exitUsage)
+		}
+		argv = append(argv, hosts...)
+	}
+
+	if len(argv) < 1 || (*watch && len(argv) > 1) {
 		flag.Usage()
-		exit(1)
+		exit(exitUsage)
 	}
 
 	sc := new(srvclient.SRVClient)
-	for _, r := range strings.Split(*resolvers, ",") {
-		if net.ParseIP(r) != nil {
-			r += ":53"
+	defaultPort := "53"
+	var transports int
+	if *useTCP {
+		sc.Net = srvclient.NetTCP
+		transports++
+	}
+	if *useTLS {
+		sc.Net = srvclient.NetTLS
+		defaultPort = "853"
+		transports++
+	}
+	if *useHTTPS {
+		sc.Net = srvclient.NetHTTPS
+		defaultPort = "443"
+		transports++
+	}
+	if transports > 1 {
+		fmt.Fprintf(stderr, "only one of -tcp, -tls and -https can be set\n")
+		exit(exitUsage)
+	}
+
+	if *resolvers == "" {
+		*resolvers = os.Getenv(srvclient.ResolversEnv)
+	}
+	sc.ResolverAddrs = srvclient.ParseResolverAddrs(*resolvers, defaultPort)
+
+	if *ignore {
+		sc.Truncation = srvclient.TruncationUseTruncated
+	}
+
+	o := opts{
+		json:        *jsonOut,
+		all:         *all,
+		long:        *long,
+		timeout:     *timeout,
+		retries:     *retries,
+		noTranslate: *noTranslate,
+		noPort:      *noPort,
+	}
+	qtype, ok := dns.StringToType[strings.ToUpper(*qtypeStr)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown -type %q\n", *qtypeStr)
+		exit(exitUsage)
+	}
+	o.qtype = qtype
+
+	if *format != "" {
+		tpl, err := template.New("format").Parse(*format)
+		if err != nil {
+			fmt.Fprintf(stderr, "error parsing -format: %s\n", err)
+			exit(exitUsage)
+		}
+		o.format = tpl
+	}
+	ctx := context.Background()
+	if *verbose {
+		ctx = srvclient.WithTrace(ctx, printAttempt(stderr))
+	}
+
+	if *benchN > 0 {
+		bench(ctx, sc, o, argv, *benchN, *concurrency).print(stdout)
+		return
+	}
+
+	if *watch {
+		// a single random pick would look like a change on nearly every lookup
+		o.all = true
+		watchLoop(ctx, sc, o, argv[0], *interval, stdout, stderr)
+		return
+	}
+
+	if len(argv) == 1 && !*stdin {
+		lines, err := resolve(ctx, sc, o, argv[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "error resolving %q: %s\n", argv[0], err)
+			os.Exit(exitCode(err))
 		}
-		if r != "" {
-			sc.ResolverAddrs = append(sc.ResolverAddrs, r)
+
+		for _, l := range lines {
+			fmt.Fprintln(stdout, l)
 		}
+		return
 	}
 
-	if *ignore {
-		sc.IgnoreTruncated = true
+	// when multiple hostnames fail use the highest exit code
+	code := exitOK
+	for _, r := range resolveAll(ctx, sc, o, argv) {
+		if r.err != nil {
+			fmt.Fprintf(stderr, "error resolving %q: %s\n", r.hostname, r.err)
+			if c := exitCode(r.err); c > code {
+				code = c
+			}
+			continue
+		}
+		for _, l := range r.lines {
+			fmt.Fprintln(stdout, r.hostname, l)
+		}
+	}
+	os.Exit(code)
+}
+
+var printAttemptL sync.Mutex
+
+// printAttempt returns a function which prints the details of an exchange to w
+// for -verbose
+func printAttempt(w io.Writer) func(srvclient.Attempt) {
+	return func(a srvclient.Attempt) {
+		printAttemptTo(w, a)
+	}
+}
+
+func printAttemptTo(w io.Writer, a srvclient.Attempt) {
+	var desc string
+	if a.Err != nil {
+		desc = "error: " + a.Err.Error()
+	} else {
+		desc = fmt.Sprintf("%s, %d answers", dns.RcodeToString[a.Msg.Rcode], len(a.Msg.Answer))
+		if a.Msg.Truncated {
+			desc += ", truncated"
+		}
+	}
+
+	printAttemptL.Lock()
+	defer printAttemptL.Unlock()
+	fmt.Fprintf(w, ";; %s over %s to %s in %s: %s\n", a.Question, a.Net, a.Resolver, a.RTT, desc)
+}
+
+// quietArg returns whether -q is among the flags in args, stopping where flag
+// parsing would
+func quietArg(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			return false
+		}
+		name, value, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "q" {
+			return value == "" || value == "true" || value == "1"
+		}
+	}
+	return false
+}
+
+// readHostnames reads one hostname per line, skipping empty lines
+func readHostnames(r io.Reader) ([]string, error) {
+	var hosts []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if h := strings.TrimSpace(s.Text()); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, s.Err()
+}
+
+// maxConcurrentResolves is the number of hostnames resolved at once by
+// resolveAll
+const maxConcurrentResolves = 16
+
+type resolveResult struct {
+	hostname string
+	lines    []string
+	err      error
+}
+
+// resolveAll resolves all of the hostnames concurrently and returns their
+// results in the same order as the hostnames
+func resolveAll(ctx context.Context, sc *srvclient.SRVClient, o opts, hostnames []string) []resolveResult {
+	res := make([]resolveResult, len(hostnames))
+	sem := make(chan struct{}, maxConcurrentResolves)
+	var wg sync.WaitGroup
+	for i, h := range hostnames {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, h string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			lines, err := resolve(ctx, sc, o, h)
+			res[i] = resolveResult{hostname: h, lines: lines, err: err}
+		}(i, h)
+	}
+	wg.Wait()
+	return res
+}
+
+type opts struct {
+	json    bool
+	all     bool
+	long    bool
+	timeout time.Duration
+	retries int
+	format  *template.Template
+
+	noTranslate bool
+	noPort      bool
+
+	qtype uint16
+}
+
+// resolve looks up the hostname and returns the lines which should be output,
+// retrying as configured in the opts
+func resolve(ctx context.Context, sc *srvclient.SRVClient, o opts, hostname string) ([]string, error) {
+	var lines []string
+	var err error
+	for i := 0; i <= o.retries; i++ {
+		lines, err = resolveOnce(ctx, sc, o, hostname)
+		if err == nil || exitCode(err) == exitNoRecords || ctx.Err() != nil {
+			break
+		}
+	}
+	return lines, err
+}
+
+func resolveOnce(ctx context.Context, sc *srvclient.SRVClient, o opts, hostname string) ([]string, error) {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	if o.qtype != dns.TypeSRV {
+		return query(ctx, sc, o.qtype, hostname)
+	}
+
+	switch {
+	case o.json:
+		res, err := sc.LookupSRVContext(ctx, hostname)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(res)
+		if err != nil {
+			return nil, err
+		}
+		return []string{string(b)}, nil
+	case o.format != nil:
+		res, err := sc.LookupSRVContext(ctx, hostname)
+		if err != nil {
+			return nil, err
+		}
+		return formatRecords(o.format, res, o.all)
+	case o.long:
+		records, err := sc.SRVAllIPs(ctx, hostname)
+		if err != nil {
+			return nil, err
+		}
+		return longRecords(records), nil
+	}
+
+	var lines []string
+	var err error
+	switch {
+	case o.all && o.noTranslate:
+		lines, err = sc.AllSRVContext(ctx, hostname)
+	case o.all:
+		lines, err = sc.AllSRVTranslateContext(ctx, hostname)
+	case o.noTranslate:
+		var r string
+		r, err = sc.SRVNoTranslateContext(ctx, hostname)
+		lines = []string{r}
+	case o.noPort:
+		var r string
+		r, err = sc.SRVNoPortContext(ctx, hostname)
+		return []string{r}, err
+	default:
+		var r string
+		r, err = sc.SRVContext(ctx, hostname)
+		lines = []string{r}
 	}
-	r, err := sc.SRV(argv[0])
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error resolving %q: %s\n", argv[0], err)
-		os.Exit(2)
+		return nil, err
+	}
+
+	if o.noPort {
+		for i := range lines {
+			if h, _, err := net.SplitHostPort(lines[i]); err == nil {
+				lines[i] = h
+			}
+		}
+	}
+	return lines, nil
+}
+
+// query performs a non-SRV query and returns each of the answer records
+func query(ctx context.Context, sc *srvclient.SRVClient, qtype uint16, hostname string) ([]string, error) {
+	m, err := sc.Query(ctx, hostname, qtype)
+	if m == nil {
+		return nil, err
+	}
+	if m.Rcode != dns.RcodeSuccess {
+		return nil, &srvclient.ErrRcode{Hostname: hostname, Rcode: m.Rcode}
+	}
+	lines := make([]string, 0, len(m.Answer))
+	for _, rr := range m.Answer {
+		lines = append(lines, rr.String())
 	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w of type %s", errNoAnswers, dns.TypeToString[qtype])
+	}
+	return lines, nil
+}
+
+// watchLoop resolves the hostname every interval and prints the lines which were
+// added (+) or removed (-) since the previous lookup, prefixed with the time
+func watchLoop(ctx context.Context, sc *srvclient.SRVClient, o opts, hostname string, interval time.Duration, stdout, stderr io.Writer) {
+	last := map[string]bool{}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		now := time.Now().Format(time.RFC3339)
+		lines, err := resolve(ctx, sc, o, hostname)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s error resolving %q: %s\n", now, hostname, err)
+		} else {
+			curr := make(map[string]bool, len(lines))
+			for _, l := range lines {
+				curr[l] = true
+				if !last[l] {
+					fmt.Fprintf(stdout, "%s + %s\n", now, l)
+				}
+			}
+			var removed []string
+			for l := range last {
+				if !curr[l] {
+					removed = append(removed, l)
+				}
+			}
+			sort.Strings(removed)
+			for _, l := range removed {
+				fmt.Fprintf(stdout, "%s - %s\n", now, l)
+			}
+			last = curr
+		}
 
-	fmt.Println(r)
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 func exit(i int) {
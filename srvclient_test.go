@@ -2,6 +2,7 @@ package srvclient
 
 import (
 	"context"
+	"errors"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -111,6 +112,49 @@ func init() {
 	DefaultSRVClient.ResolverAddrs = []string{addr, "8.8.8.8:53"}
 }
 
+// startTestServer starts a udp server using the given handler and returns its
+// address. The server is shutdown when the test finishes.
+func startTestServer(tb testing.TB, h dns.HandlerFunc) string {
+	started := make(chan struct{})
+	server := &dns.Server{
+		Addr:              "127.0.0.1:0",
+		Net:               "udp",
+		Handler:           h,
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go server.ListenAndServe()
+	<-started
+	tb.Cleanup(func() { server.Shutdown() })
+	return server.PacketConn.LocalAddr().String()
+}
+
+// memExchanger is an Exchanger which answers queries in memory using a
+// handler, without any sockets involved
+type memExchanger dns.HandlerFunc
+
+type memResponseWriter struct {
+	dns.ResponseWriter
+	res *dns.Msg
+}
+
+func (w *memResponseWriter) WriteMsg(m *dns.Msg) error {
+	w.res = m
+	return nil
+}
+
+// ExchangeContext implements the Exchanger interface
+func (e memExchanger) ExchangeContext(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	w := new(memResponseWriter)
+	e(w, m)
+	if w.res == nil {
+		return nil, 0, errors.New("no response written")
+	}
+	return w.res, time.Microsecond, nil
+}
+
 func testDistr(srvs []*dns.SRV) map[string]int {
 	m := map[string]int{}
 	for i := 0; i < 1000; i++ {
@@ -277,6 +321,40 @@ func TestMaybeSRV(t *testing.T) {
 	assert.True(t, r == "10.0.0.1:1000" || r == "[2607:5300:60:92e7::1]:1001")
 }
 
+func TestMaybeSRVDefaultPort(t *testing.T) {
+	r := MaybeSRVDefaultPort(testHostnameNoSRV, "80")
+	assert.Equal(t, testHostnameNoSRV+":80", r)
+
+	r = MaybeSRVDefaultPort("[::1]", "https")
+	assert.Equal(t, "[::1]:443", r)
+
+	hp := net.JoinHostPort(testHostnameNoSRV, "9999")
+	r = MaybeSRVDefaultPort(hp, "80")
+	assert.Equal(t, hp, r)
+
+	r = MaybeSRVDefaultPort(testHostname, "80")
+	assert.True(t, r == "10.0.0.1:1000" || r == "[2607:5300:60:92e7::1]:1001")
+}
+
+func TestMaybeSRVE(t *testing.T) {
+	ctx := context.Background()
+	r, ok, err := MaybeSRVE(ctx, testHostnameNoSRV)
+	assert.Equal(t, testHostnameNoSRV, r)
+	assert.False(t, ok)
+	assert.True(t, errors.Is(err, ErrNoRecords))
+
+	hp := testHostname + ":80"
+	r, ok, err = MaybeSRVE(ctx, hp)
+	assert.Equal(t, hp, r)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	r, ok, err = MaybeSRVE(ctx, testHostname)
+	assert.True(t, r == "10.0.0.1:1000" || r == "[2607:5300:60:92e7::1]:1001")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+}
+
 func TestLastCache(t *testing.T) {
 	cl := new(SRVClient)
 	cl.EnableCacheLast()
@@ -301,15 +379,16 @@ func TestLastCache(t *testing.T) {
 	// we don't cache not found errors
 	_, err = cl.SRV("fail")
 	assert.NotNil(t, err)
-	assert.IsType(t, &net.OpError{}, err)
+	assert.IsType(t, &ErrTimeout{}, err)
+	assert.ErrorAs(t, err, new(*net.OpError))
 
 	_, err = cl.SRVNoCacheContext(context.Background(), testHostname)
 	assert.NotNil(t, err)
-	assert.IsType(t, &net.OpError{}, err)
+	assert.IsType(t, &ErrTimeout{}, err)
 
 	_, err = cl.AllSRVNoCacheContext(context.Background(), testHostname)
 	assert.NotNil(t, err)
-	assert.IsType(t, &net.OpError{}, err)
+	assert.IsType(t, &ErrTimeout{}, err)
 }
 
 func TestMaybeSRVURL(t *testing.T) {
@@ -344,6 +423,56 @@ func TestPreprocess(t *testing.T) {
 	assert.Equal(t, str, "10.0.0.1")
 }
 
+func TestPostProcess(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	var got []string
+	client.PostProcess = func(srvs []*dns.SRV) []*dns.SRV {
+		got = got[:0]
+		var ipv4 []*dns.SRV
+		for _, srv := range srvs {
+			got = append(got, srv.Target)
+			if ip := net.ParseIP(srv.Target); ip != nil && ip.To4() != nil {
+				ipv4 = append(ipv4, srv)
+			}
+		}
+		return ipv4
+	}
+
+	// it's given the translated records
+	str, err := client.SRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:1000", str)
+	assert.Equal(t, []string{"10.0.0.1", "2607:5300:60:92e7::1"}, got)
+
+	r, err := client.AllSRVTranslate(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:1000"}, r)
+
+	// but not when translation wasn't asked for
+	_, err = client.AllSRV(testHostname)
+	assert.ErrorIs(t, err, ErrNoRecords)
+	assert.Equal(t, []string{"1.srv.test.", "2.srv.test."}, got)
+}
+
+func TestPostProcessRetained(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	fallback := []*dns.SRV{{Target: "10.0.0.9", Port: 9000}}
+	client.PostProcess = func([]*dns.SRV) []*dns.SRV {
+		return fallback
+	}
+
+	for i := 0; i < 10; i++ {
+		str, err := client.SRV(testHostname)
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.9:9000", str)
+	}
+	// the slice it returned isn't cleared or reused for other lookups
+	require.Len(t, fallback, 1)
+	assert.Equal(t, &dns.SRV{Target: "10.0.0.9", Port: 9000}, fallback[0])
+}
+
 func TestSingleInFlight(t *testing.T) {
 	var count int64
 
@@ -435,3 +564,215 @@ func TestSingleInFlight(t *testing.T) {
 	_, err := client.SRVNoCacheContext(context.Background(), "fail")
 	assert.NotNil(t, err)
 }
+
+func TestSingleInFlightSkipCache(t *testing.T) {
+	var fail int64
+	waitCh := make(chan struct{})
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if atomic.LoadInt64(&fail) == 0 {
+			handleRequest(w, r)
+			return
+		}
+		<-waitCh
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.SingleInFlight = true
+	client.EnableCacheLast()
+	client.ResolverAddrs = []string{addr}
+	_, err := client.AllSRV(testHostname)
+	require.NoError(t, err)
+
+	// a lookup using the cache and one skipping it share the same failed
+	// exchange, and only the first should get the cached response
+	atomic.StoreInt64(&fail, 1)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r, err := client.AllSRV(testHostname)
+		assert.NoError(t, err)
+		assert.Len(t, r, 2)
+	}()
+	go func() {
+		defer wg.Done()
+		// wait until the first lookup is in flight so this one joins it
+		key := cacheKey(dns.Fqdn(testHostname), dns.TypeSRV, dns.ClientConfig{Servers: client.ResolverAddrs})
+		for {
+			if _, ok := client.inFlights.Load(key); ok {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		_, err := client.AllSRVNoCacheContext(context.Background(), testHostname)
+		assert.True(t, errors.Is(err, ErrServFail))
+	}()
+	for client.Stats().InFlightHits == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(waitCh)
+	wg.Wait()
+}
+
+func TestSingleInFlightShared(t *testing.T) {
+	waitCh := make(chan struct{})
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		<-waitCh
+		handleRequest(w, r)
+	})
+
+	// queries two lookups at once and returns both responses
+	both := func(client *SRVClient) [2]*dns.Msg {
+		var msgs [2]*dns.Msg
+		var wg sync.WaitGroup
+		hits := client.Stats().InFlightHits
+		for i := range msgs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var err error
+				msgs[i], err = client.Query(context.Background(), testHostname, dns.TypeSRV)
+				assert.NoError(t, err)
+			}(i)
+		}
+		for client.Stats().InFlightHits == hits {
+			time.Sleep(time.Millisecond)
+		}
+		waitCh <- struct{}{}
+		wg.Wait()
+		return msgs
+	}
+
+	client := &SRVClient{SingleInFlight: true, ResolverAddrs: []string{addr}}
+	msgs := both(client)
+	assert.Same(t, msgs[0], msgs[1])
+
+	// with Preprocess each caller needs its own copy to modify
+	client = &SRVClient{SingleInFlight: true, ResolverAddrs: []string{addr}}
+	client.Preprocess = func(*dns.Msg) {}
+	msgs = both(client)
+	assert.NotSame(t, msgs[0], msgs[1])
+	assert.Equal(t, msgs[0].Answer, msgs[1].Answer)
+}
+
+func TestMaxConcurrentExchanges(t *testing.T) {
+	var curr, max int64
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		n := atomic.AddInt64(&curr, 1)
+		defer atomic.AddInt64(&curr, -1)
+		for {
+			m := atomic.LoadInt64(&max)
+			if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		handleRequest(w, r)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	client.MaxConcurrentExchanges = 2
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.SRV(testHostname)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, atomic.LoadInt64(&max), int64(2))
+
+	// a canceled context shouldn't wait for a slot
+	client.exchangeSem <- struct{}{}
+	client.exchangeSem <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.SRVContext(ctx, testHostname)
+	assert.Error(t, err)
+}
+
+func TestNormalizeHostname(t *testing.T) {
+	for in, out := range map[string]string{
+		"srv.test.test":        "srv.test.test.",
+		"SRV.Test.test.":       "srv.test.test.",
+		"_http._tcp.Bücher.de": "_http._tcp.xn--bcher-kva.de.",
+	} {
+		fqdn, err := normalizeHostname(in)
+		assert.NoError(t, err)
+		assert.Equal(t, out, fqdn)
+	}
+	_, err := normalizeHostname("bad\u200d.test")
+	assert.Error(t, err)
+	_, err = normalizeHostname("a\u0627b.test")
+	assert.Error(t, err)
+
+	client := SRVClient{}
+	client.EnableCacheLast()
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	lower, err := client.AllSRV(testHostname)
+	require.NoError(t, err)
+	upper, err := client.AllSRV("SRV.TEST.TEST.")
+	require.NoError(t, err)
+	assert.Equal(t, lower, upper)
+
+	client.cacheLastL.RLock()
+	defer client.cacheLastL.RUnlock()
+	assert.Len(t, client.cacheLast, 1)
+}
+
+func TestOnConfigReload(t *testing.T) {
+	var reloads []dns.ClientConfig
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	client.OnConfigReload = func(cfg dns.ClientConfig) {
+		reloads = append(reloads, cfg)
+	}
+
+	servers, err := client.CurrentResolvers()
+	require.NoError(t, err)
+	assert.Equal(t, client.ResolverAddrs, servers)
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+	assert.Empty(t, reloads)
+
+	// pretend the config was loaded before the latest change to resolv.conf
+	client.clientConfigL.Lock()
+	client.lastConfig.updated = time.Time{}
+	client.clientConfigL.Unlock()
+	_, err = client.SRV(testHostname)
+	require.NoError(t, err)
+	require.Len(t, reloads, 1)
+	assert.Equal(t, client.ResolverAddrs, reloads[0].Servers)
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	silent := func(dns.ResponseWriter, *dns.Msg) {}
+	addrs := []string{startTestServer(t, silent), startTestServer(t, silent)}
+
+	for _, singleInFlight := range []bool{false, true} {
+		client := SRVClient{
+			ResolverAddrs:  addrs,
+			DefaultTimeout: 100 * time.Millisecond,
+			SingleInFlight: singleInFlight,
+		}
+		start := time.Now()
+		_, err := client.SRV(testHostname)
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	}
+
+	// a deadline on the context takes precedence
+	client := SRVClient{ResolverAddrs: addrs, DefaultTimeout: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := client.SRVContext(ctx, testHostname)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+}
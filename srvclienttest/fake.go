@@ -0,0 +1,234 @@
+package srvclienttest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/levenlabs/go-srvclient"
+)
+
+// Fake is a srvclient.Resolver whose answers are set programmatically for each
+// hostname, and which never touches the network. Lookups of hostnames which
+// haven't been set return an error matching srvclient.ErrNoRecords.
+//
+// SRVContext returns the records with the lowest priority in turn, rather than
+// randomly, so tests are deterministic. The zero value is ready to use and all
+// methods are safe to call concurrently.
+type Fake struct {
+	l     sync.Mutex
+	hosts map[string]*fakeHost
+}
+
+type fakeHost struct {
+	records []srvclient.Record
+	err     error
+	latency time.Duration
+	next    int
+}
+
+var _ srvclient.Resolver = new(Fake)
+
+func fakeKey(hostname string) string {
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
+
+// host returns the fakeHost for hostname, creating it if needed. The lock must
+// be held.
+func (f *Fake) host(hostname string) *fakeHost {
+	if f.hosts == nil {
+		f.hosts = map[string]*fakeHost{}
+	}
+	key := fakeKey(hostname)
+	h, ok := f.hosts[key]
+	if !ok {
+		h = new(fakeHost)
+		f.hosts[key] = h
+	}
+	return h
+}
+
+// Set sets the records returned for hostname, replacing any previously set
+func (f *Fake) Set(hostname string, records ...srvclient.Record) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	h := f.host(hostname)
+	h.records = append([]srvclient.Record(nil), records...)
+	sort.SliceStable(h.records, func(i, j int) bool {
+		if h.records[i].Priority == h.records[j].Priority {
+			return h.records[i].Weight > h.records[j].Weight
+		}
+		return h.records[i].Priority < h.records[j].Priority
+	})
+	h.next = 0
+}
+
+// SetAddrs calls Set with a record for each of the given addresses ("host:port")
+// for hostname. If the host of an address is an IP then it's also used as the
+// Record's IPs. It panics if an address is invalid.
+func (f *Fake) SetAddrs(hostname string, addrs ...string) {
+	records := make([]srvclient.Record, len(addrs))
+	for i, addr := range addrs {
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			panic(fmt.Sprintf("srvclienttest: invalid address %q: %s", addr, err))
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			panic(fmt.Sprintf("srvclienttest: invalid port in %q: %s", addr, err))
+		}
+		records[i] = srvclient.Record{Target: host, Port: uint16(port), TTL: DefaultTTL}
+		if ip := net.ParseIP(host); ip != nil {
+			records[i].IPs = []net.IP{ip}
+		}
+	}
+	f.Set(hostname, records...)
+}
+
+// SetError causes lookups of hostname to return err, until it's called again
+// with a nil error
+func (f *Fake) SetError(hostname string, err error) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.host(hostname).err = err
+}
+
+// SetLatency causes lookups of hostname to block for d, or until their context
+// is canceled, before returning
+func (f *Fake) SetLatency(hostname string, d time.Duration) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.host(hostname).latency = d
+}
+
+// Remove removes everything set for hostname
+func (f *Fake) Remove(hostname string) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	delete(f.hosts, fakeKey(hostname))
+}
+
+// lookup returns the records for hostname, after waiting for its latency, and
+// the index of the record SRVContext should use
+func (f *Fake) lookup(ctx context.Context, hostname string) ([]srvclient.Record, int, error) {
+	f.l.Lock()
+	h, ok := f.hosts[fakeKey(hostname)]
+	var (
+		records []srvclient.Record
+		next    int
+		err     error
+		latency time.Duration
+	)
+	if ok {
+		records, err, latency = h.records, h.err, h.latency
+		next = h.next
+		// only rotate between the records with the lowest priority, like
+		// SRVClient would
+		var n int
+		for n < len(records) && records[n].Priority == records[0].Priority {
+			n++
+		}
+		if n > 0 {
+			next %= n
+			h.next = next + 1
+		}
+	}
+	f.l.Unlock()
+
+	if latency > 0 {
+		t := time.NewTimer(latency)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	} else if len(records) == 0 {
+		return nil, 0, fmt.Errorf("%w for %q", srvclient.ErrNoRecords, hostname)
+	}
+	return records, next, nil
+}
+
+// splitPort returns the hostname and port if hostname contains one, like
+// SRVClient does
+func splitPort(hostname string) (string, string) {
+	if h, p, _ := net.SplitHostPort(hostname); h != "" && p != "" {
+		return h, p
+	}
+	return hostname, ""
+}
+
+func recordAddr(r srvclient.Record, port string, translate bool) string {
+	if port == "" {
+		port = strconv.Itoa(int(r.Port))
+	}
+	host := r.Target
+	if translate && len(r.IPs) > 0 {
+		host = r.IPs[0].String()
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// SRVContext implements the srvclient.Resolver interface
+func (f *Fake) SRVContext(ctx context.Context, hostname string) (string, error) {
+	hostname, port := splitPort(hostname)
+	if port != "" && net.ParseIP(hostname) != nil {
+		return net.JoinHostPort(hostname, port), nil
+	}
+	records, i, err := f.lookup(ctx, hostname)
+	if err != nil {
+		return "", err
+	}
+	return recordAddr(records[i], port, true), nil
+}
+
+// AllSRVContext implements the srvclient.Resolver interface
+func (f *Fake) AllSRVContext(ctx context.Context, hostname string) ([]string, error) {
+	hostname, port := splitPort(hostname)
+	records, _, err := f.lookup(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(records))
+	for i := range records {
+		addrs[i] = recordAddr(records[i], port, false)
+	}
+	return addrs, nil
+}
+
+// LookupSRVContext implements the srvclient.Resolver interface
+func (f *Fake) LookupSRVContext(ctx context.Context, hostname string) (*srvclient.Result, error) {
+	records, _, err := f.lookup(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	res := &srvclient.Result{
+		Hostname: hostname,
+		Records:  make([]srvclient.Record, len(records)),
+	}
+	for i, r := range records {
+		r.IPs = append([]net.IP(nil), r.IPs...)
+		res.Records[i] = r
+	}
+	return res, nil
+}
+
+// MaybeSRVE implements the srvclient.Resolver interface
+func (f *Fake) MaybeSRVE(ctx context.Context, host string) (string, bool, error) {
+	if _, p, _ := net.SplitHostPort(host); p != "" {
+		return host, false, nil
+	}
+	addr, err := f.SRVContext(ctx, host)
+	if err != nil {
+		return host, false, err
+	}
+	return addr, true, nil
+}
@@ -0,0 +1,56 @@
+package srvclienttest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/levenlabs/go-srvclient"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	var f Fake
+	f.SetAddrs("_http._tcp.example.test", "10.0.0.1:8000", "10.0.0.2:8001")
+	f.Set("_db._tcp.example.test", srvclient.Record{Target: "db.example.test.", Port: 5432})
+
+	addr, err := f.SRVContext(ctx, "_http._tcp.example.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:8000", addr)
+	addr, err = f.SRVContext(ctx, "_http._tcp.example.test")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:8001", addr)
+	addr, err = f.SRVContext(ctx, "_http._tcp.example.test:80")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:80", addr)
+
+	addrs, err := f.AllSRVContext(ctx, "_db._tcp.example.test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db.example.test.:5432"}, addrs)
+
+	res, err := f.LookupSRVContext(ctx, "_db._tcp.example.test")
+	require.NoError(t, err)
+	assert.Equal(t, "_db._tcp.example.test", res.Hostname)
+	assert.Len(t, res.Records, 1)
+
+	host, ok, err := f.MaybeSRVE(ctx, "unknown.example.test")
+	assert.Equal(t, "unknown.example.test", host)
+	assert.False(t, ok)
+	assert.True(t, errors.Is(err, srvclient.ErrNoRecords))
+
+	injected := errors.New("injected")
+	f.SetError("_http._tcp.example.test", injected)
+	_, err = f.SRVContext(ctx, "_http._tcp.example.test")
+	assert.Equal(t, injected, err)
+	f.SetError("_http._tcp.example.test", nil)
+
+	f.SetLatency("_http._tcp.example.test", time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = f.SRVContext(ctx, "_http._tcp.example.test")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,35 @@
+// Package srvclienttest provides an in-memory DNS server, along with a
+// SRVClient pointed at it, for testing code which depends on SRV lookups
+package srvclienttest
+
+import (
+	"testing"
+
+	"github.com/levenlabs/go-srvclient"
+	"github.com/levenlabs/go-srvclient/dnsserver"
+)
+
+// DefaultTTL is the TTL given to records when the Server's TTL isn't set
+const DefaultTTL = dnsserver.DefaultTTL
+
+// Server is a DNS server which answers queries, over both udp and tcp, using
+// the records which have been added to it. See dnsserver.Server.
+type Server = dnsserver.Server
+
+// NewServer starts a Server listening on a random port on the loopback
+// interface. Close should be called once it's no longer needed.
+func NewServer() (*Server, error) {
+	return dnsserver.Listen("127.0.0.1:0")
+}
+
+// New starts a Server and returns it along with a SRVClient which uses it as
+// its only resolver. The Server is closed when the test finishes.
+func New(tb testing.TB) (*Server, *srvclient.SRVClient) {
+	tb.Helper()
+	s, err := NewServer()
+	if err != nil {
+		tb.Fatalf("starting srvclienttest server: %s", err)
+	}
+	tb.Cleanup(func() { s.Close() })
+	return s, s.Client()
+}
@@ -0,0 +1,50 @@
+package srvclienttest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/levenlabs/go-srvclient"
+)
+
+func TestServer(t *testing.T) {
+	s, sc := New(t)
+	s.AddSRV("_http._tcp.example.test", "a.example.test", 8000, 0, 0)
+	s.AddA("a.example.test", "10.0.0.1")
+	s.AddAAAA("a.example.test", "::1")
+
+	res, err := sc.LookupSRV("_http._tcp.example.test")
+	require.NoError(t, err)
+	assert.Equal(t, s.Addr, res.Resolver)
+	require.Len(t, res.Records, 1)
+	assert.Equal(t, "a.example.test.", res.Records[0].Target)
+	assert.EqualValues(t, 8000, res.Records[0].Port)
+	assert.EqualValues(t, DefaultTTL, res.Records[0].TTL)
+	assert.Len(t, res.Records[0].IPs, 2)
+
+	addr, err := sc.SRV("_http._tcp.example.test")
+	require.NoError(t, err)
+	assert.Contains(t, []string{"10.0.0.1:8000", "[::1]:8000"}, addr)
+
+	s.Remove("_http._tcp.example.test")
+	_, err = sc.SRV("_http._tcp.example.test")
+	assert.True(t, errors.Is(err, srvclient.ErrNXDomain))
+}
+
+func TestServerTruncated(t *testing.T) {
+	s, sc := New(t)
+	for i := 0; i < 200; i++ {
+		target := fmt.Sprintf("host-%d.example.test", i)
+		s.AddSRV("_http._tcp.example.test", target, 8000, 0, 0)
+		s.AddA(target, fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+
+	srvs, err := sc.AllSRV("_http._tcp.example.test")
+	require.NoError(t, err)
+	assert.Len(t, srvs, 200)
+	assert.NotZero(t, sc.Stats().TCPQueries)
+}
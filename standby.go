@@ -0,0 +1,29 @@
+package srvclient
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// standbyResolvers returns the StandbyResolverAddrs with the default port for
+// Net added to any bare ips
+func (sc *SRVClient) standbyResolvers() []string {
+	if len(sc.StandbyResolverAddrs) == 0 {
+		return nil
+	}
+	return resolverAddrs(sc.StandbyResolverAddrs, sc.defaultPort())
+}
+
+// exchangeStandby queries the standby resolvers after every normal one failed
+// with the outcome r. A truncated response from the normal resolvers is kept if
+// the standby resolvers don't have one, and their error is kept if the standby
+// resolvers couldn't be queried at all.
+func (sc *SRVClient) exchangeStandby(ctx context.Context, r rawLookup, standby []string, exchange func(context.Context, string) (rawLookup, bool)) rawLookup {
+	atomic.AddInt64(&sc.numStandbyLookups, 1)
+	sr, ok := sc.exchangeList(ctx, standby, exchange)
+	if !ok {
+		atomic.AddInt64(&sc.numStandbyErrors, 1)
+	}
+	r.merge(sr)
+	return r
+}
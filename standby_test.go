@@ -0,0 +1,57 @@
+package srvclient
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandbyResolvers(t *testing.T) {
+	var standbyQueries int64
+	standby := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt64(&standbyQueries, 1)
+		handleRequest(w, r)
+	})
+
+	// the standby resolvers aren't used while the normal ones work
+	sc := &SRVClient{
+		ResolverAddrs:        DefaultSRVClient.ResolverAddrs[:1],
+		StandbyResolverAddrs: []string{standby},
+	}
+	_, err := sc.SRV(testHostname)
+	require.NoError(t, err)
+	assert.Zero(t, atomic.LoadInt64(&standbyQueries))
+	assert.Zero(t, sc.Stats().StandbyLookups)
+
+	// but are once all of them fail
+	sc = &SRVClient{
+		ResolverAddrs:        []string{closedAddr(t), closedAddr(t)},
+		StandbyResolverAddrs: []string{standby},
+	}
+	addr, err := sc.SRV(testHostname)
+	require.NoError(t, err)
+	assert.Contains(t, []string{"10.0.0.1:1000", "[2607:5300:60:92e7::1]:1001"}, addr)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&standbyQueries))
+	stats := sc.Stats()
+	assert.Equal(t, int64(1), stats.StandbyLookups)
+	assert.Zero(t, stats.StandbyErrors)
+	assert.Equal(t, int64(2), stats.ExchangeErrors)
+
+	// the error is from the standby resolvers if they fail too
+	closed := closedAddr(t)
+	sc = &SRVClient{
+		ResolverAddrs:        []string{closedAddr(t)},
+		StandbyResolverAddrs: []string{closed},
+	}
+	_, err = sc.SRV(testHostname)
+	var eerr *ErrExchange
+	require.ErrorAs(t, err, &eerr)
+	assert.Equal(t, closed, eerr.Resolver)
+	stats = sc.Stats()
+	assert.Equal(t, int64(1), stats.StandbyLookups)
+	assert.Equal(t, int64(1), stats.StandbyErrors)
+	assert.Equal(t, []string{closed}, sc.Describe().StandbyResolvers)
+}
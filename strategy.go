@@ -0,0 +1,142 @@
+package srvclient
+
+import (
+	"context"
+	"time"
+)
+
+// ResolverStrategy determines how a lookup queries the resolvers when there's
+// more than one
+type ResolverStrategy int
+
+// The ResolverStrategy values which can be set in SRVClient.ResolverStrategy
+const (
+	// StrategySequential queries each resolver in turn, only moving on to the
+	// next once the previous one has failed or timed out. This puts the least
+	// load on the resolvers.
+	StrategySequential ResolverStrategy = iota
+
+	// StrategyParallel queries every resolver at once and uses the first
+	// successful response, canceling the other queries. This has the lowest
+	// latency, at the cost of multiplying the load on the resolvers.
+	StrategyParallel
+
+	// StrategyHedged queries the first resolver, and then the next one each
+	// time HedgeDelay passes without a successful response, or as soon as the
+	// previous one fails. The first successful response is used and the other
+	// queries are canceled. Only slow lookups cause extra load.
+	StrategyHedged
+)
+
+// String returns the name of the strategy
+func (s ResolverStrategy) String() string {
+	switch s {
+	case StrategySequential:
+		return "sequential"
+	case StrategyParallel:
+		return "parallel"
+	case StrategyHedged:
+		return "hedged"
+	}
+	return "unknown"
+}
+
+const defaultHedgeDelay = 100 * time.Millisecond
+
+func (sc *SRVClient) hedgeDelay() time.Duration {
+	if sc.HedgeDelay > 0 {
+		return sc.HedgeDelay
+	}
+	return defaultHedgeDelay
+}
+
+// exchangeConcurrently queries servers using exchange as determined by
+// StrategyParallel or StrategyHedged, returning the first successful outcome
+// along with true. If none were successful then their outcomes are merged in
+// the order of servers, as if they'd been queried sequentially.
+func (sc *SRVClient) exchangeConcurrently(ctx context.Context, servers []string, exchange func(context.Context, string) (rawLookup, bool)) (rawLookup, bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		i  int
+		r  rawLookup
+		ok bool
+	}
+	// buffered so that canceled queries don't block once we've returned
+	ch := make(chan result, len(servers))
+	start := func(i int) {
+		go func() {
+			r, ok := exchange(ctx, servers[i])
+			ch <- result{i: i, r: r, ok: ok}
+		}()
+	}
+
+	var next int
+	if sc.ResolverStrategy == StrategyParallel {
+		for ; next < len(servers); next++ {
+			start(next)
+		}
+	} else {
+		start(0)
+		next = 1
+	}
+
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	resetTimer := func() {
+		if next >= len(servers) {
+			timerC = nil
+			return
+		} else if timer == nil {
+			timer = time.NewTimer(sc.hedgeDelay())
+		} else {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(sc.hedgeDelay())
+		}
+		timerC = timer.C
+	}
+	resetTimer()
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	results := make([]*rawLookup, len(servers))
+	for pending := next; pending > 0; {
+		select {
+		case <-timerC:
+			start(next)
+			next++
+			pending++
+			resetTimer()
+		case res := <-ch:
+			pending--
+			if res.ok {
+				return res.r, true
+			}
+			results[res.i] = &res.r
+			if next < len(servers) {
+				// don't wait for the delay to move on from a failure
+				start(next)
+				next++
+				pending++
+				resetTimer()
+			}
+		}
+	}
+
+	var r rawLookup
+	for _, sr := range results {
+		if sr != nil {
+			r.merge(*sr)
+		}
+	}
+	return r, false
+}
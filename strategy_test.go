@@ -0,0 +1,84 @@
+package srvclient
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolverStrategy(t *testing.T) {
+	// counts the queries it gets, and responds to them after delay
+	server := func(delay time.Duration) (string, *int64) {
+		var n int64
+		addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+			atomic.AddInt64(&n, 1)
+			time.Sleep(delay)
+			handleRequest(w, r)
+		})
+		return addr, &n
+	}
+	silent := startTestServer(t, func(dns.ResponseWriter, *dns.Msg) {})
+	lookup := func(sc *SRVClient) ([]string, time.Duration, error) {
+		start := time.Now()
+		srvs, err := sc.AllSRVNoCacheContext(context.Background(), testHostname)
+		return srvs, time.Since(start), err
+	}
+
+	t.Run("parallel", func(t *testing.T) {
+		good, n := server(0)
+		sc := SRVClient{ResolverStrategy: StrategyParallel}
+		sc.ResolverAddrs = []string{silent, good}
+		srvs, took, err := lookup(&sc)
+		require.NoError(t, err)
+		assert.Len(t, srvs, 2)
+		assert.Less(t, took, time.Second)
+		assert.Equal(t, int64(1), atomic.LoadInt64(n))
+	})
+
+	t.Run("hedged", func(t *testing.T) {
+		slow, slowN := server(500 * time.Millisecond)
+		good, goodN := server(0)
+		sc := SRVClient{ResolverStrategy: StrategyHedged, HedgeDelay: 50 * time.Millisecond}
+		sc.ResolverAddrs = []string{slow, good}
+		srvs, took, err := lookup(&sc)
+		require.NoError(t, err)
+		assert.Len(t, srvs, 2)
+		assert.Less(t, took, 400*time.Millisecond)
+		assert.Equal(t, int64(1), atomic.LoadInt64(slowN))
+		assert.Equal(t, int64(1), atomic.LoadInt64(goodN))
+
+		// the next resolver isn't queried if the first responds in time
+		sc = SRVClient{ResolverStrategy: StrategyHedged, HedgeDelay: 50 * time.Millisecond}
+		sc.ResolverAddrs = []string{good, slow}
+		_, _, err = lookup(&sc)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), atomic.LoadInt64(goodN))
+		assert.Equal(t, int64(1), atomic.LoadInt64(slowN))
+
+		// failures move on to the next resolver without waiting
+		sc = SRVClient{ResolverStrategy: StrategyHedged, HedgeDelay: 10 * time.Second}
+		sc.ResolverAddrs = []string{closedAddr(t), good}
+		srvs, took, err = lookup(&sc)
+		require.NoError(t, err)
+		assert.Len(t, srvs, 2)
+		assert.Less(t, took, time.Second)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		for _, s := range []ResolverStrategy{StrategyParallel, StrategyHedged} {
+			sc := SRVClient{ResolverStrategy: s}
+			bad := closedAddr(t)
+			sc.ResolverAddrs = []string{closedAddr(t), bad}
+			_, _, err := lookup(&sc)
+			var eerr *ErrExchange
+			require.ErrorAs(t, err, &eerr, s.String())
+			// the error is from the last resolver, like StrategySequential
+			assert.Equal(t, bad, eerr.Resolver, s.String())
+		}
+	})
+}
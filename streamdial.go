@@ -0,0 +1,149 @@
+package srvclient
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// StreamDialer dials long-lived connections, e.g. websockets, raw TCP or gRPC
+// streams, to the targets of a hostname's SRV records. If a target can't be
+// connected to then the others are tried, and once connected the hostname
+// keeps being looked up so that the caller can be told when the target is
+// removed from the records and it should reconnect.
+//
+// It can be used as the dialer of most clients with a function such as:
+//
+//	func(ctx context.Context, addr string) (net.Conn, error) {
+//		return d.DialContext(ctx, "tcp", addr)
+//	}
+type StreamDialer struct {
+	// Client is used for the lookups. If nil then DefaultSRVClient is used.
+	Client *SRVClient
+
+	// Dial is used to connect to each target's address ("ip:port"), e.g. to
+	// establish TLS. If nil then a zero net.Dialer is used.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// OnTargetGone, if set, is called once when a successful lookup of the
+	// hostname no longer contains the target the connection was made to, with
+	// the target's address and the connection. The connection isn't closed,
+	// so the caller can decide whether to drain it or close it immediately. It
+	// isn't called once the connection has been closed.
+	OnTargetGone func(address string, conn net.Conn)
+}
+
+func (d *StreamDialer) client() *SRVClient {
+	if d.Client == nil {
+		return DefaultSRVClient
+	}
+	return d.Client
+}
+
+func (d *StreamDialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.Dial != nil {
+		return d.Dial(ctx, network, address)
+	}
+	var nd net.Dialer
+	return nd.DialContext(ctx, network, address)
+}
+
+// dialOrder returns the records in the order they should be dialed: the one
+// picked by the client's Picker first, then the rest by priority and weight
+func dialOrder(sc *SRVClient, ans []*dns.SRV) []*dns.SRV {
+	picked := sc.pick(ans)
+	ordered := make([]*dns.SRV, 0, len(ans))
+	ordered = append(ordered, picked)
+	for _, srv := range ans {
+		if srv != picked {
+			ordered = append(ordered, srv)
+		}
+	}
+	sortSRVs(ordered[1:])
+	return ordered
+}
+
+// DialContext looks up the given hostname and connects to one of its targets,
+// trying each of them in turn until a connection succeeds. If none do then the
+// errors from every attempt are returned. Like SRV, if the hostname contains a
+// port then that port is used for every target. If the hostname is an IP then
+// it's connected to directly.
+func (d *StreamDialer) DialContext(ctx context.Context, network, hostname string) (net.Conn, error) {
+	sc := d.client()
+	host, portStr := splitHostPort(hostname)
+	if portStr != "" && net.ParseIP(host) != nil {
+		return d.dial(ctx, network, hostname)
+	}
+
+	ans, err := sc.lookupSRV(ctx, host, true, false)
+	if len(ans) == 0 {
+		return nil, err
+	}
+
+	var errs []error
+	for _, srv := range dialOrder(sc, ans) {
+		addr := srvToStr(srv, portStr)
+		conn, err := d.dial(ctx, network, addr)
+		if err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if d.OnTargetGone == nil {
+			return conn, nil
+		}
+		return d.watch(sc, hostname, addr, conn), nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// streamConn is a connection returned by StreamDialer which is being watched
+type streamConn struct {
+	net.Conn
+	cancel context.CancelFunc
+}
+
+// Close implements the net.Conn interface, and stops the watching
+func (c *streamConn) Close() error {
+	c.cancel()
+	return c.Conn.Close()
+}
+
+// watch subscribes to the hostname until the connection is closed, calling
+// OnTargetGone once addr is no longer one of its targets
+func (d *StreamDialer) watch(sc *SRVClient, hostname, addr string, conn net.Conn) net.Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	sconn := &streamConn{Conn: conn, cancel: cancel}
+	go func() {
+		defer cancel()
+		first := true
+		for ev := range sc.Subscribe(ctx, hostname) {
+			if ev.Err != nil {
+				continue
+			}
+			gone := first && !containsString(ev.Added, addr)
+			gone = gone || containsString(ev.Removed, addr)
+			first = false
+			if gone {
+				if ctx.Err() == nil {
+					d.OnTargetGone(addr, sconn)
+				}
+				return
+			}
+		}
+	}()
+	return sconn
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
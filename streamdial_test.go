@@ -0,0 +1,80 @@
+package srvclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamDialer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	_, livePort, _ := net.SplitHostPort(l.Addr().String())
+	_, deadPort, _ := net.SplitHostPort(closedAddr(t))
+
+	var recordsL sync.Mutex
+	records := []string{
+		"stream.test. 1 IN SRV 0 0 " + deadPort + " s.test.",
+		"stream.test. 1 IN SRV 1 0 " + livePort + " s.test.",
+	}
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if q := r.Question[0]; q.Name == "stream.test." && q.Qtype == dns.TypeSRV {
+			recordsL.Lock()
+			for _, rr := range records {
+				m.Answer = append(m.Answer, newRR(rr))
+			}
+			recordsL.Unlock()
+			m.Extra = []dns.RR{newRR("s.test. 1 IN A 127.0.0.1")}
+		}
+		w.WriteMsg(m)
+	})
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+
+	gone := make(chan string, 1)
+	d := StreamDialer{
+		Client:       &client,
+		OnTargetGone: func(addr string, _ net.Conn) { gone <- addr },
+	}
+
+	// the dead target is tried first, and then the live one
+	conn, err := d.DialContext(context.Background(), "tcp", "stream.test")
+	require.NoError(t, err)
+	defer conn.Close()
+	liveAddr := "127.0.0.1:" + livePort
+	assert.Equal(t, liveAddr, conn.RemoteAddr().String())
+
+	recordsL.Lock()
+	records = records[:1]
+	recordsL.Unlock()
+	select {
+	case addr := <-gone:
+		assert.Equal(t, liveAddr, addr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnTargetGone wasn't called")
+	}
+
+	// if every target fails then each error is returned
+	_, err = d.DialContext(context.Background(), "tcp", "stream.test")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("127.0.0.1:%s", deadPort))
+}
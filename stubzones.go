@@ -0,0 +1,24 @@
+package srvclient
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// stubZoneServers returns the resolvers from StubZones for the normalized fqdn,
+// or nil if it's not in any of them
+func (sc *SRVClient) stubZoneServers(fqdn string) []string {
+	var servers []string
+	var longest int
+	for zone, zoneServers := range sc.StubZones {
+		zone = strings.ToLower(dns.Fqdn(strings.TrimPrefix(zone, "*.")))
+		if len(zone) <= longest {
+			continue
+		}
+		if fqdn == zone || strings.HasSuffix(fqdn, "."+zone) {
+			servers, longest = zoneServers, len(zone)
+		}
+	}
+	return servers
+}
@@ -0,0 +1,40 @@
+package srvclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubZoneServers(t *testing.T) {
+	client := SRVClient{
+		StubZones: map[string][]string{
+			"*.consul":         {"127.0.0.1:8600"},
+			"dc2.consul":       {"127.0.0.2:8600"},
+			"Example.Test.":    {"127.0.0.3:53"},
+			"notconsul.domain": {"127.0.0.4:53"},
+		},
+	}
+	assert.Equal(t, []string{"127.0.0.1:8600"}, client.stubZoneServers("web.service.consul."))
+	assert.Equal(t, []string{"127.0.0.1:8600"}, client.stubZoneServers("consul."))
+	assert.Equal(t, []string{"127.0.0.2:8600"}, client.stubZoneServers("web.service.dc2.consul."))
+	assert.Equal(t, []string{"127.0.0.3:53"}, client.stubZoneServers("a.example.test."))
+	assert.Nil(t, client.stubZoneServers("notconsul."))
+	assert.Nil(t, client.stubZoneServers("other.test."))
+}
+
+func TestStubZones(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = []string{closedAddr(t)}
+	client.StubZones = map[string][]string{
+		"test": DefaultSRVClient.ResolverAddrs[:1],
+	}
+	srvs, err := client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, srvs, 2)
+
+	// other hostnames still use the normal resolvers
+	_, err = client.AllSRV("srv.other")
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package srvclient
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// SubscribeEvent describes how the targets of a hostname changed since the
+// previous lookup performed by Subscribe
+type SubscribeEvent struct {
+	Hostname string
+
+	// Added and Removed contain the addresses ("host:port") which appeared or
+	// disappeared since the previous event. They are sorted.
+	Added   []string
+	Removed []string
+
+	// Err is set if the lookup failed, in which case Added and Removed are empty
+	// and the previous set of targets is still assumed
+	Err error
+}
+
+// Subscribe calls the Subscribe method on the DefaultSRVClient
+func Subscribe(ctx context.Context, hostname string) <-chan SubscribeEvent {
+	return DefaultSRVClient.Subscribe(ctx, hostname)
+}
+
+// Subscribe repeatedly looks up the given hostname, using the TTL of the answer
+// to decide when to look it up next, and sends an event on the returned channel
+// every time the set of targets changes or a lookup fails. The first event
+// contains every target as Added. Like SRV, if the hostname contains a port
+// then that port is used for every target.
+//
+// The channel is closed once the context is canceled.
+func (sc *SRVClient) Subscribe(ctx context.Context, hostname string) <-chan SubscribeEvent {
+	ch := make(chan SubscribeEvent)
+	go sc.subscribeLoop(ctx, hostname, ch)
+	return ch
+}
+
+func (sc *SRVClient) subscribeLoop(ctx context.Context, hostname string, ch chan<- SubscribeEvent) {
+	defer close(ch)
+
+	name, portStr := splitHostPort(hostname)
+
+	last := map[string]bool{}
+	for {
+		ans, err := sc.lookupSRV(ctx, name, true, false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		ev := SubscribeEvent{Hostname: hostname}
+		if len(ans) == 0 {
+			ev.Err = err
+		} else {
+			curr := make(map[string]bool, len(ans))
+			for _, srv := range ans {
+				addr := srvToStr(srv, portStr)
+				curr[addr] = true
+				if !last[addr] {
+					ev.Added = append(ev.Added, addr)
+				}
+			}
+			for addr := range last {
+				if !curr[addr] {
+					ev.Removed = append(ev.Removed, addr)
+				}
+			}
+			sort.Strings(ev.Added)
+			sort.Strings(ev.Removed)
+			last = curr
+		}
+
+		if ev.Err != nil || len(ev.Added) > 0 || len(ev.Removed) > 0 {
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		t := time.NewTimer(sc.jitter(refreshInterval(ans, 0, 0)))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
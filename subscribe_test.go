@@ -0,0 +1,50 @@
+package srvclient
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	var calls int64
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs
+	client.Preprocess = func(m *dns.Msg) {
+		// force the lookups to happen as often as possible
+		for _, rr := range m.Answer {
+			rr.Header().Ttl = 0
+		}
+		// drop the second record after the first lookup
+		if atomic.AddInt64(&calls, 1) > 1 && len(m.Answer) > 1 {
+			m.Answer = m.Answer[:1]
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := client.Subscribe(ctx, testHostname)
+
+	ev := <-ch
+	assert.Equal(t, testHostname, ev.Hostname)
+	assert.NoError(t, ev.Err)
+	assert.Equal(t, []string{"10.0.0.1:1000", "[2607:5300:60:92e7::1]:1001"}, ev.Added)
+	assert.Empty(t, ev.Removed)
+
+	select {
+	case ev = <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for second event")
+	}
+	assert.NoError(t, ev.Err)
+	assert.Empty(t, ev.Added)
+	assert.Equal(t, []string{"[2607:5300:60:92e7::1]:1001"}, ev.Removed)
+
+	cancel()
+	for range ch {
+	}
+}
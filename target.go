@@ -0,0 +1,108 @@
+package srvclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// Target is a target of a hostname's SRV records, along with the IPs it was
+// resolved to, so that it can be used without splitting "host:port" strings
+type Target struct {
+	Host     string
+	Port     uint16
+	Priority uint16
+	Weight   uint16
+
+	// IPs are ordered according to the IPPreference of the SRVClient which
+	// looked them up
+	IPs []net.IP
+}
+
+func recordTarget(r Record) Target {
+	return Target{
+		Host:     r.Target,
+		Port:     r.Port,
+		Priority: r.Priority,
+		Weight:   r.Weight,
+		IPs:      r.IPs,
+	}
+}
+
+// Addr returns the Host and Port as a "host:port" address
+func (t Target) Addr() string {
+	return net.JoinHostPort(t.Host, strconv.Itoa(int(t.Port)))
+}
+
+// Dial connects to the target over the given network, e.g. "tcp". Each of the
+// IPs is tried in turn until one succeeds, or if there aren't any then the
+// Host is dialed. If none succeed then the errors from every attempt are
+// returned.
+func (t Target) Dial(ctx context.Context, network string) (net.Conn, error) {
+	var d net.Dialer
+	if len(t.IPs) == 0 {
+		return d.DialContext(ctx, network, t.Addr())
+	}
+
+	port := strconv.Itoa(int(t.Port))
+	var errs []error
+	for _, ip := range t.IPs {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Targets calls the Targets method on the DefaultSRVClient
+func Targets(ctx context.Context, hostname string) ([]Target, error) {
+	return DefaultSRVClient.Targets(ctx, hostname)
+}
+
+// Targets behaves the same as SRVAllIPs, but returns the records as Targets
+func (sc *SRVClient) Targets(ctx context.Context, hostname string) ([]Target, error) {
+	records, err := sc.SRVAllIPs(ctx, hostname)
+	if records == nil {
+		return nil, err
+	}
+	targets := make([]Target, len(records))
+	for i, r := range records {
+		targets[i] = recordTarget(r)
+	}
+	return targets, err
+}
+
+// PickTarget calls the PickTarget method on the DefaultSRVClient
+func PickTarget(ctx context.Context, hostname string) (Target, error) {
+	return DefaultSRVClient.PickTarget(ctx, hostname)
+}
+
+// PickTarget performs a SRV request on the given hostname and returns one of
+// the targets, picked in the same way as SRV, with every A and AAAA address
+// found for it like SRVAllIPs. Like SRV, if hostname contains a port then it
+// replaces the port of the target.
+func (sc *SRVClient) PickTarget(ctx context.Context, hostname string) (Target, error) {
+	records, err := sc.SRVAllIPs(ctx, hostname)
+	if len(records) == 0 {
+		return Target{}, err
+	}
+	srvs := make([]*dns.SRV, len(records))
+	for i, r := range records {
+		srvs[i] = &dns.SRV{Priority: r.Priority, Weight: r.Weight, Port: r.Port, Target: r.Target}
+	}
+	picked := sc.pick(srvs)
+	for i, srv := range srvs {
+		if picked != nil && *srv == *picked {
+			return recordTarget(records[i]), err
+		}
+	}
+	return recordTarget(records[0]), err
+}
@@ -0,0 +1,69 @@
+package srvclient
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargets(t *testing.T) {
+	ctx := context.Background()
+	targets, err := Targets(ctx, testHostname)
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	assert.Equal(t, "1.srv.test.", targets[0].Host)
+	assert.EqualValues(t, 1000, targets[0].Port)
+	assert.Equal(t, "1.srv.test.:1000", targets[0].Addr())
+	require.Len(t, targets[0].IPs, 1)
+	assert.Equal(t, "10.0.0.1", targets[0].IPs[0].String())
+	assert.Equal(t, "2.srv.test.", targets[1].Host)
+
+	target, err := PickTarget(ctx, testHostname+":80")
+	require.NoError(t, err)
+	assert.Contains(t, []string{"1.srv.test.", "2.srv.test."}, target.Host)
+	assert.EqualValues(t, 80, target.Port)
+	assert.Len(t, target.IPs, 1)
+
+	_, err = PickTarget(ctx, "fail")
+	assert.ErrorIs(t, err, ErrNoRecords)
+}
+
+func TestTargetDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := uint16(l.Addr().(*net.TCPAddr).Port)
+	ctx := context.Background()
+
+	// the host is dialed when there aren't any IPs
+	conn, err := Target{Host: "127.0.0.1", Port: port}.Dial(ctx, "tcp")
+	require.NoError(t, err)
+	conn.Close()
+
+	// otherwise each IP is tried until one works, nothing listens on 127.0.0.2
+	target := Target{
+		Host: "unresolvable.invalid.",
+		Port: port,
+		IPs:  []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")},
+	}
+	conn, err = target.Dial(ctx, "tcp")
+	require.NoError(t, err)
+	assert.Equal(t, l.Addr().String(), conn.RemoteAddr().String())
+	conn.Close()
+
+	target.IPs = target.IPs[:1]
+	_, err = target.Dial(ctx, "tcp")
+	assert.Error(t, err)
+}
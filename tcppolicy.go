@@ -0,0 +1,102 @@
+package srvclient
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// TCPPolicy determines when TCP is used for queries of a hostname, when the
+// SRVClient is otherwise using UDP
+type TCPPolicy int
+
+// The TCPPolicy values which can be set in SRVClient.TCPHostnames
+const (
+	// TCPFallback queries over UDP, and over TCP if the response was
+	// truncated, unless Truncation is set to another TruncationPolicy
+	TCPFallback TCPPolicy = iota
+
+	// TCPRace queries over UDP and TCP at the same time, using whichever
+	// full response arrives first and canceling the other query. A truncated
+	// UDP response is only used if the TCP query fails.
+	TCPRace
+
+	// TCPOnly only queries over TCP
+	TCPOnly
+)
+
+// String returns the name of the policy
+func (p TCPPolicy) String() string {
+	switch p {
+	case TCPFallback:
+		return "fallback"
+	case TCPRace:
+		return "race"
+	case TCPOnly:
+		return "only"
+	}
+	return "unknown"
+}
+
+// tcpPolicy returns the TCPPolicy from TCPHostnames for the normalized fqdn,
+// which only applies if queries are normally made over UDP
+func (sc *SRVClient) tcpPolicy(fqdn string, c Exchanger) TCPPolicy {
+	if len(sc.TCPHostnames) == 0 || !sc.isUDP() || sc.Exchanger != nil {
+		return TCPFallback
+	} else if _, ok := c.(mdnsClient); ok {
+		return TCPFallback
+	}
+	for hostname, p := range sc.TCPHostnames {
+		if strings.ToLower(dns.Fqdn(hostname)) == fqdn {
+			return p
+		}
+	}
+	return TCPFallback
+}
+
+// raceExchange queries server over both c and tcpc at once, as described by
+// TCPRace. If the UDP response was truncated then it's returned as tres, even
+// if the TCP query failed.
+func (sc *SRVClient) raceExchange(ctx context.Context, c, tcpc Exchanger, fqdn string, qtype uint16, server string, cfg dns.ClientConfig) (res, tres *dns.Msg, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		res *dns.Msg
+		err error
+		tcp bool
+	}
+	ch := make(chan result, 2)
+	exchange := func(c Exchanger, tcp bool) {
+		actx, cancel := sc.attemptContext(ctx, cfg)
+		defer cancel()
+		res, err := sc.doExchange(actx, c, fqdn, qtype, server)
+		ch <- result{res: res, err: err, tcp: tcp}
+	}
+	atomic.AddInt64(&sc.numUDPQueries, 1)
+	atomic.AddInt64(&sc.numTCPQueries, 1)
+	go exchange(c, false)
+	go exchange(tcpc, true)
+
+	for i := 0; i < 2; i++ {
+		r := <-ch
+		if r.err != nil || r.res == nil {
+			atomic.AddInt64(&sc.numExchangeErrors, 1)
+			// the TCP error is more relevant, since it's the one which
+			// would have had the full response
+			if err == nil || r.tcp {
+				err = r.err
+			}
+			continue
+		} else if r.res.Truncated {
+			atomic.AddInt64(&sc.numTruncatedResponses, 1)
+			sc.emit(Event{Type: EventTruncated, Question: fqdn, Qtype: qtype, Resolver: server})
+			tres = r.res
+			continue
+		}
+		return r.res, tres, nil
+	}
+	return nil, tres, err
+}
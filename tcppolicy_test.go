@@ -0,0 +1,57 @@
+package srvclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPPolicy(t *testing.T) {
+	sc := &SRVClient{TCPHostnames: map[string]TCPPolicy{
+		"Trunc.Test.Test": TCPOnly,
+		"race.test.":      TCPRace,
+	}}
+	assert.Equal(t, TCPOnly, sc.tcpPolicy("trunc.test.test.", nil))
+	assert.Equal(t, TCPRace, sc.tcpPolicy("race.test.", nil))
+	assert.Equal(t, TCPFallback, sc.tcpPolicy("other.test.", nil))
+	assert.Equal(t, TCPFallback, sc.tcpPolicy("race.test.", mdnsClient{}))
+
+	sc.Net = NetTLS
+	assert.Equal(t, TCPFallback, sc.tcpPolicy("race.test.", nil))
+}
+
+func TestTCPOnly(t *testing.T) {
+	client := SRVClient{TCPHostnames: map[string]TCPPolicy{testHostnameTruncated: TCPOnly}}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	r, err := client.SRV(testHostnameTruncated)
+	require.NoError(t, err)
+	assert.True(t, r == "10.0.0.2:1000" || r == "[2607:5300:60:92e7::2]:1001")
+
+	stats := client.Stats()
+	assert.Equal(t, int64(0), stats.UDPQueries)
+	assert.Equal(t, int64(1), stats.TCPQueries)
+	assert.Equal(t, int64(0), stats.TruncatedResponses)
+}
+
+func TestTCPRace(t *testing.T) {
+	client := SRVClient{TCPHostnames: map[string]TCPPolicy{
+		testHostnameTruncated: TCPRace,
+		testHostname:          TCPRace,
+	}}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	r, err := client.SRV(testHostnameTruncated)
+	require.NoError(t, err)
+	assert.True(t, r == "10.0.0.2:1000" || r == "[2607:5300:60:92e7::2]:1001")
+
+	stats := client.Stats()
+	assert.Equal(t, int64(1), stats.UDPQueries)
+	assert.Equal(t, int64(1), stats.TCPQueries)
+
+	// either response is fine when neither is truncated
+	res, err := client.Query(context.Background(), testHostname, dns.TypeSRV)
+	require.NoError(t, err)
+	assert.Len(t, res.Answer, 2)
+}
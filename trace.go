@@ -0,0 +1,53 @@
+package srvclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Attempt describes a single exchange with a resolver made during a lookup
+type Attempt struct {
+	// Question is the name which was queried
+	Question string
+	Resolver string
+
+	// Net is the transport used for the exchange, e.g. NetUDP or NetTCP
+	Net string
+	RTT time.Duration
+
+	// Msg is the response, if there was one. It must not be modified.
+	Msg *dns.Msg
+	Err error
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context which will cause fn to be called after every
+// exchange made with a resolver by lookups using the context. Exchanges can be
+// made concurrently so fn must be safe to call from multiple goroutines.
+func WithTrace(ctx context.Context, fn func(Attempt)) context.Context {
+	return context.WithValue(ctx, traceKey{}, fn)
+}
+
+func traceFromContext(ctx context.Context) func(Attempt) {
+	fn, _ := ctx.Value(traceKey{}).(func(Attempt))
+	return fn
+}
+
+// exchangerNet returns the transport used by the given Exchanger
+func exchangerNet(c Exchanger) string {
+	switch c := c.(type) {
+	case *dns.Client:
+		if c.Net == "" {
+			return NetUDP
+		}
+		return c.Net
+	case *dohClient:
+		return NetHTTPS
+	case *udpMux, *hardenedUDP, mdnsClient:
+		return NetUDP
+	}
+	return ""
+}
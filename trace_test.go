@@ -0,0 +1,63 @@
+package srvclient
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTrace(t *testing.T) {
+	var l sync.Mutex
+	var attempts []Attempt
+	ctx := WithTrace(context.Background(), func(a Attempt) {
+		l.Lock()
+		defer l.Unlock()
+		attempts = append(attempts, a)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	_, err := client.SRVContext(ctx, testHostnameTruncated)
+	require.NoError(t, err)
+
+	require.Len(t, attempts, 2)
+	assert.Equal(t, dns.Fqdn(testHostnameTruncated), attempts[0].Question)
+	assert.Equal(t, client.ResolverAddrs[0], attempts[0].Resolver)
+	assert.Equal(t, NetUDP, attempts[0].Net)
+	assert.True(t, attempts[0].Msg.Truncated)
+	assert.NoError(t, attempts[0].Err)
+	assert.True(t, attempts[0].RTT > 0)
+
+	assert.Equal(t, NetTCP, attempts[1].Net)
+	assert.False(t, attempts[1].Msg.Truncated)
+	assert.Equal(t, dns.RcodeSuccess, attempts[1].Msg.Rcode)
+}
+
+func TestOnExchange(t *testing.T) {
+	var l sync.Mutex
+	var servers []string
+	client := SRVClient{}
+	client.ResolverAddrs = []string{closedAddr(t), DefaultSRVClient.ResolverAddrs[0]}
+	client.OnExchange = func(ctx context.Context, hostname, server string, rtt time.Duration, msg *dns.Msg, err error) {
+		l.Lock()
+		defer l.Unlock()
+		assert.Equal(t, dns.Fqdn(testHostname), hostname)
+		assert.True(t, rtt > 0)
+		if server == client.ResolverAddrs[0] {
+			assert.Error(t, err)
+			assert.Nil(t, msg)
+		} else {
+			assert.NoError(t, err)
+			assert.Len(t, msg.Answer, 2)
+		}
+		servers = append(servers, server)
+	}
+	_, err := client.SRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, client.ResolverAddrs, servers)
+}
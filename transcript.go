@@ -0,0 +1,193 @@
+package srvclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Exchange is a single query sent to a resolver, and its response, recorded in
+// a Transcript
+type Exchange struct {
+	Resolver string
+	Net      string
+	Query    *dns.Msg
+	Response *dns.Msg
+	RTT      time.Duration
+	Err      error
+}
+
+type exchangeJSON struct {
+	Resolver string        `json:"resolver"`
+	Net      string        `json:"net,omitempty"`
+	Question string        `json:"question,omitempty"`
+	Query    []byte        `json:"query"`
+	Response []byte        `json:"response,omitempty"`
+	RTT      time.Duration `json:"rtt"`
+	Err      string        `json:"error,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The query and response
+// are encoded in wire format so that they're reproduced exactly.
+func (e Exchange) MarshalJSON() ([]byte, error) {
+	ej := exchangeJSON{Resolver: e.Resolver, Net: e.Net, RTT: e.RTT}
+	var err error
+	if e.Query != nil {
+		if len(e.Query.Question) > 0 {
+			ej.Question = e.Query.Question[0].String()
+		}
+		if ej.Query, err = e.Query.Pack(); err != nil {
+			return nil, err
+		}
+	}
+	if e.Response != nil {
+		if ej.Response, err = e.Response.Pack(); err != nil {
+			return nil, err
+		}
+	}
+	if e.Err != nil {
+		ej.Err = e.Err.Error()
+	}
+	return json.Marshal(ej)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Err, if any, is only
+// restored as an error with the same message.
+func (e *Exchange) UnmarshalJSON(b []byte) error {
+	var ej exchangeJSON
+	if err := json.Unmarshal(b, &ej); err != nil {
+		return err
+	}
+	*e = Exchange{Resolver: ej.Resolver, Net: ej.Net, RTT: ej.RTT}
+	if len(ej.Query) > 0 {
+		e.Query = new(dns.Msg)
+		if err := e.Query.Unpack(ej.Query); err != nil {
+			return err
+		}
+	}
+	if len(ej.Response) > 0 {
+		e.Response = new(dns.Msg)
+		if err := e.Response.Unpack(ej.Response); err != nil {
+			return err
+		}
+	}
+	if ej.Err != "" {
+		e.Err = errors.New(ej.Err)
+	}
+	return nil
+}
+
+// Transcript records every exchange made during the lookups using a context
+// returned from WithTranscript. It can be encoded as JSON and later given to a
+// Replayer to reproduce the lookups exactly, e.g. in a test.
+type Transcript struct {
+	l         sync.Mutex
+	exchanges []Exchange
+}
+
+// Exchanges returns the exchanges recorded so far, in the order they were made.
+// The messages must not be modified.
+func (t *Transcript) Exchanges() []Exchange {
+	t.l.Lock()
+	defer t.l.Unlock()
+	return append([]Exchange(nil), t.exchanges...)
+}
+
+func (t *Transcript) add(server, network string, m, res *dns.Msg, rtt time.Duration, err error) {
+	e := Exchange{Resolver: server, Net: network, Query: m.Copy(), RTT: rtt, Err: err}
+	if res != nil {
+		e.Response = res.Copy()
+	}
+	t.l.Lock()
+	t.exchanges = append(t.exchanges, e)
+	t.l.Unlock()
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (t *Transcript) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Exchanges())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (t *Transcript) UnmarshalJSON(b []byte) error {
+	var exchanges []Exchange
+	if err := json.Unmarshal(b, &exchanges); err != nil {
+		return err
+	}
+	t.l.Lock()
+	t.exchanges = exchanges
+	t.l.Unlock()
+	return nil
+}
+
+type transcriptKey struct{}
+
+// WithTranscript returns a context which causes every exchange made by lookups
+// using it to be recorded in the returned Transcript
+func WithTranscript(ctx context.Context) (context.Context, *Transcript) {
+	t := new(Transcript)
+	return context.WithValue(ctx, transcriptKey{}, t), t
+}
+
+func transcriptFromContext(ctx context.Context) *Transcript {
+	t, _ := ctx.Value(transcriptKey{}).(*Transcript)
+	return t
+}
+
+// Replayer is an Exchanger which answers queries using the exchanges recorded
+// in a Transcript, rather than the network. It's meant to be set as a
+// SRVClient's Exchanger. Each recorded exchange is used once, in order, for
+// the first query with the same resolver and question.
+type Replayer struct {
+	l         sync.Mutex
+	exchanges []Exchange
+	used      []bool
+}
+
+// NewReplayer returns a Replayer for the exchanges in the given Transcript
+func NewReplayer(t *Transcript) *Replayer {
+	exchanges := t.Exchanges()
+	return &Replayer{exchanges: exchanges, used: make([]bool, len(exchanges))}
+}
+
+// Remaining returns the number of recorded exchanges which haven't been
+// replayed yet
+func (r *Replayer) Remaining() int {
+	r.l.Lock()
+	defer r.l.Unlock()
+	var n int
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// ExchangeContext implements the Exchanger interface
+func (r *Replayer) ExchangeContext(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+	for i, e := range r.exchanges {
+		if r.used[i] || e.Resolver != server || e.Query == nil ||
+			len(e.Query.Question) == 0 || e.Query.Question[0] != m.Question[0] {
+			continue
+		}
+		r.used[i] = true
+		var res *dns.Msg
+		if e.Response != nil {
+			res = e.Response.Copy()
+			res.Id = m.Id
+		}
+		return res, e.RTT, e.Err
+	}
+	return nil, 0, fmt.Errorf("no recorded exchange for %q on %s", m.Question[0].String(), server)
+}
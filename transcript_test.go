@@ -0,0 +1,42 @@
+package srvclient
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript(t *testing.T) {
+	client := SRVClient{}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	ctx, transcript := WithTranscript(context.Background())
+	expected, err := client.AllSRVTranslateContext(ctx, testHostnameTruncated)
+	require.NoError(t, err)
+
+	exchanges := transcript.Exchanges()
+	require.Len(t, exchanges, 2)
+	assert.Equal(t, NetUDP, exchanges[0].Net)
+	assert.True(t, exchanges[0].Response.Truncated)
+	assert.Equal(t, NetTCP, exchanges[1].Net)
+
+	b, err := json.Marshal(transcript)
+	require.NoError(t, err)
+	var decoded Transcript
+	require.NoError(t, json.Unmarshal(b, &decoded))
+
+	replayer := NewReplayer(&decoded)
+	replayClient := SRVClient{}
+	replayClient.ResolverAddrs = client.ResolverAddrs
+	replayClient.Exchanger = replayer
+	srvs, err := replayClient.AllSRVTranslate(testHostnameTruncated)
+	require.NoError(t, err)
+	assert.Equal(t, expected, srvs)
+	assert.Zero(t, replayer.Remaining())
+
+	// nothing is left to replay
+	_, err = replayClient.AllSRVTranslate(testHostnameTruncated)
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package srvclient
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// TruncationPolicy determines what happens when a resolver's UDP response is
+// truncated
+type TruncationPolicy int
+
+// The TruncationPolicy values which can be set in SRVClient.Truncation
+const (
+	// TruncationTCP retries the query over TCP, using the truncated response
+	// if that fails
+	TruncationTCP TruncationPolicy = iota
+
+	// TruncationUseTruncated uses the truncated response as it is, unless
+	// there's a better one, i.e. the next resolver is tried and the truncated
+	// response used if none of them return a full one
+	TruncationUseTruncated
+
+	// TruncationRetryUDP retries the query over UDP advertising a larger EDNS
+	// buffer, up to MaxUDPSize if it's set, for networks which drop DNS over
+	// TCP but allow large UDP responses. If the response is still truncated
+	// then it's handled like TruncationUseTruncated.
+	TruncationRetryUDP
+
+	// TruncationFail treats truncated responses as failures, with an
+	// ErrTruncated, and the next resolver is tried
+	TruncationFail
+)
+
+// String returns the name of the policy
+func (p TruncationPolicy) String() string {
+	switch p {
+	case TruncationTCP:
+		return "tcp"
+	case TruncationUseTruncated:
+		return "use"
+	case TruncationRetryUDP:
+		return "udp"
+	case TruncationFail:
+		return "fail"
+	}
+	return "unknown"
+}
+
+// retryUDPSize is the EDNS buffer size advertised by TruncationRetryUDP. The
+// response is already known not to fit in the normal buffer, so the largest
+// possible one is used.
+const retryUDPSize = dns.MaxMsgSize
+
+var errTruncatedPolicy = errors.New("truncated responses are treated as failures")
+
+// truncation returns the TruncationPolicy in effect, accounting for
+// IgnoreTruncated
+func (sc *SRVClient) truncation() TruncationPolicy {
+	if sc.Truncation == TruncationTCP && sc.IgnoreTruncated {
+		return TruncationUseTruncated
+	}
+	return sc.Truncation
+}
+
+// udpRetrySizes returns the EDNS buffer sizes which a query whose UDP response
+// advertising size was truncated is retried with, in order. TruncationRetryUDP
+// retries with the largest possible buffer, and otherwise, if MaxUDPSize is
+// set, the size is doubled each time up to MaxUDPSize.
+func (sc *SRVClient) udpRetrySizes(size uint16) []uint16 {
+	maxSize := sc.MaxUDPSize
+	if maxSize == 0 && sc.truncation() == TruncationRetryUDP {
+		if size < retryUDPSize {
+			return []uint16{retryUDPSize}
+		}
+		return nil
+	}
+
+	var sizes []uint16
+	for size < maxSize {
+		if size > maxSize/2 {
+			size = maxSize
+		} else {
+			size *= 2
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// retryTruncatedUDP retries a query whose UDP response was truncated over UDP
+// with larger EDNS buffers, as determined by udpRetrySizes. It returns nil if
+// every retry failed or was still truncated, or if there were none.
+func (sc *SRVClient) retryTruncatedUDP(ctx context.Context, c Exchanger, fqdn string, qtype uint16, server string, cfg dns.ClientConfig) *dns.Msg {
+	size := udpSize(c)
+	if size == 0 || sc.EDNS == EDNSDisabled {
+		return nil
+	}
+	for _, size := range sc.udpRetrySizes(size) {
+		atomic.AddInt64(&sc.numUDPQueries, 1)
+		actx, cancel := sc.attemptContext(ctx, cfg)
+		res, err := sc.doExchangeSize(actx, c, fqdn, qtype, server, size)
+		cancel()
+		if err != nil || res == nil {
+			atomic.AddInt64(&sc.numExchangeErrors, 1)
+			return nil
+		} else if !res.Truncated {
+			return res
+		}
+		atomic.AddInt64(&sc.numTruncatedResponses, 1)
+		sc.emit(Event{Type: EventTruncated, Question: fqdn, Qtype: qtype, Resolver: server})
+	}
+	return nil
+}
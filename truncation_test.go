@@ -0,0 +1,112 @@
+package srvclient
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncation(t *testing.T) {
+	// only responds in full to queries advertising a large enough buffer
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{newRR("srv.test. 60 IN SRV 0 0 1000 1.srv.test.")}
+		if opt := r.IsEdns0(); opt != nil && opt.UDPSize() >= retryUDPSize {
+			m.Answer = append(m.Answer, newRR("srv.test. 60 IN SRV 0 0 1001 2.srv.test."))
+		} else {
+			m.Truncated = true
+		}
+		w.WriteMsg(m)
+	})
+	newClient := func(p TruncationPolicy) *SRVClient {
+		sc := &SRVClient{Truncation: p, UDPSize: 1232}
+		sc.ResolverAddrs = []string{addr}
+		return sc
+	}
+
+	sc := newClient(TruncationRetryUDP)
+	addrs, err := sc.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, addrs, 2)
+	stats := sc.Stats()
+	assert.Equal(t, int64(2), stats.UDPQueries)
+	assert.Zero(t, stats.TCPQueries)
+	assert.Equal(t, int64(1), stats.TruncatedResponses)
+
+	// the retry isn't made if it wouldn't advertise a larger buffer
+	sc = newClient(TruncationRetryUDP)
+	sc.UDPSize = retryUDPSize
+	addrs, err = sc.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, addrs, 2)
+	assert.Equal(t, int64(1), sc.Stats().UDPQueries)
+
+	sc = newClient(TruncationUseTruncated)
+	addrs, err = sc.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.srv.test.:1000"}, addrs)
+	assert.Zero(t, sc.Stats().TCPQueries)
+
+	sc = newClient(TruncationFail)
+	_, err = sc.AllSRV(testHostname)
+	assert.True(t, errors.Is(err, &ErrTruncated{}), "%v", err)
+	assert.True(t, errors.Is(err, errTruncatedPolicy), "%v", err)
+	assert.Zero(t, sc.Stats().TCPQueries)
+
+	// IgnoreTruncated is the same as TruncationUseTruncated
+	sc = &SRVClient{IgnoreTruncated: true}
+	assert.Equal(t, TruncationUseTruncated, sc.truncation())
+	assert.Equal(t, "use", sc.Describe().Truncation)
+}
+
+func TestMaxUDPSize(t *testing.T) {
+	var l sync.Mutex
+	var sizes []uint16
+	getSizes := func() []uint16 {
+		l.Lock()
+		defer l.Unlock()
+		got := sizes
+		sizes = nil
+		return got
+	}
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{newRR("srv.test. 60 IN SRV 0 0 1000 1.srv.test.")}
+		opt := r.IsEdns0()
+		l.Lock()
+		sizes = append(sizes, opt.UDPSize())
+		l.Unlock()
+		if opt.UDPSize() >= 3000 {
+			m.Answer = append(m.Answer, newRR("srv.test. 60 IN SRV 0 0 1001 2.srv.test."))
+		} else {
+			m.Truncated = true
+		}
+		w.WriteMsg(m)
+	})
+
+	sc := &SRVClient{UDPSize: 1232, MaxUDPSize: 4000}
+	sc.ResolverAddrs = []string{addr}
+	addrs, err := sc.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, addrs, 2)
+	assert.Equal(t, []uint16{1232, 2464, 4000}, getSizes())
+	assert.Zero(t, sc.Stats().TCPQueries)
+
+	// TCP is used once the cap is reached
+	sc = &SRVClient{UDPSize: 1232, MaxUDPSize: 2000}
+	sc.ResolverAddrs = []string{addr}
+	_, err = sc.AllSRV(testHostname)
+	assert.True(t, errors.Is(err, &ErrTruncated{}), "%v", err)
+	assert.Equal(t, []uint16{1232, 2000}, getSizes())
+	assert.Equal(t, int64(1), sc.Stats().TCPQueries)
+
+	assert.Empty(t, (&SRVClient{MaxUDPSize: 1000}).udpRetrySizes(1232))
+	assert.Equal(t, []uint16{retryUDPSize}, (&SRVClient{Truncation: TruncationRetryUDP}).udpRetrySizes(1232))
+	assert.Equal(t, []uint16{2000, 4000, 5000}, (&SRVClient{MaxUDPSize: 5000}).udpRetrySizes(1000))
+}
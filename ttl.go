@@ -0,0 +1,30 @@
+package srvclient
+
+import (
+	"context"
+	"time"
+)
+
+// SRVWithTTL calls the SRVWithTTL method on the DefaultSRVClient
+func SRVWithTTL(hostname string) (string, time.Duration, error) {
+	return DefaultSRVClient.SRVWithTTL(hostname)
+}
+
+// SRVWithTTLContext calls the SRVWithTTLContext method on the DefaultSRVClient
+func SRVWithTTLContext(ctx context.Context, hostname string) (string, time.Duration, error) {
+	return DefaultSRVClient.SRVWithTTLContext(ctx, hostname)
+}
+
+// SRVWithTTL calls SRVWithTTLContext with an empty context
+func (sc *SRVClient) SRVWithTTL(hostname string) (string, time.Duration, error) {
+	return sc.SRVWithTTLContext(context.Background(), hostname)
+}
+
+// SRVWithTTLContext behaves the same as SRVContext, but also returns the
+// smallest TTL of the records, i.e. how long until the answer expires, so that
+// the address can be cached for as long as DNS says it's valid. The TTL is 0 if
+// the hostname was an "ip:port".
+func (sc *SRVClient) SRVWithTTLContext(ctx context.Context, hostname string) (string, time.Duration, error) {
+	addr, ttl, err := sc.srvTTL(ctx, hostname, true, false)
+	return addr, time.Duration(ttl) * time.Second, err
+}
@@ -0,0 +1,27 @@
+package srvclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSRVWithTTL(t *testing.T) {
+	addr, ttl, err := SRVWithTTL(testHostname)
+	require.NoError(t, err)
+	assert.True(t, addr == "10.0.0.1:1000" || addr == "[2607:5300:60:92e7::1]:1001")
+	assert.Equal(t, 60*time.Second, ttl)
+
+	addr, ttl, err = SRVWithTTL("10.0.0.1:80")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:80", addr)
+	assert.Zero(t, ttl)
+
+	start := time.Now()
+	res, err := LookupSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(60), res.TTL)
+	assert.WithinDuration(t, start.Add(60*time.Second), res.Expires, time.Second)
+}
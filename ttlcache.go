@@ -0,0 +1,144 @@
+package srvclient
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultStaleWhileRefresh is how long past their TTL responses in the TTL
+// cache keep being served while they're refreshed, when StaleWhileRefresh
+// isn't set
+const DefaultStaleWhileRefresh = 30 * time.Second
+
+// ttlCacheEntry tracks when a response in the TTL cache was stored and when it
+// expires, and whether a lookup is refreshing it
+type ttlCacheEntry struct {
+	stored     atomic.Int64 // unix nanoseconds
+	expires    atomic.Int64 // unix nanoseconds
+	refreshing atomic.Bool
+}
+
+// agedMsg returns a copy of msg with the TTLs of its answers reduced by how long
+// ago it was stored, like a caching resolver would, so that callers don't treat
+// it as valid for longer than it is. msg is returned as it is if it's unknown
+// when it was stored.
+func agedMsg(msg *dns.Msg, stored int64) *dns.Msg {
+	if stored == 0 {
+		return msg
+	}
+	age := uint32(time.Since(time.Unix(0, stored)) / time.Second)
+	if age == 0 {
+		return msg
+	}
+	aged := msg.Copy()
+	for _, rr := range aged.Answer {
+		if hdr := rr.Header(); hdr.Ttl > age {
+			hdr.Ttl -= age
+		} else {
+			hdr.Ttl = 0
+		}
+	}
+	return aged
+}
+
+// ttlCacheKey returns the key used for key in the TTL cache, which is kept
+// apart from the last responses kept by EnableCacheLast when they share Cache
+func ttlCacheKey(key string) string {
+	return "ttl:" + key
+}
+
+func (sc *SRVClient) staleWhileRefresh() time.Duration {
+	if sc.StaleWhileRefresh == 0 {
+		return DefaultStaleWhileRefresh
+	} else if sc.StaleWhileRefresh < 0 {
+		return 0
+	}
+	return sc.StaleWhileRefresh
+}
+
+// ttlCacheStore returns the Cache used for the TTL cache, either Cache or one
+// private to the SRVClient
+func (sc *SRVClient) ttlCacheStore() Cache {
+	if sc.Cache != nil {
+		return sc.Cache
+	}
+	return sc.privateTTLCache()
+}
+
+func (sc *SRVClient) privateTTLCache() *MemoryCache {
+	sc.ttlCacheO.Do(func() {
+		sc.ttlCache = NewMemoryCache()
+	})
+	return sc.ttlCache
+}
+
+// ttlCacheGet returns the response cached under key, if any, and whether the
+// caller should refresh it. It isn't to be refreshed if it's still fresh, or if
+// another lookup is already refreshing it, in which case the expiring response
+// is used in the meantime. If refresh is true then the caller must call
+// ttlCacheDone once it's finished.
+func (sc *SRVClient) ttlCacheGet(key string) (msg *dns.Msg, refresh bool) {
+	msg = sc.ttlCacheStore().Get(ttlCacheKey(key))
+	if msg == nil {
+		return nil, false
+	}
+	ei, _ := sc.ttlEntries.LoadOrStore(key, new(ttlCacheEntry))
+	e := ei.(*ttlCacheEntry)
+	// responses stored by another SRVClient sharing Cache have an unknown age,
+	// so they're refreshed unless they're only kept for their TTL
+	expires, stale := e.expires.Load(), sc.staleWhileRefresh()
+	msg = agedMsg(msg, e.stored.Load())
+	if (expires == 0 && stale == 0) || time.Now().UnixNano() < expires {
+		atomic.AddInt64(&sc.numTTLCacheHits, 1)
+		return msg, false
+	} else if stale == 0 {
+		return nil, false
+	} else if !e.refreshing.CompareAndSwap(false, true) {
+		atomic.AddInt64(&sc.numTTLCacheStaleHits, 1)
+		return msg, false
+	}
+	return msg, true
+}
+
+// ttlCacheDone marks the refresh of key claimed by ttlCacheGet as finished
+func (sc *SRVClient) ttlCacheDone(key string) {
+	if ei, ok := sc.ttlEntries.Load(key); ok {
+		ei.(*ttlCacheEntry).refreshing.Store(false)
+	}
+}
+
+// ttlCacheSet caches msg under key for the smallest TTL of its answers, plus
+// the time it can be served for while being refreshed. Responses without any
+// answers, or with a TTL of 0, aren't cached.
+func (sc *SRVClient) ttlCacheSet(key string, msg *dns.Msg) {
+	if msg == nil || msg.Rcode != dns.RcodeSuccess || len(msg.Answer) == 0 {
+		return
+	}
+	ttl := msg.Answer[0].Header().Ttl
+	for _, rr := range msg.Answer[1:] {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	if ttl == 0 {
+		return
+	}
+	d := time.Duration(ttl) * time.Second
+	sc.ttlCacheStore().Set(ttlCacheKey(key), msg, d+sc.staleWhileRefresh())
+	ei, _ := sc.ttlEntries.LoadOrStore(key, new(ttlCacheEntry))
+	e, now := ei.(*ttlCacheEntry), time.Now()
+	e.stored.Store(now.UnixNano())
+	e.expires.Store(now.Add(d).UnixNano())
+}
+
+// flushTTLCache discards the SRVClient's own TTL cache and the expiry times of
+// the responses in it
+func (sc *SRVClient) flushTTLCache() {
+	sc.privateTTLCache().Flush()
+	sc.ttlEntries.Range(func(key, _ interface{}) bool {
+		sc.ttlEntries.Delete(key)
+		return true
+	})
+}
@@ -0,0 +1,139 @@
+package srvclient
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expireTTLCache makes every response in sc's TTL cache expired, as if their
+// TTLs had run out
+func expireTTLCache(sc *SRVClient) {
+	sc.ttlEntries.Range(func(_, ei interface{}) bool {
+		ei.(*ttlCacheEntry).expires.Store(time.Now().Add(-time.Second).UnixNano())
+		return true
+	})
+}
+
+func TestTTLCache(t *testing.T) {
+	var queries int64
+	var servFail atomic.Bool
+	block := make(chan struct{})
+	var blocking atomic.Bool
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Qtype == dns.TypeSRV {
+			atomic.AddInt64(&queries, 1)
+		}
+		if blocking.Load() {
+			<-block
+		}
+		if servFail.Load() {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeServerFailure)
+			w.WriteMsg(m)
+			return
+		}
+		handleRequest(w, r)
+	})
+
+	sc := &SRVClient{ResolverAddrs: []string{addr}, TTLCache: true}
+	for i := 0; i < 3; i++ {
+		_, err := sc.SRV(testHostname)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&queries))
+	assert.Equal(t, int64(2), sc.Stats().TTLCacheHits)
+
+	// once expired only a single lookup refreshes it, and the others are
+	// given the expiring response in the meantime
+	expireTTLCache(sc)
+	blocking.Store(true)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := sc.SRV(testHostname)
+		assert.NoError(t, err)
+	}()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&queries) == 2
+	}, time.Second, time.Millisecond)
+	for i := 0; i < 5; i++ {
+		_, err := sc.SRV(testHostname)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(5), sc.Stats().TTLCacheStaleHits)
+	blocking.Store(false)
+	close(block)
+	wg.Wait()
+	assert.Equal(t, int64(2), atomic.LoadInt64(&queries))
+
+	// the refreshed response is fresh again
+	_, err := sc.SRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&queries))
+
+	// a failed refresh still gets the expiring response, and the next lookup
+	// tries again
+	servFail.Store(true)
+	expireTTLCache(sc)
+	for i := 0; i < 2; i++ {
+		_, err = sc.SRV(testHostname)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(4), atomic.LoadInt64(&queries))
+
+	// skipping the cache always queries
+	_, err = sc.SRVNoCacheContext(context.Background(), testHostname)
+	require.Error(t, err)
+	assert.Equal(t, int64(5), atomic.LoadInt64(&queries))
+
+	// expiring responses aren't used at all with a negative StaleWhileRefresh
+	servFail.Store(false)
+	sc = &SRVClient{ResolverAddrs: []string{addr}, TTLCache: true, StaleWhileRefresh: -1}
+	_, err = sc.SRV(testHostname)
+	require.NoError(t, err)
+	servFail.Store(true)
+	expireTTLCache(sc)
+	_, err = sc.SRV(testHostname)
+	assert.ErrorIs(t, err, ErrServFail)
+	assert.Equal(t, int64(7), atomic.LoadInt64(&queries))
+	assert.Zero(t, sc.Stats().TTLCacheStaleHits)
+	assert.Zero(t, sc.Stats().TTLCacheHits)
+}
+
+func TestTTLCacheAge(t *testing.T) {
+	sc := &SRVClient{ResolverAddrs: DefaultSRVClient.ResolverAddrs[:1], TTLCache: true}
+	_, ttl, err := sc.SRVWithTTL(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, 60*time.Second, ttl)
+
+	// pretend the response was cached 20 seconds ago
+	sc.ttlEntries.Range(func(_, ei interface{}) bool {
+		e := ei.(*ttlCacheEntry)
+		e.stored.Store(e.stored.Load() - int64(20*time.Second))
+		e.expires.Store(e.expires.Load() - int64(20*time.Second))
+		return true
+	})
+	_, ttl, err = sc.SRVWithTTL(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, 40*time.Second, ttl)
+	assert.Equal(t, int64(1), sc.Stats().TTLCacheHits)
+
+	res, err := sc.LookupSRV(testHostname)
+	require.NoError(t, err)
+	assert.EqualValues(t, 40, res.TTL)
+	assert.WithinDuration(t, time.Now().Add(40*time.Second), res.Expires, time.Second)
+	assert.EqualValues(t, 40, res.Records[0].TTL)
+
+	// the cached response itself isn't modified
+	msg := sc.ttlCacheStore().Get(ttlCacheKey(dns.Fqdn(testHostname)))
+	require.NotNil(t, msg)
+	assert.EqualValues(t, 60, msg.Answer[0].Header().Ttl)
+}
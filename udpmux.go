@@ -0,0 +1,236 @@
+package srvclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// udpIdleTimeout is how long a reused UDP socket stays open without any
+// queries outstanding on it
+const udpIdleTimeout = time.Minute
+
+var errUDPConnClosed = errors.New("udp socket closed")
+
+// udpMux is an Exchanger which keeps a single connected UDP socket open per
+// resolver and sends every query to that resolver over it, matching responses
+// to queries by their ID and question. Sockets are dialed on demand and closed
+// once they've been idle for udpIdleTimeout.
+type udpMux struct {
+	udpSize uint16
+
+	l     sync.Mutex
+	conns map[string]*udpMuxConn
+}
+
+func newUDPMux(udpSize uint16) *udpMux {
+	return &udpMux{udpSize: udpSize, conns: map[string]*udpMuxConn{}}
+}
+
+type udpMuxPending struct {
+	q  dns.Question
+	ch chan *dns.Msg
+}
+
+type udpMuxConn struct {
+	mux    *udpMux
+	server string
+	conn   net.Conn
+	done   chan struct{}
+
+	l       sync.Mutex
+	pending map[uint16]*udpMuxPending
+	closed  bool
+	err     error
+}
+
+// conn returns the open socket for server, dialing one if there isn't one
+func (mux *udpMux) conn(ctx context.Context, server string) (*udpMuxConn, error) {
+	mux.l.Lock()
+	defer mux.l.Unlock()
+	if uc, ok := mux.conns[server]; ok {
+		return uc, nil
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	uc := &udpMuxConn{
+		mux:     mux,
+		server:  server,
+		conn:    conn,
+		done:    make(chan struct{}),
+		pending: map[uint16]*udpMuxPending{},
+	}
+	mux.conns[server] = uc
+	go uc.readLoop()
+	return uc, nil
+}
+
+// register picks an ID which isn't used by any outstanding query on the socket
+// and returns it along with the channel the response will be sent on
+func (uc *udpMuxConn) register(q dns.Question) (uint16, chan *dns.Msg, error) {
+	uc.l.Lock()
+	defer uc.l.Unlock()
+	if uc.closed {
+		return 0, nil, errUDPConnClosed
+	}
+	id := dns.Id()
+	for uc.pending[id] != nil {
+		id = dns.Id()
+	}
+	p := &udpMuxPending{q: q, ch: make(chan *dns.Msg, 1)}
+	uc.pending[id] = p
+	return id, p.ch, nil
+}
+
+func (uc *udpMuxConn) unregister(id uint16) {
+	uc.l.Lock()
+	delete(uc.pending, id)
+	uc.l.Unlock()
+}
+
+// close closes the socket and removes it from the udpMux, causing any
+// outstanding queries on it to fail with err
+func (uc *udpMuxConn) close(err error) {
+	uc.l.Lock()
+	closed := uc.markClosed(err)
+	uc.l.Unlock()
+	if closed {
+		uc.teardown()
+	}
+}
+
+// closeIfIdle closes the socket if there aren't any outstanding queries on it,
+// and returns whether it did. The check and marking it closed happen together,
+// so that a query registered afterwards always dials a new socket instead of
+// failing.
+func (uc *udpMuxConn) closeIfIdle() bool {
+	uc.l.Lock()
+	idle := len(uc.pending) == 0
+	closed := idle && uc.markClosed(errUDPConnClosed)
+	uc.l.Unlock()
+	if closed {
+		uc.teardown()
+	}
+	return idle
+}
+
+// markClosed marks the socket as closed with err and returns true, unless it
+// already was. uc.l must be held.
+func (uc *udpMuxConn) markClosed(err error) bool {
+	if uc.closed {
+		return false
+	}
+	uc.closed = true
+	uc.err = err
+	close(uc.done)
+	return true
+}
+
+// teardown removes the socket from the udpMux and closes it, once markClosed
+// has been called
+func (uc *udpMuxConn) teardown() {
+	uc.mux.l.Lock()
+	if uc.mux.conns[uc.server] == uc {
+		delete(uc.mux.conns, uc.server)
+	}
+	uc.mux.l.Unlock()
+	uc.conn.Close()
+}
+
+// deliver sends the response to the query it's for, if there's one
+// outstanding. Responses which don't match an outstanding query's ID and
+// question are dropped, since they're either late or spoofed.
+func (uc *udpMuxConn) deliver(res *dns.Msg) {
+	if len(res.Question) != 1 {
+		return
+	}
+	q := res.Question[0]
+	uc.l.Lock()
+	defer uc.l.Unlock()
+	p, ok := uc.pending[res.Id]
+	if !ok || p.q.Qtype != q.Qtype || p.q.Qclass != q.Qclass || !strings.EqualFold(p.q.Name, q.Name) {
+		return
+	}
+	delete(uc.pending, res.Id)
+	p.ch <- res
+}
+
+func (uc *udpMuxConn) readLoop() {
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		uc.conn.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+		n, err := uc.conn.Read(buf)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			if uc.closeIfIdle() {
+				return
+			}
+			continue
+		} else if err != nil {
+			uc.close(err)
+			return
+		}
+
+		res := new(dns.Msg)
+		if err := res.Unpack(buf[:n]); err != nil {
+			continue
+		}
+		uc.deliver(res)
+	}
+}
+
+// ExchangeContext implements the Exchanger interface
+func (mux *udpMux) ExchangeContext(ctx context.Context, m *dns.Msg, server string) (*dns.Msg, time.Duration, error) {
+	var uc *udpMuxConn
+	var id uint16
+	var ch chan *dns.Msg
+	for {
+		var err error
+		if uc, err = mux.conn(ctx, server); err != nil {
+			return nil, 0, err
+		}
+		id, ch, err = uc.register(m.Question[0])
+		if err == nil {
+			break
+		} else if !errors.Is(err, errUDPConnClosed) {
+			return nil, 0, err
+		}
+		// the socket was closed in between being retrieved and registered
+		// on, which means it's already been removed and we'll get a new one
+	}
+	defer uc.unregister(id)
+
+	// the ID is chosen by the socket so that it's unique amongst its
+	// outstanding queries, so we restore the original once we're done
+	origID := m.Id
+	m.Id = id
+	b, err := m.Pack()
+	m.Id = origID
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	if _, err := uc.conn.Write(b); err != nil {
+		uc.close(err)
+		return nil, 0, err
+	}
+
+	select {
+	case res := <-ch:
+		res.Id = origID
+		return res, time.Since(start), nil
+	case <-uc.done:
+		return nil, time.Since(start), uc.err
+	case <-ctx.Done():
+		return nil, time.Since(start), ctx.Err()
+	}
+}
@@ -0,0 +1,135 @@
+package srvclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReuseUDPSockets(t *testing.T) {
+	var l sync.Mutex
+	sources := map[string]int{}
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		l.Lock()
+		sources[w.RemoteAddr().String()]++
+		l.Unlock()
+		handleRequest(w, r)
+	})
+
+	client := SRVClient{ReuseUDPSockets: true}
+	client.ResolverAddrs = []string{addr}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := client.AllSRVNoCacheContext(context.Background(), testHostname)
+			assert.NoError(t, err)
+			assert.Len(t, r, 2)
+		}()
+	}
+	wg.Wait()
+
+	l.Lock()
+	defer l.Unlock()
+	assert.Len(t, sources, 1)
+	for _, n := range sources {
+		assert.Equal(t, 20, n)
+	}
+	assert.Equal(t, int64(20), client.Stats().UDPQueries)
+
+	// truncated responses still fall back to TCP
+	client2 := SRVClient{ReuseUDPSockets: true}
+	client2.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	r, err := client2.SRV(testHostnameTruncated)
+	require.NoError(t, err)
+	assert.True(t, r == "10.0.0.2:1000" || r == "[2607:5300:60:92e7::2]:1001")
+	assert.Equal(t, int64(1), client2.Stats().TruncatedResponses)
+}
+
+func TestUDPMuxMismatched(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		// first send a response for a different question, and one with a
+		// different ID, both of which should be ignored
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Question[0].Name = "other.test."
+		w.WriteMsg(m)
+		m = new(dns.Msg)
+		m.SetReply(r)
+		m.Id++
+		w.WriteMsg(m)
+		handleRequest(w, r)
+	})
+
+	mux := newUDPMux(dns.DefaultMsgSize)
+	m := new(dns.Msg)
+	m.SetQuestion(testHostname+".", dns.TypeSRV)
+	res, _, err := mux.ExchangeContext(context.Background(), m, addr)
+	require.NoError(t, err)
+	assert.Equal(t, m.Id, res.Id)
+	assert.Equal(t, m.Question, res.Question)
+	assert.Len(t, res.Answer, 2)
+}
+
+func TestUDPMuxTimeout(t *testing.T) {
+	addr := startTestServer(t, func(dns.ResponseWriter, *dns.Msg) {})
+
+	mux := newUDPMux(dns.DefaultMsgSize)
+	m := new(dns.Msg)
+	m.SetQuestion(testHostname+".", dns.TypeSRV)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := mux.ExchangeContext(ctx, m, addr)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// the socket stays open, but no longer has the query outstanding
+	mux.l.Lock()
+	uc := mux.conns[addr]
+	mux.l.Unlock()
+	require.NotNil(t, uc)
+	assert.True(t, uc.closeIfIdle())
+	mux.l.Lock()
+	assert.Empty(t, mux.conns)
+	mux.l.Unlock()
+}
+
+func TestUDPMuxCloseIfIdleRace(t *testing.T) {
+	addr := startTestServer(t, handleRequest)
+	mux := newUDPMux(dns.DefaultMsgSize)
+	ctx := context.Background()
+
+	// queries racing with the socket being closed for being idle get a new
+	// socket instead of failing
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := new(dns.Msg)
+			m.SetQuestion(testHostname+".", dns.TypeSRV)
+			if _, _, err := mux.ExchangeContext(ctx, m, addr); err != nil {
+				errs <- err
+			}
+		}()
+		mux.l.Lock()
+		uc := mux.conns[addr]
+		mux.l.Unlock()
+		if uc != nil {
+			uc.closeIfIdle()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
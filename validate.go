@@ -0,0 +1,117 @@
+package srvclient
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxResponseRecords is the number of records in each of a response's answer
+// and extra sections which are processed. Any more are dropped.
+const maxResponseRecords = 1024
+
+// validateResponse checks that res is a response to the query m, and isn't
+// larger than any DNS message can legitimately be, so that spoofed or malformed
+// responses don't reach Preprocess or the cache. It also drops any records over
+// maxResponseRecords from res.
+func validateResponse(m, res *dns.Msg, server string) error {
+	invalid := func(format string, args ...interface{}) error {
+		return &ErrInvalidResponse{
+			Hostname: strings.TrimSuffix(m.Question[0].Name, "."),
+			Resolver: server,
+			Reason:   fmt.Sprintf(format, args...),
+		}
+	}
+	switch {
+	case res == nil:
+		return invalid("empty response")
+	case !res.Response:
+		return invalid("message is not a response")
+	case res.Id != m.Id:
+		return invalid("id %d doesn't match query id %d", res.Id, m.Id)
+	case res.Opcode != m.Opcode:
+		return invalid("unexpected opcode %s", dns.OpcodeToString[res.Opcode])
+	}
+
+	// a resolver can omit the question when responding with an error
+	if len(res.Question) > 0 || res.Rcode == dns.RcodeSuccess {
+		q := m.Question[0]
+		if len(res.Question) != 1 {
+			return invalid("expected 1 question, got %d", len(res.Question))
+		}
+		rq := res.Question[0]
+		// resolvers can randomize the case of names (see draft-vixie-dnsext-dns0x20)
+		if !strings.EqualFold(rq.Name, q.Name) || rq.Qtype != q.Qtype || rq.Qclass != q.Qclass {
+			return invalid("question %q doesn't match query %q", rq.String(), q.String())
+		}
+	}
+
+	if l := res.Len(); l > dns.MaxMsgSize {
+		return invalid("message is too large (%d bytes)", l)
+	}
+
+	if len(res.Answer) > maxResponseRecords {
+		res.Answer = res.Answer[:maxResponseRecords]
+	}
+	if len(res.Extra) > maxResponseRecords {
+		res.Extra = res.Extra[:maxResponseRecords]
+	}
+	return nil
+}
+
+// validate checks res using validateResponse and against the MaxAnswers and
+// MaxResponseSize limits
+func (sc *SRVClient) validate(m, res *dns.Msg, server string) error {
+	if err := validateResponse(m, res, server); err != nil {
+		return err
+	}
+	exceeded := func(limit string, max, n int) error {
+		return &ErrLimitExceeded{
+			Hostname: strings.TrimSuffix(m.Question[0].Name, "."),
+			Resolver: server,
+			Limit:    limit,
+			Max:      max,
+			N:        n,
+		}
+	}
+	if sc.MaxAnswers > 0 && len(res.Answer) > sc.MaxAnswers {
+		return exceeded("answers", sc.MaxAnswers, len(res.Answer))
+	}
+	if sc.MaxResponseSize > 0 {
+		if l := res.Len(); l > sc.MaxResponseSize {
+			return exceeded("response size", sc.MaxResponseSize, l)
+		}
+	}
+	return nil
+}
+
+// checkMinAnswers returns an ErrTooFewAnswers if res is a successful response
+// with fewer than MinAnswers SRV records
+func (sc *SRVClient) checkMinAnswers(res *dns.Msg, server string) error {
+	if res == nil || res.Rcode != dns.RcodeSuccess || len(res.Question) == 0 {
+		return nil
+	}
+	var n int
+	for _, rr := range res.Answer {
+		if _, ok := rr.(*dns.SRV); ok {
+			n++
+		}
+	}
+	if n == 0 || n >= sc.MinAnswers {
+		return nil
+	}
+	return &ErrTooFewAnswers{
+		Hostname: strings.TrimSuffix(res.Question[0].Name, "."),
+		Resolver: server,
+		Min:      sc.MinAnswers,
+		N:        n,
+	}
+}
+
+// ValidSRV can be used as ValidateRecord to drop the records which can't be
+// connected to: ones with a port of 0, or without a target. A target of "."
+// means the service isn't available at all, as described by RFC 2782.
+func ValidSRV(srv *dns.SRV) bool {
+	return srv.Port != 0 && srv.Target != "" && srv.Target != "."
+}
@@ -0,0 +1,185 @@
+package srvclient
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateResponse(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(testHostname), dns.TypeSRV)
+
+	reply := func(fn func(res *dns.Msg)) *dns.Msg {
+		res := new(dns.Msg)
+		res.SetReply(m)
+		fn(res)
+		return res
+	}
+	assert.NoError(t, validateResponse(m, reply(func(*dns.Msg) {}), "server"))
+	assert.NoError(t, validateResponse(m, reply(func(res *dns.Msg) {
+		res.Question[0].Name = "SRV.test.TEST."
+	}), "server"))
+	assert.NoError(t, validateResponse(m, reply(func(res *dns.Msg) {
+		res.Rcode = dns.RcodeServerFailure
+		res.Question = nil
+	}), "server"))
+
+	for _, fn := range []func(res *dns.Msg){
+		func(res *dns.Msg) { res.Response = false },
+		func(res *dns.Msg) { res.Id++ },
+		func(res *dns.Msg) { res.Question = nil },
+		func(res *dns.Msg) { res.Question[0].Name = "other." },
+		func(res *dns.Msg) { res.Question[0].Qtype = dns.TypeA },
+	} {
+		err := validateResponse(m, reply(fn), "server")
+		assert.True(t, errors.Is(err, &ErrInvalidResponse{}), "%v", err)
+	}
+
+	res := reply(func(res *dns.Msg) {
+		for i := 0; i < maxResponseRecords+1; i++ {
+			res.Answer = append(res.Answer, newRR("srv.test.test. 60 IN SRV 0 0 1000 1.srv.test."))
+		}
+	})
+	require.NoError(t, validateResponse(m, res, "server"))
+	assert.Len(t, res.Answer, maxResponseRecords)
+}
+
+func TestSpoofedResponse(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Question[0].Name = "spoofed.test."
+		m.Answer = []dns.RR{newRR("spoofed.test. 60 IN SRV 0 0 1000 1.srv.test.")}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{}
+	client.ResolverAddrs = []string{addr}
+	_, err := client.SRV(testHostname)
+	assert.True(t, errors.Is(err, &ErrExchange{}))
+	assert.True(t, errors.Is(err, &ErrInvalidResponse{}))
+}
+
+func TestLimits(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(testHostname), dns.TypeSRV)
+	res := new(dns.Msg)
+	res.SetReply(m)
+	for i := 0; i < 10; i++ {
+		res.Answer = append(res.Answer, newRR("srv.test.test. 60 IN SRV 0 0 1000 1.srv.test."))
+	}
+
+	sc := new(SRVClient)
+	assert.NoError(t, sc.validate(m, res, "server"))
+
+	sc.MaxAnswers = 10
+	assert.NoError(t, sc.validate(m, res, "server"))
+	sc.MaxAnswers = 9
+	err := sc.validate(m, res, "server")
+	var lerr *ErrLimitExceeded
+	require.True(t, errors.As(err, &lerr))
+	assert.Equal(t, "answers", lerr.Limit)
+	assert.Equal(t, 9, lerr.Max)
+	assert.Equal(t, 10, lerr.N)
+
+	sc.MaxAnswers = 0
+	sc.MaxResponseSize = res.Len()
+	assert.NoError(t, sc.validate(m, res, "server"))
+	sc.MaxResponseSize = 100
+	err = sc.validate(m, res, "server")
+	require.True(t, errors.As(err, &lerr))
+	assert.Equal(t, "response size", lerr.Limit)
+
+	// through a lookup it's wrapped in an ErrExchange
+	client := SRVClient{MaxAnswers: 1}
+	client.ResolverAddrs = DefaultSRVClient.ResolverAddrs[:1]
+	_, err = client.SRV(testHostname)
+	assert.True(t, errors.Is(err, &ErrExchange{}))
+	assert.True(t, errors.Is(err, &ErrLimitExceeded{}))
+}
+
+func TestMinAnswers(t *testing.T) {
+	var single int64
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if atomic.LoadInt64(&single) == 0 {
+			handleRequest(w, r)
+			return
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{newRR("srv.test. 60 IN SRV 0 0 1000 1.srv.test.")}
+		w.WriteMsg(m)
+	})
+
+	client := SRVClient{MinAnswers: 2}
+	client.ResolverAddrs = []string{addr}
+	r, err := client.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, r, 2)
+
+	atomic.StoreInt64(&single, 1)
+	_, err = client.AllSRV(testHostname)
+	var ferr *ErrTooFewAnswers
+	require.True(t, errors.As(err, &ferr))
+	assert.Equal(t, 2, ferr.Min)
+	assert.Equal(t, 1, ferr.N)
+	assert.Equal(t, addr, ferr.Resolver)
+
+	// with the cache the last full response is used instead, and the partial
+	// one isn't cached
+	client = SRVClient{MinAnswers: 2}
+	client.ResolverAddrs = []string{addr}
+	client.EnableCacheLast()
+	atomic.StoreInt64(&single, 0)
+	_, err = client.AllSRV(testHostname)
+	require.NoError(t, err)
+	atomic.StoreInt64(&single, 1)
+	for i := 0; i < 2; i++ {
+		r, err = client.AllSRV(testHostname)
+		assert.True(t, errors.Is(err, &ErrTooFewAnswers{}))
+		assert.Len(t, r, 2)
+	}
+}
+
+func TestValidateRecord(t *testing.T) {
+	addr := startTestServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		name := r.Question[0].Name
+		m.Answer = []dns.RR{
+			newRR(name + " 60 IN SRV 0 0 0 placeholder.test."),
+			newRR(name + " 60 IN SRV 0 0 1000 ."),
+		}
+		if name == dns.Fqdn(testHostname) {
+			m.Answer = append(m.Answer, newRR(name+" 60 IN SRV 0 0 1000 good.test."))
+		}
+		w.WriteMsg(m)
+	})
+
+	sc := &SRVClient{ResolverAddrs: []string{addr}, ValidateRecord: ValidSRV}
+	ans, err := sc.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"good.test.:1000"}, ans)
+	assert.Equal(t, int64(2), sc.Stats().DroppedRecords)
+
+	_, err = sc.SRV("other.test")
+	assert.True(t, errors.Is(err, ErrNoRecords), "%v", err)
+	assert.Equal(t, int64(4), sc.Stats().DroppedRecords)
+
+	// nothing is dropped without a ValidateRecord
+	sc = &SRVClient{ResolverAddrs: []string{addr}}
+	ans, err = sc.AllSRV(testHostname)
+	require.NoError(t, err)
+	assert.Len(t, ans, 3)
+	assert.Zero(t, sc.Stats().DroppedRecords)
+
+	assert.True(t, ValidSRV(&dns.SRV{Port: 1, Target: "a.test."}))
+	assert.False(t, ValidSRV(&dns.SRV{Port: 1, Target: "."}))
+	assert.False(t, ValidSRV(&dns.SRV{Port: 1}))
+	assert.False(t, ValidSRV(&dns.SRV{Target: "a.test."}))
+}